@@ -2,6 +2,7 @@
 
 if [ $# -ne 3 ]; then
   echo "Usage $0 <worklog_url> <month> <dest-dir>"
+  echo "Month is any range accepted by 'worklog filter', for example 2021-03 or last-month."
   exit 2
 fi
 
@@ -15,10 +16,7 @@ export WORKLOG="$workdir/worklog.txt"
 
 wget "$worklog_url" -O "$WORKLOG"
 
-worklog filter "$month" | worklog invoice >"$workdir/invoice.html"
-worklog filter "$month" | worklog fmt html >"$workdir/worklog.html"
-
-chromium --headless --print-to-pdf-no-header --disable-gpu --print-to-pdf="$destdir/invoice_${lowermonth}.pdf" "$workdir/invoice.html"
-chromium --headless --print-to-pdf-no-header --disable-gpu --print-to-pdf="$destdir/worklog_${lowermonth}.pdf" "$workdir/worklog.html"
+worklog filter "$month" | worklog invoice -o "$destdir/invoice_${lowermonth}.pdf"
+worklog filter -format pdf "$month" >"$destdir/worklog_${lowermonth}.pdf"
 
 rm -r "$workdir"
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/husio/worklog/wlog"
+)
+
+func cmdBalance(input io.Reader, output io.Writer, args []string) error {
+	fl := flag.NewFlagSet("balance", flag.ContinueOnError)
+	contractFl := fl.String("c", "contract.txt", "Path to the contract definition file.")
+	byFl := fl.String("by", "week", "Group balance by week or month.")
+	durationFl := fl.String("duration", string(wlog.DefaultDurationStyle), "Duration display style: 1h30m, 1.5h or 1:30.")
+	holidaysFl := fl.String("holidays", defaultHolidays(), "Holiday region and files with additional days. Used if the contract does not define holidays.")
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), "Usage: balance [<flags>] [<range>]")
+		fmt.Fprint(fl.Output(), `
+Compare the logged time with the time expected by the contract. Range is a
+date expression as accepted by the filter command. By default the balance is
+computed from the contract start until today.
+
+Contract file defines the expected work time for each weekday and days off:
+
+	# 32h over 4 days.
+	holidays DE-BY
+	2021-01-01 mon-thu=8h
+	2021-07-01 2021-12-31 mon-fri=6h24m
+	off 2021-12-24 Christmas Eve
+	off 2021-08-02..2021-08-13 vacation
+`)
+		fl.PrintDefaults()
+	}
+	if err := fl.Parse(args); err != nil {
+		return fmt.Errorf("flag parse: %w", err)
+	}
+	style, err := wlog.ParseDurationStyle(*durationFl)
+	if err != nil {
+		return err
+	}
+
+	fd, err := os.Open(*contractFl)
+	if err != nil {
+		return fmt.Errorf("cannot open %q: %w", *contractFl, err)
+	}
+	defer fd.Close()
+	contract, err := wlog.ParseContract(fd)
+	if err != nil {
+		return fmt.Errorf("cannot read contract: %w", err)
+	}
+	if contract.Holidays == nil {
+		if contract.Holidays, err = holidayCalendar(*holidaysFl); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	dr := wlog.DateRange{
+		From: contract.Start(),
+		To:   time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC),
+	}
+	if len(fl.Args()) != 0 {
+		r, err := wlog.ParseDateRange(strings.Join(fl.Args(), " "), now)
+		if err != nil {
+			return err
+		}
+		if !r.From.IsZero() {
+			dr.From = r.From
+		}
+		if !r.To.IsZero() {
+			dr.To = r.To
+		}
+	}
+
+	entries, err := wlog.Parse(input)
+	if err != nil {
+		return fmt.Errorf("parse log: %s", err)
+	}
+	rows, err := wlog.Balance(entries, contract, dr, *byFl)
+	if err != nil {
+		return err
+	}
+
+	wr := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(wr, "PERIOD\tFROM\tTO\tEXPECTED\tWORKED\tDIFF\tBALANCE")
+	var expected, worked time.Duration
+	for _, r := range rows {
+		expected += r.Expected
+		worked += r.Worked
+		fmt.Fprintf(wr, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.Period,
+			r.From.Format("2006-01-02"),
+			r.To.Format("2006-01-02"),
+			wlog.FormatDuration(r.Expected, style),
+			wlog.FormatDuration(r.Worked, style),
+			formatSignedDuration(r.Diff(), style),
+			formatSignedDuration(r.Running, style),
+		)
+	}
+	fmt.Fprintf(wr, "total\t%s\t%s\t%s\t%s\t%s\t\n",
+		dr.From.Format("2006-01-02"),
+		dr.To.Format("2006-01-02"),
+		wlog.FormatDuration(expected, style),
+		wlog.FormatDuration(worked, style),
+		formatSignedDuration(worked-expected, style),
+	)
+	return wr.Flush()
+}
+
+// formatSignedDuration returns the duration with an explicit sign.
+func formatSignedDuration(d time.Duration, style wlog.DurationStyle) string {
+	if d > 0 {
+		return "+" + wlog.FormatDuration(d, style)
+	}
+	return wlog.FormatDuration(d, style)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/husio/worklog/wlog"
+)
+
+func cmdDiff(input io.Reader, output io.Writer, args []string) error {
+	fl := flag.NewFlagSet("diff", flag.ContinueOnError)
+	urlFl := fl.String("url", "", "Worklog storage URL. By default WORKLOG, if it is a storage server URL.")
+	tokenFl := fl.String("token", "", "Worklog storage read token. By default WORKLOG_TOKEN.")
+	durationFl := fl.String("duration", string(wlog.DefaultDurationStyle), "Duration display style: 1h30m, 1.5h or 1:30.")
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), "Usage: diff [<flags>] <rev> [<rev>]")
+		fmt.Fprint(fl.Output(), `
+Show days and tasks changed between two revisions of the worklog kept by the
+storage server. If only one revision is given, it is compared with the
+current one. Revisions are listed by the history command and can be
+shortened to any unambiguous prefix.
+
+`)
+		fl.PrintDefaults()
+	}
+	if err := fl.Parse(args); err != nil {
+		return fmt.Errorf("flag parse: %w", err)
+	}
+	if fl.NArg() < 1 || fl.NArg() > 2 {
+		fl.Usage()
+		return fmt.Errorf("expected one or two revisions")
+	}
+	style, err := wlog.ParseDurationStyle(*durationFl)
+	if err != nil {
+		return err
+	}
+
+	remote, err := newRemoteWorklog(*urlFl, *tokenFl)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	from, err := remoteEntries(ctx, remote, fl.Arg(0))
+	if err != nil {
+		return err
+	}
+	toRev := ""
+	if fl.NArg() == 2 {
+		toRev = fl.Arg(1)
+	}
+	to, err := remoteEntries(ctx, remote, toRev)
+	if err != nil {
+		return err
+	}
+	if _, err := writeEntriesDiff(output, from, to, style); err != nil {
+		return fmt.Errorf("write diff: %w", err)
+	}
+	return nil
+}
+
+// remoteEntries returns entries of the remote worklog revision. Empty
+// revision is the current worklog.
+func remoteEntries(ctx context.Context, remote *remoteWorklog, rev string) ([]*wlog.Entry, error) {
+	var (
+		content []byte
+		err     error
+	)
+	if rev == "" {
+		content, err = remote.get(ctx)
+	} else {
+		content, err = remote.revision(ctx, rev)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("revision %s: %w", rev, err)
+	}
+	entries, err := wlog.Parse(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("parse revision %s: %w", rev, err)
+	}
+	return entries, nil
+}
@@ -1,10 +1,11 @@
 package main
 
 import (
-	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/husio/worklog/wlog"
@@ -12,60 +13,96 @@ import (
 
 func cmdFilter(input io.Reader, output io.Writer, args []string) error {
 	fl := flag.NewFlagSet("filter", flag.ContinueOnError)
+	durationFl := fl.String("duration", string(wlog.DefaultDurationStyle), "Duration display style: 1h30m, 1.5h or 1:30.")
+	formatFl := fl.String("format", "txt", "Output format, one of the formats supported by the fmt command: text, json, csv, html, pdf.")
+	fromFl := fl.String("from", "", "Include only days since given date (YYYY-MM-DD).")
+	toFl := fl.String("to", "", "Include only days until given date (YYYY-MM-DD).")
+	yearFl := fl.Int("year", 0, "Include only days of given year.")
+	monthFl := fl.String("month", "", "Include only days of given month, for example 2021-03 or March. Month name refers to the -year or the current year.")
+	weekFl := fl.String("week", "", "Include only days of given ISO week, for example 2021-W14.")
+	queryFl := fl.String("q", "", "Include only tasks matching the query, for example: desc ~ /deploy/i and duration > 2h.")
+	holidaysFl := fl.String("holidays", defaultHolidays(), "Holiday region and files with additional days, highlighted in the html and pdf formats.")
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), "Usage: filter [<flags>] [<range>]")
+		fmt.Fprintln(fl.Output(), `
+Range is a date expression, for example 2021-03, March, 2021-W14, 2021-Q2,
+2021, last-month, this-week, "last 30 days" or 2021-01..2021-03. All given
+conditions must be met.`)
+		fl.PrintDefaults()
+	}
 	if err := fl.Parse(args); err != nil {
 		return fmt.Errorf("flag parse: %w", err)
 	}
-
-	if len(fl.Args()) != 1 {
-		return errors.New("usage: fmt <month-name>")
+	style, err := wlog.ParseDurationStyle(*durationFl)
+	if err != nil {
+		return err
+	}
+	holidays, err := holidayCalendar(*holidaysFl)
+	if err != nil {
+		return err
 	}
 
-	m, ok := months[fl.Args()[0]]
-	if !ok {
-		return fmt.Errorf("invalid month: %q", fl.Args()[0])
+	now := time.Now()
+	var dr wlog.DateRange
+	if *fromFl != "" {
+		day, err := wlog.ParseDate(*fromFl)
+		if err != nil {
+			return err
+		}
+		dr = dr.Intersect(wlog.DateRange{From: day})
+	}
+	if *toFl != "" {
+		day, err := wlog.ParseDate(*toFl)
+		if err != nil {
+			return err
+		}
+		dr = dr.Intersect(wlog.DateRange{To: day})
+	}
+	year := now.Year()
+	if *yearFl != 0 {
+		year = *yearFl
+		r, err := wlog.ParseDateRange(strconv.Itoa(*yearFl), now)
+		if err != nil {
+			return err
+		}
+		dr = dr.Intersect(r)
+	}
+	if *monthFl != "" {
+		r, err := wlog.ParseDateRangeYear(*monthFl, year, now)
+		if err != nil {
+			return err
+		}
+		dr = dr.Intersect(r)
+	}
+	if *weekFl != "" {
+		r, err := wlog.ParseDateRange(*weekFl, now)
+		if err != nil {
+			return err
+		}
+		dr = dr.Intersect(r)
+	}
+	if len(fl.Args()) != 0 {
+		r, err := wlog.ParseDateRangeYear(strings.Join(fl.Args(), " "), year, now)
+		if err != nil {
+			return err
+		}
+		dr = dr.Intersect(r)
+	}
+	var query *wlog.Query
+	if *queryFl != "" {
+		if query, err = wlog.ParseQueryAt(*queryFl, now); err != nil {
+			return err
+		}
 	}
 
 	entries, err := wlog.Parse(input)
 	if err != nil {
 		return fmt.Errorf("parse log: %s", err)
 	}
-
-	all := entries
-	entries = nil
-	for _, e := range all {
-		if e.Day.Month() == m {
-			entries = append(entries, e)
-		}
+	entries = dr.Filter(entries)
+	if query != nil {
+		entries = query.Filter(entries)
 	}
 
-	if err := wlog.ToText(output, entries); err != nil {
-		return fmt.Errorf("format to text: %w", err)
-	}
-	return nil
-}
-
-var months = map[string]time.Month{
-	"January":  time.January,
-	"Jan":      time.January,
-	"February": time.February,
-	"Feb":      time.February,
-	"March":    time.March,
-	"Mar":      time.March,
-	"April":    time.April,
-	"Apr":      time.April,
-	"May":      time.May,
-	"June":     time.June,
-	"Jun":      time.June,
-	"July":     time.July,
-	"Jul":      time.July,
-	"August":   time.August,
-	"Aug":      time.August,
-	"Septembe": time.September,
-	"Sep":      time.September,
-	"October":  time.October,
-	"Oct":      time.October,
-	"November": time.November,
-	"Nov":      time.November,
-	"December": time.December,
-	"Dec":      time.December,
+	return writeEntries(output, *formatFl, entries, style, holidays)
 }
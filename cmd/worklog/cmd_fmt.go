@@ -8,10 +8,13 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"html/template"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
-	"text/template"
+	"strings"
 	"time"
 
 	"github.com/husio/worklog/wlog"
@@ -19,29 +22,84 @@ import (
 
 func cmdFmt(input io.Reader, output io.Writer, args []string) error {
 	fl := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	durationFl := fl.String("duration", string(wlog.DefaultDurationStyle), "Duration display style: 1h30m, 1.5h or 1:30.")
+	writeFl := fl.Bool("w", false, "Write the canonical text format back to the worklog file.")
+	listFl := fl.Bool("l", false, "Print the worklog file name if its content is not in the canonical text format.")
+	diffFl := fl.Bool("d", false, "Print the difference between the worklog and its canonical text format.")
+	holidaysFl := fl.String("holidays", defaultHolidays(), "Holiday region and files with additional days, highlighted in the html and pdf formats.")
+	templateFl := fl.String("template", "", "Path to the custom report template, used instead of the built-in html template. Run 'templates export fmt' to get a starting point.")
 	if err := fl.Parse(args); err != nil {
 		return fmt.Errorf("flag parse: %w", err)
 	}
+	style, err := wlog.ParseDurationStyle(*durationFl)
+	if err != nil {
+		return err
+	}
+	holidays, err := holidayCalendar(*holidaysFl)
+	if err != nil {
+		return err
+	}
+
+	var report *template.Template
+	if *templateFl != "" {
+		if report, err = loadTemplate(*templateFl, templateFuncs(style, holidays)); err != nil {
+			return err
+		}
+	}
 
 	var format string
 	switch len(fl.Args()) {
 	case 0:
 		format = "txt"
+		if report != nil {
+			format = "html"
+		}
 	case 1:
 		format = fl.Args()[0]
 	default:
 		return fmt.Errorf("usage: fmt [<format>]")
 	}
+	if report != nil && format != "html" {
+		return errors.New("-template can be used only with the html format")
+	}
 
-	entries, err := wlog.Parse(input)
+	file, err := wlog.ParseFile(input)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "parse log: %s\n", err)
 		os.Exit(1)
 	}
+	entries := file.Entries()
+
+	if *writeFl || *listFl || *diffFl {
+		if format != "text" && format != "txt" {
+			return errors.New("-w, -l and -d flags can be used only with the text format")
+		}
+		if style == wlog.DurationClock && !wlog.ClockDurations() {
+			// The rewritten worklog would be read back with times of
+			// the day instead of durations.
+			return errors.New("clock durations can be written only if WORKLOG_DURATION=1:30 is set")
+		}
+		return canonicalizeWorklog(output, inputName(input), file, style, *writeFl, *listFl, *diffFl)
+	}
+
+	// All formats present entries, that would include both versions of a
+	// conflict.
+	if err := file.CheckConflicts(); err != nil {
+		return fmt.Errorf("parse log: %w", err)
+	}
+	if report != nil {
+		return executeReport(output, report, entries, style, holidays)
+	}
+	return writeEntries(output, format, entries, style, holidays)
+}
 
+// writeEntries writes entries to the output using given format. Format is
+// one of the names supported by the fmt command. Holidays are highlighted in
+// the html and pdf formats, calendar can be nil.
+func writeEntries(output io.Writer, format string, entries []*wlog.Entry, style wlog.DurationStyle, holidays *wlog.HolidayCalendar) error {
 	switch format {
 	case "text", "txt":
-		if err := wlog.ToText(output, entries); err != nil {
+		if err := wlog.ToTextStyle(output, entries, style); err != nil {
 			return fmt.Errorf("format to text: %w", err)
 		}
 		return nil
@@ -58,15 +116,24 @@ func cmdFmt(input io.Reader, output io.Writer, args []string) error {
 		wr := csv.NewWriter(output)
 		defer wr.Flush()
 
-		if err := wr.Write([]string{"day", "hours", "description"}); err != nil {
+		if err := wr.Write([]string{"day", "hours", "description", "project", "client", "tags", "attributes"}); err != nil {
 			return fmt.Errorf("write header: %w", err)
 		}
 		for _, e := range entries {
 			for _, t := range e.Tasks {
+				attrs := make([]string, 0, len(t.Attrs))
+				for k, v := range t.Attrs {
+					attrs = append(attrs, k+":"+v)
+				}
+				sort.Strings(attrs)
 				err := wr.Write([]string{
 					e.Day.Format("2/01/2006"),
 					fmt.Sprint(t.Duration.Hours()),
 					t.Description,
+					t.Project,
+					t.Client,
+					strings.Join(t.Tags, " "),
+					strings.Join(attrs, " "),
 				})
 				if err != nil {
 					return fmt.Errorf("write entry: %w", err)
@@ -75,69 +142,167 @@ func cmdFmt(input io.Reader, output io.Writer, args []string) error {
 		}
 		return nil
 	case "html":
-		var extended []*wlog.Entry
-		if len(entries) > 0 {
-			var i int
-			for t := entries[0].Day; !t.After(entries[len(entries)-1].Day); t = t.Add(time.Hour * 24) {
-				if i < len(entries) && entries[i].Day.Equal(t) {
-					extended = append(extended, entries[i])
-					i++
-				} else {
-					extended = append(extended, &wlog.Entry{Day: t})
-				}
+		return executeReport(output, nil, entries, style, holidays)
+	case "pdf":
+		return writeEntriesPDF(output, entries, style, holidays)
+	default:
+		return errors.New("valid formats are text, json, csv, html, pdf")
+	}
+}
+
+// entriesByMonth returns entries grouped by month, the most recent first.
+// Days missing in the worklog are added as empty entries, so that each day
+// of the period is present.
+func entriesByMonth(entries []*wlog.Entry) [][]*wlog.Entry {
+	var extended []*wlog.Entry
+	if len(entries) > 0 {
+		var i int
+		for t := entries[0].Day; !t.After(entries[len(entries)-1].Day); t = t.Add(time.Hour * 24) {
+			if i < len(entries) && entries[i].Day.Equal(t) {
+				extended = append(extended, entries[i])
+				i++
+			} else {
+				extended = append(extended, &wlog.Entry{Day: t})
 			}
 		}
-		sort.Slice(extended, func(i, j int) bool {
-			return extended[i].Day.After(extended[j].Day)
-		})
-
-		var byMonth [][]*wlog.Entry
-		if len(extended) > 0 {
-			current := []*wlog.Entry{extended[0]}
-			for _, e := range extended[1:] {
-				if e.Day.Month() == current[0].Day.Month() {
-					current = append(current, e)
-				} else {
-					byMonth = append(byMonth, current)
-					current = []*wlog.Entry{e}
-				}
+	}
+	sort.Slice(extended, func(i, j int) bool {
+		return extended[i].Day.After(extended[j].Day)
+	})
+
+	var byMonth [][]*wlog.Entry
+	if len(extended) > 0 {
+		current := []*wlog.Entry{extended[0]}
+		for _, e := range extended[1:] {
+			if e.Day.Month() == current[0].Day.Month() {
+				current = append(current, e)
+			} else {
+				byMonth = append(byMonth, current)
+				current = []*wlog.Entry{e}
 			}
-			byMonth = append(byMonth, current)
 		}
-		context := struct {
-			Entries [][]*wlog.Entry
-		}{
-			Entries: byMonth,
+		byMonth = append(byMonth, current)
+	}
+	return byMonth
+}
+
+// canonicalizeWorklog compares the worklog with its canonical text format,
+// similarly to how gofmt does. Depending on the flags, the worklog file is
+// rewritten, its name is printed or the difference is printed.
+func canonicalizeWorklog(output io.Writer, name string, file *wlog.File, style wlog.DurationStyle, write, list, diff bool) error {
+	// Formatting a worklog with syntax problems could change its meaning,
+	// for example an invalid header would become a part of the previous
+	// day.
+	if len(file.Diagnostics) != 0 {
+		return fmt.Errorf("%s: cannot format, fix the syntax problems first: %w", name, file.Diagnostics)
+	}
+	var b bytes.Buffer
+	if err := file.Format(&b, style); err != nil {
+		return fmt.Errorf("format to text: %w", err)
+	}
+	current := file.Bytes()
+	if bytes.Equal(current, b.Bytes()) {
+		return nil
+	}
+
+	if list {
+		if _, err := fmt.Fprintln(output, name); err != nil {
+			return err
+		}
+	}
+	if diff {
+		patch := unifiedDiff(name, name+" (canonical)", current, b.Bytes())
+		if _, err := output.Write(patch); err != nil {
+			return err
 		}
-		var b bytes.Buffer
-		if err := fmtTmpl.Execute(&b, context); err != nil {
-			return fmt.Errorf("render template: %w", err)
+	}
+	if write {
+		if name == "<stdin>" || isURL(name) {
+			return fmt.Errorf("cannot rewrite %s, only a local worklog file can be written", name)
 		}
-		if _, err := b.WriteTo(output); err != nil {
-			return fmt.Errorf("write to output: %w", err)
+		if err := writeFileAtomic(name, b.Bytes()); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
 		}
-		return nil
-	default:
-		return errors.New("valid formats are text, json, csv")
 	}
+	return nil
+}
+
+// writeFileAtomic replaces the content of the file at given path. Content is
+// first written to a temporary file that is then renamed, so that the file
+// is never left partially written. File permissions are preserved.
+func writeFileAtomic(path string, content []byte) error {
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	fd, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("create temporary file: %w", err)
+	}
+	defer os.Remove(fd.Name())
+
+	if _, err := fd.Write(content); err != nil {
+		fd.Close()
+		return fmt.Errorf("write temporary file: %w", err)
+	}
+	if err := fd.Sync(); err != nil {
+		fd.Close()
+		return fmt.Errorf("sync temporary file: %w", err)
+	}
+	if err := fd.Close(); err != nil {
+		return fmt.Errorf("close temporary file: %w", err)
+	}
+	if err := os.Chmod(fd.Name(), perm); err != nil {
+		return fmt.Errorf("chmod temporary file: %w", err)
+	}
+	if err := os.Rename(fd.Name(), path); err != nil {
+		return fmt.Errorf("rename temporary file: %w", err)
+	}
+	return nil
 }
 
 //go:embed cmd_fmt.html
 var htmlFmtTemplate string
 
-var fmtTmpl = template.Must(template.New("").Funcs(template.FuncMap{
-	"narrowhours": func(d time.Duration) string {
-		hours := d / time.Hour
-		if hours == 0 {
-			return ""
-		}
-		return fmt.Sprintf("%dh", hours)
-	},
-	"hoursduration": func(entries []*wlog.Entry) uint {
-		var total time.Duration
-		for _, e := range entries {
-			total += e.TotalDuration()
-		}
-		return uint(total.Hours())
-	},
-}).Parse(htmlFmtTemplate))
+var fmtTmpl = template.Must(template.New("fmt").Funcs(templateFuncs(wlog.DefaultDurationStyle, nil)).Parse(htmlFmtTemplate))
+
+// ReportContext is the context of the html worklog report template.
+type ReportContext struct {
+	// Entries are grouped by month, the most recent month first. Days
+	// missing in the worklog are added as empty entries.
+	Entries [][]*wlog.Entry
+	// Days are all entries, in the worklog order.
+	Days      []*wlog.Entry
+	Total     time.Duration
+	Generated time.Time
+}
+
+func newReportContext(entries []*wlog.Entry) ReportContext {
+	var total time.Duration
+	for _, e := range entries {
+		total += e.TotalDuration()
+	}
+	return ReportContext{
+		Entries:   entriesByMonth(entries),
+		Days:      entries,
+		Total:     total,
+		Generated: time.Now(),
+	}
+}
+
+// executeReport writes the worklog report using the template. Nil template
+// is the built-in html template.
+func executeReport(output io.Writer, t *template.Template, entries []*wlog.Entry, style wlog.DurationStyle, holidays *wlog.HolidayCalendar) error {
+	if t == nil {
+		t = fmtTmpl
+	}
+	var b bytes.Buffer
+	t = template.Must(t.Clone()).Funcs(templateFuncs(style, holidays))
+	if err := t.Execute(&b, newReportContext(entries)); err != nil {
+		return fmt.Errorf("render template: %w", err)
+	}
+	if _, err := b.WriteTo(output); err != nil {
+		return fmt.Errorf("write to output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/husio/worklog/wlog"
+)
+
+func TestCanonicalizeWorklog(t *testing.T) {
+	const messy = "# 1 Mar 2021 Monday\n\n  2h   review\n1h30m coding\n"
+	const canonical = "# 1 Mar 2021 Monday\n2h review\n1h30m coding\n"
+
+	cases := map[string]struct {
+		content     string
+		write       bool
+		list        bool
+		diff        bool
+		wantOutput  string
+		wantContent string
+		wantErr     string
+	}{
+		"canonical": {
+			content:     canonical,
+			write:       true,
+			list:        true,
+			diff:        true,
+			wantContent: canonical,
+		},
+		"list": {
+			content:     messy,
+			list:        true,
+			wantOutput:  "{name}\n",
+			wantContent: messy,
+		},
+		"diff": {
+			content: messy,
+			diff:    true,
+			wantOutput: `--- {name}
++++ {name} (canonical)
+@@ -1,4 +1,3 @@
+ # 1 Mar 2021 Monday
+-
+-  2h   review
++2h review
+ 1h30m coding
+`,
+			wantContent: messy,
+		},
+		"write": {
+			content:     messy,
+			write:       true,
+			wantContent: canonical,
+		},
+		"syntax problems": {
+			content:     "# 1 Mar 2021 Monday\n2h review\n  # 31 Feb 2021 Monday\n",
+			write:       true,
+			list:        true,
+			diff:        true,
+			wantContent: "# 1 Mar 2021 Monday\n2h review\n  # 31 Feb 2021 Monday\n",
+			wantErr:     `invalid day header "# 31 Feb 2021 Monday"`,
+		},
+	}
+	for testName, tc := range cases {
+		t.Run(testName, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "worklog.txt")
+			if err := ioutil.WriteFile(name, []byte(tc.content), 0600); err != nil {
+				t.Fatalf("write worklog: %s", err)
+			}
+			file, err := wlog.ParseFile(strings.NewReader(tc.content))
+			if err != nil {
+				t.Fatalf("parse: %s", err)
+			}
+
+			var out bytes.Buffer
+			err = canonicalizeWorklog(&out, name, file, wlog.DurationHM, tc.write, tc.list, tc.diff)
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("canonicalize: %s", err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Fatalf("want %q error, got %v", tc.wantErr, err)
+			}
+			if want := strings.ReplaceAll(tc.wantOutput, "{name}", name); out.String() != want {
+				t.Fatalf("want output\n%s\ngot\n%s", want, out.String())
+			}
+
+			b, err := ioutil.ReadFile(name)
+			if err != nil {
+				t.Fatalf("read worklog: %s", err)
+			}
+			if string(b) != tc.wantContent {
+				t.Fatalf("want content\n%s\ngot\n%s", tc.wantContent, b)
+			}
+			info, err := os.Stat(name)
+			if err != nil {
+				t.Fatalf("stat worklog: %s", err)
+			}
+			if perm := info.Mode().Perm(); perm != 0600 {
+				t.Fatalf("want permissions preserved, got %s", perm)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeWorklogStdin(t *testing.T) {
+	file, err := wlog.ParseFile(strings.NewReader("# 1 Mar 2021 Monday\n\n2h review\n"))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	if err := canonicalizeWorklog(ioutil.Discard, "<stdin>", file, wlog.DurationHM, true, false, false); err == nil {
+		t.Fatal("want error when rewriting the standard input")
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	cases := map[string]struct {
+		from, to string
+		want     string
+	}{
+		"equal": {
+			from: "a\nb\n",
+			to:   "a\nb\n",
+			want: "",
+		},
+		"separate hunks": {
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			to:   "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			want: `--- a
++++ b
+@@ -1,3 +1,4 @@
++0
+ 1
+ 2
+ 3
+@@ -8,4 +9,3 @@
+ 8
+ 9
+ 10
+-11
+`,
+		},
+		"no final newline": {
+			from: "a\nb",
+			to:   "a\nb\n",
+			want: `--- a
++++ b
+@@ -1,2 +1,2 @@
+ a
+-b
+\ No newline at end of file
++b
+`,
+		},
+		"replaced": {
+			from: "a\nb\n",
+			to:   "c\nd\n",
+			want: `--- a
++++ b
+@@ -1,2 +1,2 @@
+-a
+-b
++c
++d
+`,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := string(unifiedDiff("a", "b", []byte(tc.from), []byte(tc.to)))
+			if got != tc.want {
+				t.Fatalf("want\n%s\ngot\n%s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "worklog.txt")
+	if err := ioutil.WriteFile(path, []byte("old"), 0640); err != nil {
+		t.Fatalf("write: %s", err)
+	}
+	if err := writeFileAtomic(path, []byte("new")); err != nil {
+		t.Fatalf("write atomic: %s", err)
+	}
+	if b, err := ioutil.ReadFile(path); err != nil || string(b) != "new" {
+		t.Fatalf("want new content, got %q, %v", b, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0640 {
+		t.Fatalf("want permissions preserved, got %v, %v", info.Mode(), err)
+	}
+	// Temporary file must not be left behind.
+	if files, err := ioutil.ReadDir(dir); err != nil || len(files) != 1 {
+		t.Fatalf("want a single file in the directory, got %d, %v", len(files), err)
+	}
+}
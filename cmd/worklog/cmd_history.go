@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+)
+
+func cmdHistory(input io.Reader, output io.Writer, args []string) error {
+	fl := flag.NewFlagSet("history", flag.ContinueOnError)
+	urlFl := fl.String("url", "", "Worklog storage URL. By default WORKLOG, if it is a storage server URL.")
+	tokenFl := fl.String("token", "", "Worklog storage read token. By default WORKLOG_TOKEN.")
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), "Usage: history [<flags>]")
+		fmt.Fprintln(fl.Output(), "\nList revisions of the worklog kept by the storage server, newest first.")
+		fl.PrintDefaults()
+	}
+	if err := fl.Parse(args); err != nil {
+		return fmt.Errorf("flag parse: %w", err)
+	}
+
+	remote, err := newRemoteWorklog(*urlFl, *tokenFl)
+	if err != nil {
+		return err
+	}
+	revs, err := remote.history(context.Background())
+	if err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	for i, rev := range revs {
+		fmt.Fprintf(output, "%s  %s  %8d bytes", shortRevision(rev.Hash), rev.Time.Local().Format("2006-01-02 15:04:05"), rev.Size)
+		if i == 0 {
+			fmt.Fprint(output, "  (current)")
+		}
+		fmt.Fprintln(output)
+	}
+	return nil
+}
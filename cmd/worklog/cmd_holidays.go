@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/husio/worklog/wlog"
+)
+
+func cmdHolidays(input io.Reader, output io.Writer, args []string) error {
+	fl := flag.NewFlagSet("holidays", flag.ContinueOnError)
+	holidaysFl := fl.String("holidays", defaultHolidays(), "Holiday region code, optionally followed by comma separated files with additional days, for example DE-BY,holidays.txt.")
+	regionsFl := fl.Bool("regions", false, "List all supported region codes.")
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), "Usage: holidays [<flags>] [<year>]")
+		fmt.Fprint(fl.Output(), `
+List public holidays of a year. By default the current year is listed. The
+default region is configured via the WORKLOG_HOLIDAYS environment variable.
+
+A file with additional days contains a day and a name on each line. A day is
+either a date, a month and day repeated every year or an offset from Easter:
+
+	2021-06-14 Company anniversary
+	12-24 Christmas Eve
+	easter-3 Maundy Thursday
+`)
+		fl.PrintDefaults()
+	}
+	if err := fl.Parse(args); err != nil {
+		return fmt.Errorf("flag parse: %w", err)
+	}
+	if *regionsFl {
+		_, err := fmt.Fprintln(output, strings.Join(wlog.HolidayRegions(), "\n"))
+		return err
+	}
+	year := time.Now().Year()
+	switch len(fl.Args()) {
+	case 0:
+	case 1:
+		y, err := strconv.Atoi(fl.Arg(0))
+		if err != nil {
+			return fmt.Errorf("invalid year %q", fl.Arg(0))
+		}
+		year = y
+	default:
+		return fmt.Errorf("too many arguments")
+	}
+	holidays, err := holidayCalendar(*holidaysFl)
+	if err != nil {
+		return err
+	}
+	if holidays == nil {
+		return fmt.Errorf("holiday region not set, use the -holidays flag or the WORKLOG_HOLIDAYS environment variable")
+	}
+
+	wr := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	for _, h := range holidays.Holidays(year) {
+		fmt.Fprintf(wr, "%s\t%s\t%s\n", h.Day.Format("2006-01-02"), h.Day.Format("Mon"), h.Name)
+	}
+	return wr.Flush()
+}
+
+// defaultHolidays returns the holiday calendar specification configured via
+// the environment.
+func defaultHolidays() string {
+	return os.Getenv("WORKLOG_HOLIDAYS")
+}
+
+// holidayCalendar returns the holiday calendar described by the
+// specification, which is a comma separated list of a region code and paths
+// to files with additional days. Nil calendar is returned for an empty
+// specification.
+func holidayCalendar(spec string) (*wlog.HolidayCalendar, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	var (
+		region string
+		files  []string
+	)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if _, err := os.Stat(part); err == nil {
+			files = append(files, part)
+		} else if region == "" {
+			region = part
+		} else {
+			return nil, fmt.Errorf("invalid holidays %q, only one region can be used", spec)
+		}
+	}
+	holidays, err := wlog.NewHolidayCalendar(region)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range files {
+		if err := addHolidaysFile(holidays, path); err != nil {
+			return nil, err
+		}
+	}
+	return holidays, nil
+}
+
+func addHolidaysFile(holidays *wlog.HolidayCalendar, path string) error {
+	fd, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot open %q: %w", path, err)
+	}
+	defer fd.Close()
+	if err := holidays.AddDays(fd); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
@@ -3,17 +3,21 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
 	_ "embed"
+	"errors"
 	"flag"
 	"fmt"
+	"html"
+	"html/template"
 	"io"
 	"io/ioutil"
 	"math"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/husio/worklog/wlog"
@@ -22,32 +26,76 @@ import (
 var (
 	//go:embed cmd_invoice.html
 	rawTmpl string
-	tmpl    = template.Must(template.New("").Funcs(template.FuncMap{
-		"prettyNumber": prettyFormatNumberDE,
-	}).Parse(rawTmpl))
+	tmpl    = template.Must(template.New("invoice").Funcs(templateFuncs(wlog.DefaultDurationStyle, nil)).Parse(rawTmpl))
 )
 
 type TemplateContext struct {
-	Debtor          string
-	InvoiceNumber   string
-	InvoiceDate     string
-	ToCompany       string
-	ToAddress       string
-	ToCo            string
-	ToVATID         string
-	FromName        string
-	FromAddress     string
-	FromCountry     string
-	FromTaxID       string
-	FromEmail       string
+	Debtor        string
+	InvoiceNumber string
+	InvoiceDate   string
+	ToCompany     string
+	ToAddress     string
+	ToCo          string
+	ToVATID       string
+	FromName      string
+	FromAddress   string
+	FromCountry   string
+	FromTaxID     string
+	FromEmail     string
+	// Seller and buyer details below are required by electronic invoices.
+	// Country codes are ISO 3166-1 alpha-2 codes, for example DE.
+	FromVATID       string
+	FromPhone       string
+	FromCountryCode string
+	ToCountryCode   string
+	ToEmail         string
+	// BuyerReference is the reference assigned by the buyer, for example
+	// the Leitweg-ID of a public sector client.
+	BuyerReference  string
 	PaymentName     string
 	PaymentIBAN     string
 	PaymentBIC      string
 	PaymentBankName string
+	PaymentTerms    string
+	// GiroCode enables the payment QR code. GiroCodeImage is generated.
+	GiroCode      bool
+	GiroCodeImage template.URL
+	// QRBill enables the Swiss QR-bill payment part. QRBillReference is
+	// QRR, SCOR or NON. QRBillPart is generated.
+	QRBill          bool
+	QRBillReference string
+	QRBillPart      *QRBillPart
 	ItemDescription string
-	ItemHours       int
+	ItemHours       float64
 	ItemRate        int
-	ItemTotal       float64
+	// ItemTotal is the sum of all items, without VAT.
+	ItemTotal float64
+	Rounding  string
+	// Rates is the path to the rate table file. ItemRate is used if not set.
+	Rates string
+	// Register is the path to the invoice register. If set, issued invoices
+	// are recorded and numbered using NumberPattern.
+	Register      string
+	NumberPattern string
+	// GroupBy is the name of the key that worklog tasks are grouped by into
+	// invoice items. If empty, a single item is created.
+	GroupBy string
+	Items   []InvoiceItem
+	// Period is the first and the last day of the invoiced work.
+	Period      string
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	// Currency is the ISO 4217 code of the invoice currency, EUR by
+	// default. Locale is one of de (default), en, pl, fr or ch and selects
+	// the number format and the currency symbol placement.
+	Currency string
+	Locale   string
+	// ConvertTo is the currency that the total is additionally given in,
+	// using the exchange rate of the invoice date from the ExchangeRates
+	// file. Conversion is generated.
+	ConvertTo       string
+	ExchangeRates   string
+	Conversion      string
 	BottomNote      string
 	SignatureBase64 string
 	VATPaymentPerc  int
@@ -55,14 +103,55 @@ type TemplateContext struct {
 	Total           float64
 }
 
+// InvoiceItem is a single line of the invoice.
+type InvoiceItem struct {
+	// Description is html, so that it can be formatted, for example
+	// with a line break.
+	Description string
+	Quantity    float64
+	Unit        string
+	Rate        float64
+	Total       float64
+}
+
 func cmdInvoice(input io.Reader, output io.Writer, args []string) error {
 	fl := flag.NewFlagSet("invoice", flag.ContinueOnError)
-	confFl := fl.String("c", "config.txt", "Path to the configuration file.")
+	confFl := fl.String("c", "config.txt", "Path to the configuration file. Files with the .toml or .json extension are structured configurations with seller, bank, defaults and clients.<name> sections. YAML is not supported.")
+	clientFl := fl.String("client", "", "Name of the client profile of the structured configuration. Required if more than one client is defined.")
+	migrateFl := fl.Bool("migrate", false, "Convert the key = value configuration file to the TOML structured configuration, written to the output. The -client flag names the client profile.")
 	outFl := fl.String("o", "", "Output file. Stdout if not given.")
+	formatFl := fl.String("format", "", "Output format: html, pdf, ubl or cii (XRechnung) and zugferd (PDF/A-3 with embedded XRechnung). Detected from the output file extension if not given, html by default.")
 	exConfFl := fl.Bool("g", false, "Generate an example configuration file.")
+	ratesFl := fl.String("rates", "", "Path to the rate table file. Overrides the Rates configuration.")
+	dryFl := fl.Bool("dry", false, "Do not record the invoice in the register.")
+	templateFl := fl.String("template", "", "Path to the custom invoice template, used instead of the built-in html template. Run 'templates export invoice' to get a starting point.")
+	groupFl := fl.String("group", "", "Group tasks into invoice items by tag, project, week or word (the first word of the description). Overrides the GroupBy configuration.")
 	if err := fl.Parse(args); err != nil {
 		return fmt.Errorf("flag parse: %w", err)
 	}
+	format := *formatFl
+	if format == "" {
+		format = "html"
+		if strings.EqualFold(filepath.Ext(*outFl), ".pdf") {
+			format = "pdf"
+		}
+	}
+	render, ok := invoiceRenderers[format]
+	if !ok {
+		return errors.New("valid formats are html, pdf, ubl, cii and zugferd")
+	}
+	if *templateFl != "" {
+		if format != "html" {
+			return errors.New("-template can be used only with the html format")
+		}
+		custom, err := loadTemplate(*templateFl, templateFuncs(wlog.DefaultDurationStyle, nil))
+		if err != nil {
+			return err
+		}
+		render = func(w io.Writer, c TemplateContext) error {
+			return custom.Execute(w, c)
+		}
+	}
 
 	if *exConfFl {
 		fmt.Fprint(output, `
@@ -79,14 +168,74 @@ FromCountry       =
 FromTaxID         =
 FromEmail         =
 
+# Required by electronic invoices (ubl, cii and zugferd formats) only.
+# Country codes are two letter codes, for example DE. BuyerReference is the
+# reference provided by the client, for example the Leitweg-ID.
+FromVATID         =
+FromPhone         =
+FromCountryCode   =
+ToCountryCode     =
+ToEmail           =
+BuyerReference    =
+
 PaymentName       =
 PaymentIBAN       =
 PaymentBIC        =
 PaymentBankName   =
+PaymentTerms      = Payable within 14 days without deduction.
+
+# Add a GiroCode (EPC QR code) to the invoice, that can be scanned with a
+# banking app to pay the invoice. Requires PaymentName and PaymentIBAN.
+GiroCode          = false
+
+# Add the Swiss QR-bill payment part to the invoice. Requires a Swiss IBAN,
+# FromCountryCode, ToCountryCode and the CHF or EUR currency. The reference
+# is created from the invoice number, either QRR (requires a QR-IBAN) or
+# SCOR.
+QRBill            = false
+QRBillReference   =
+
+# Currency code, for example EUR, CHF or PLN. Locale is one of de, en, pl,
+# fr or ch and selects how amounts are written.
+Currency          = EUR
+Locale            = de
+
+# Give the total additionally in another currency, using the exchange rate
+# effective on the invoice date. Each line of the exchange rates file is the
+# day, both currency codes and the rate, for example:
+#   2021-11-30 EUR PLN 4.6360
+ConvertTo         =
+ExchangeRates     =
 
 ItemRate          = 100
+
+# Path to the rate table file with rates per tag, project or client, weekday
+# multipliers and rate changes. Tasks without a rate in the table are billed
+# using ItemRate, adjusted by the multipliers. For example:
+#   default 100
+#   default 110 from 2021-07-01
+#   @acme 120
+#   #rush x2
+#   weekend x1.5
+Rates             =
 ItemDescription   = Software development.
 
+# Rounding applied to each task duration before billing. Either "none" or
+# <mode>:<unit> where mode is up, down or nearest, for example up:15m.
+Rounding          = none
+
+# Group worklog tasks into separate invoice items by tag, project, week or
+# word (the first word of the task description). A single item described by
+# ItemDescription is created if not provided.
+GroupBy           =
+
+# Path to the invoice register, the WORKLOG_INVOICES environment variable is
+# used if not set. If set, each generated invoice is recorded and the invoice
+# number is allocated using NumberPattern. Pattern placeholders
+# are {year}, {month} and {seq}, sequence width can be given as {seq:04}.
+Register          =
+NumberPattern     = {year}-{seq:04}
+
 # Below entries are generated from the worklog if not provided.
 ItemHours         =
 InvoiceNumber     =
@@ -114,21 +263,114 @@ SignatureBase64   =
 	}
 	defer fd.Close()
 
-	if err := populateFromConfig(&tctx, fd); err != nil {
-		return fmt.Errorf("cannot read configuration: %w", err)
+	if isStructuredConfig(*confFl) {
+		doc, err := readStructuredConfig(*confFl, fd)
+		if err != nil {
+			return fmt.Errorf("cannot read configuration: %w", err)
+		}
+		if *migrateFl {
+			return fmt.Errorf("%s is already a structured configuration", *confFl)
+		}
+		if err := populateFromStructuredConfig(&tctx, doc, *clientFl); err != nil {
+			return fmt.Errorf("invalid configuration:\n%w", err)
+		}
+	} else {
+		if err := populateFromConfig(&tctx, fd); err != nil {
+			return fmt.Errorf("cannot read configuration: %w", err)
+		}
+		if *migrateFl {
+			return migrateConfig(output, &tctx, *confFl, *clientFl)
+		}
+		if *clientFl != "" {
+			return errors.New("-client requires a structured configuration file, convert it using -migrate")
+		}
+	}
+	tctx.Register = registerPath(tctx.Register)
+	if *groupFl != "" {
+		tctx.GroupBy = *groupFl
+	}
+	if *ratesFl != "" {
+		tctx.Rates = *ratesFl
+	}
+	var rates *wlog.RateTable
+	if tctx.Rates != "" {
+		if rates, err = readRateTable(tctx.Rates); err != nil {
+			return err
+		}
 	}
 
 	entries, err := wlog.Parse(input)
 	if err != nil {
 		return fmt.Errorf("parse log: %s", err)
 	}
-	if err := populateFromLog(&tctx, entries); err != nil {
+	numbered := tctx.InvoiceNumber != ""
+	if err := populateFromLog(&tctx, entries, rates); err != nil {
 		return fmt.Errorf("cannot interpred log: %w", err)
 	}
 
+	var (
+		reg    *invoiceRegister
+		record invoiceRecord
+	)
+	if tctx.Register != "" && !*dryFl {
+		if reg, err = openRegister(tctx.Register); err != nil {
+			return err
+		}
+		if !numbered {
+			day, err := time.Parse("2006-01-02", tctx.InvoiceDate)
+			if err != nil {
+				day = time.Now()
+			}
+			pattern := tctx.NumberPattern
+			if pattern == "" {
+				pattern = "{year}-{seq:04}"
+			}
+			tctx.InvoiceNumber, record.Seq, record.Scope, err = reg.NextNumber(pattern, day)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if tctx.ConvertTo != "" {
+		if err := populateConversion(&tctx); err != nil {
+			return fmt.Errorf("cannot convert total: %w", err)
+		}
+	}
+	if tctx.GiroCode {
+		if err := populateGiroCode(&tctx); err != nil {
+			return fmt.Errorf("cannot create GiroCode: %w", err)
+		}
+	}
+	if tctx.QRBill {
+		part, err := newQRBillPart(&tctx)
+		if err != nil {
+			return fmt.Errorf("cannot create QR-bill: %w", err)
+		}
+		tctx.QRBillPart = part
+	}
+
 	var b bytes.Buffer
-	if err := tmpl.Execute(&b, tctx); err != nil {
-		return fmt.Errorf("cannot render template: %w", err)
+	if err := render(&b, tctx); err != nil {
+		return fmt.Errorf("cannot render %s: %w", format, err)
+	}
+	if reg != nil {
+		record.Event = "issued"
+		record.Number = tctx.InvoiceNumber
+		record.Date = tctx.InvoiceDate
+		record.Client = tctx.ToCompany
+		record.From = entries[0].Day.Format("2006-01-02")
+		record.To = entries[len(entries)-1].Day.Format("2006-01-02")
+		record.Hours = tctx.ItemHours
+		record.Net = tctx.ItemTotal
+		record.VAT = tctx.VATTotal
+		record.Total = tctx.Total
+		record.Hash = fmt.Sprintf("sha256:%x", sha256.Sum256(b.Bytes()))
+		// Record the invoice before writing it, so that an invoice
+		// cannot exist without being registered.
+		if err := reg.Append(record); err != nil {
+			return fmt.Errorf("cannot register invoice: %w", err)
+		}
 	}
 	if *outFl == "" {
 		if _, err = b.WriteTo(output); err != nil {
@@ -142,50 +384,186 @@ SignatureBase64   =
 	return nil
 }
 
-func populateFromLog(c *TemplateContext, entries []*wlog.Entry) error {
+// invoiceRenderers maps the output format name to the function that writes
+// the invoice in that format.
+var invoiceRenderers = map[string]func(io.Writer, TemplateContext) error{
+	"html": func(w io.Writer, c TemplateContext) error {
+		return tmpl.Execute(w, c)
+	},
+	"pdf":     renderInvoicePDF,
+	"ubl":     renderInvoiceUBL,
+	"cii":     renderInvoiceCII,
+	"zugferd": renderInvoiceZUGFeRD,
+}
+
+// populateFromLog creates invoice items from the worklog entries. Each task is
+// billed using the rate from the rate table, or ItemRate if the table does not
+// define a rate for the task, see billTask. Rate table can be nil.
+func populateFromLog(c *TemplateContext, entries []*wlog.Entry, rates *wlog.RateTable) error {
+	if len(entries) == 0 {
+		return errors.New("no worklog entries")
+	}
+	rounding, err := wlog.ParseRounding(c.Rounding)
+	if err != nil {
+		return err
+	}
+	m, err := newMoney(c.Currency, c.Locale)
+	if err != nil {
+		return err
+	}
+
+	first, last := entries[0], entries[len(entries)-1]
+	c.Period = fmt.Sprintf("%s - %s", first.Day.Format("02.01.2006"), last.Day.Format("02.01.2006"))
+	c.PeriodStart, c.PeriodEnd = first.Day, last.Day
+
+	if c.GroupBy == "" && c.ItemHours != 0 {
+		// Billed hours are provided by the configuration.
+		c.ItemDescription += fmt.Sprintf("<br><em>(%s)</em>", c.Period)
+		c.Items = []InvoiceItem{{
+			Description: c.ItemDescription,
+			Quantity:    c.ItemHours,
+			Unit:        "h",
+			Rate:        float64(c.ItemRate),
+		}}
+	} else {
+		var groupKey func(*wlog.Entry, *wlog.Task) string
+		if c.GroupBy == "" {
+			c.ItemDescription += fmt.Sprintf("<br><em>(%s)</em>", c.Period)
+			groupKey = func(*wlog.Entry, *wlog.Task) string { return c.ItemDescription }
+		} else if fn, ok := invoiceGroups[c.GroupBy]; ok {
+			// Descriptions are html, names taken from the worklog
+			// are text.
+			groupKey = func(e *wlog.Entry, t *wlog.Task) string {
+				return html.EscapeString(fn(e, t))
+			}
+		} else {
+			return fmt.Errorf("cannot group by %q, valid groups are tag, project, week and word", c.GroupBy)
+		}
 
-	if c.ItemHours == 0 {
-		var total time.Duration
+		// Tasks of the same group billed with different rates are
+		// separate items.
+		type itemKey struct {
+			name string
+			rate float64
+		}
+		var (
+			items   []InvoiceItem
+			indexOf = make(map[itemKey]int)
+		)
 		for _, e := range entries {
-			total += e.TotalDuration()
+			for _, t := range e.Tasks {
+				rate, hours := billTask(rates, rounding, float64(c.ItemRate), e, t)
+				key := itemKey{name: groupKey(e, t), rate: rate}
+				i, ok := indexOf[key]
+				if !ok {
+					i = len(items)
+					indexOf[key] = i
+					items = append(items, InvoiceItem{
+						Description: key.name,
+						Unit:        "h",
+						Rate:        rate,
+					})
+				}
+				items[i].Quantity += hours
+			}
+		}
+		// Quantities are rounded to the four decimal places that
+		// invoices present, so that each line total is the presented
+		// quantity multiplied by the rate.
+		c.Items = items
+		c.ItemHours = 0
+		for i := range items {
+			items[i].Quantity = math.Round(items[i].Quantity*1e4) / 1e4
+			c.ItemHours += items[i].Quantity
 		}
-		c.ItemHours = int(total / time.Hour)
 	}
 
-	c.ItemTotal = float64(c.ItemHours * c.ItemRate)
+	// Amounts are rounded to the minor units of the currency once, so
+	// that all invoice formats present the same values and the totals
+	// are the sum of the presented lines.
+	c.ItemTotal = 0
+	for i := range c.Items {
+		c.Items[i].Total = m.round(c.Items[i].Quantity * c.Items[i].Rate)
+		c.ItemTotal += c.Items[i].Total
+	}
+	c.ItemTotal = m.round(c.ItemTotal)
 	if c.VATPaymentPerc > 0 {
-		c.VATTotal = c.ItemTotal * float64(c.VATPaymentPerc) / 100
+		c.VATTotal = m.round(c.ItemTotal * float64(c.VATPaymentPerc) / 100)
 	}
-	c.Total = c.ItemTotal + c.VATTotal
+	c.Total = m.round(c.ItemTotal + c.VATTotal)
 
-	last := entries[len(entries)-1]
 	if c.InvoiceDate == "" {
 		c.InvoiceDate = last.Day.Format("2006-01-02")
 	}
 	if c.InvoiceNumber == "" {
 		c.InvoiceNumber = last.Day.Format("2006-01-") + "01"
 	}
+	return nil
+}
 
-	first := entries[0]
-	c.ItemDescription += fmt.Sprintf("<br><em>(%s - %s)</em>",
-		first.Day.Format("02.01.2006"),
-		last.Day.Format("02.01.2006"),
-	)
+// readRateTable reads the rate table from the file.
+func readRateTable(path string) (*wlog.RateTable, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %q: %w", path, err)
+	}
+	defer fd.Close()
+	rates, err := wlog.ParseRateTable(fd)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read rate table: %w", err)
+	}
+	return rates, nil
+}
 
-	return nil
+// billTask returns the hourly rate and the billed hours of the task. The rate
+// comes from the rate table, or is the fallback rate if the table does not
+// define one, and is adjusted by matching multipliers. Hours are rounded
+// according to the rounding policy. Rate table can be nil.
+func billTask(rates *wlog.RateTable, rounding wlog.Rounding, fallback float64, e *wlog.Entry, t *wlog.Task) (float64, float64) {
+	return rates.Rate(e, t, fallback), rounding.Apply(t.Duration).Hours()
+}
+
+// invoiceGroups maps the group name to a function that returns the name of
+// the invoice item a task belongs to.
+var invoiceGroups = map[string]func(e *wlog.Entry, t *wlog.Task) string{
+	"tag": func(e *wlog.Entry, t *wlog.Task) string {
+		if len(t.Tags) == 0 {
+			return "Other"
+		}
+		return t.Tags[0]
+	},
+	"project": func(e *wlog.Entry, t *wlog.Task) string {
+		if t.Project == "" {
+			return "Other"
+		}
+		return t.Project
+	},
+	"week": func(e *wlog.Entry, t *wlog.Task) string {
+		year, week := e.Day.ISOWeek()
+		return fmt.Sprintf("Week %d-W%02d", year, week)
+	},
+	"word": func(e *wlog.Entry, t *wlog.Task) string {
+		words := strings.Fields(t.Description)
+		if len(words) == 0 {
+			return "Other"
+		}
+		return strings.ToLower(strings.Trim(words[0], ".,;:!?"))
+	},
 }
 
 func populateFromConfig(s interface{}, r io.Reader) error {
 	v := reflect.ValueOf(s).Elem()
 
 	rd := bufio.NewReader(r)
-	for {
+	for lineNo := 1; ; lineNo++ {
 		line, err := rd.ReadString('\n')
 		switch err {
 		case nil:
 			// All good.
 		case io.EOF:
-			return nil
+			if line == "" {
+				return nil
+			}
 		default:
 			return fmt.Errorf("read string: %w", err)
 		}
@@ -200,29 +578,42 @@ func populateFromConfig(s interface{}, r io.Reader) error {
 		}
 
 		chunks := strings.SplitN(line, "=", 2)
+		if len(chunks) != 2 {
+			return fmt.Errorf("line %d: expected <name> = <value>, got %q", lineNo, line)
+		}
 		name := strings.TrimSpace(chunks[0])
 
 		value := strings.TrimSpace(chunks[1])
 		value = strings.ReplaceAll(value, "\\n", "\n")
+		if value == "" {
+			// Not provided, keep the zero value.
+			continue
+		}
 
 		field := v.FieldByName(name)
 		if !field.IsValid() || !field.CanSet() {
-			return fmt.Errorf("cannot set %q field value", name)
+			return fmt.Errorf("line %d: cannot set %q field value", lineNo, name)
 		}
 
 		switch field.Kind() {
 		case reflect.Int, reflect.Int64, reflect.Int32:
 			n, err := strconv.ParseInt(value, 10, 64)
 			if err != nil {
-				return fmt.Errorf("value of %q is not a valid number: %w", name, err)
+				return fmt.Errorf("line %d: value of %q is not a valid number: %w", lineNo, name, err)
 			}
 			field.SetInt(n)
+		case reflect.Float64, reflect.Float32:
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("line %d: value of %q is not a valid number: %w", lineNo, name, err)
+			}
+			field.SetFloat(n)
 		case reflect.String:
 			field.SetString(value)
 		case reflect.Bool:
 			v, err := strconv.ParseBool(value)
 			if err != nil {
-				return fmt.Errorf("%q field value is not a valid boolean: %w", name, err)
+				return fmt.Errorf("line %d: %q field value is not a valid boolean: %w", lineNo, name, err)
 			}
 			field.SetBool(v)
 		}
@@ -230,29 +621,42 @@ func populateFromConfig(s interface{}, r io.Reader) error {
 	}
 }
 
-// prettyFormatNumberDE does a naive dot separation.
+// Money returns the amount in the invoice currency, formatted using the
+// invoice locale.
+func (c TemplateContext) Money(amount float64) string {
+	m, err := newMoney(c.Currency, c.Locale)
+	if err != nil {
+		// Currency and locale are validated before rendering.
+		m, _ = newMoney("", "")
+	}
+	return m.format(amount)
+}
+
+// DescriptionHTML returns the item description for the html template.
+// Names taken from the worklog are escaped when the item is created, see
+// populateFromLog.
+func (it InvoiceItem) DescriptionHTML() template.HTML {
+	return template.HTML(it.Description)
+}
+
+// Quantity returns the item quantity formatted using the invoice locale.
+func (c TemplateContext) Quantity(q float64) string {
+	m, err := newMoney(c.Currency, c.Locale)
+	if err != nil {
+		// Currency and locale are validated before rendering.
+		m, _ = newMoney("", "")
+	}
+	return m.locale.formatQuantity(q)
+}
+
+// prettyFormatNumberDE formats the number using the German locale. Floats
+// are written with two decimal places.
 func prettyFormatNumberDE(n interface{}) string {
 	switch n := n.(type) {
 	case int:
-		res := strconv.Itoa(n)
-		for i := 3; i < len(res); i += 3 {
-			res = res[:len(res)-i] + "." + res[len(res)-i:]
-			i++ // Extra dot.
-		}
-		return res + ",-"
+		return locales["de"].formatNumber(float64(n), 0)
 	case float64:
-		i := math.Trunc(n)
-		res := strconv.Itoa(int(i))
-		for i := 3; i < len(res); i += 3 {
-			res = res[:len(res)-i] + "." + res[len(res)-i:]
-			i++ // Extra dot.
-		}
-		if d := int(math.Ceil((n - i) * 100)); d > 0 {
-			res = fmt.Sprintf("%s,%0.2d", res, d)
-		} else {
-			res += ",-"
-		}
-		return res
+		return locales["de"].formatNumber(n, 2)
 	default:
 		panic("unsupported type")
 	}
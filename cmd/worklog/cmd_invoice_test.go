@@ -1,6 +1,12 @@
 package main
 
-import "testing"
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/husio/worklog/wlog"
+)
 
 func TestPrettyFormatNumberDE(t *testing.T) {
 	cases := map[string]struct {
@@ -46,3 +52,195 @@ func TestPrettyFormatNumberDE(t *testing.T) {
 		})
 	}
 }
+
+func TestPopulateFromLogGroups(t *testing.T) {
+	entries, err := wlog.Parse(strings.NewReader(`# 1 Nov 2021 Monday
+8h Fix login +shop #bug
+1h10m review +api
+
+# 9 Nov 2021 Tuesday
+4h fix checkout +shop #bug
+20m meeting
+`))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	cases := map[string][]InvoiceItem{
+		"project": {
+			{Description: "shop", Quantity: 12, Unit: "h", Rate: 100, Total: 1200},
+			{Description: "api", Quantity: 1.25, Unit: "h", Rate: 100, Total: 125},
+			{Description: "Other", Quantity: 0.5, Unit: "h", Rate: 100, Total: 50},
+		},
+		"week": {
+			{Description: "Week 2021-W44", Quantity: 9.25, Unit: "h", Rate: 100, Total: 925},
+			{Description: "Week 2021-W45", Quantity: 4.5, Unit: "h", Rate: 100, Total: 450},
+		},
+		"word": {
+			{Description: "fix", Quantity: 12, Unit: "h", Rate: 100, Total: 1200},
+			{Description: "review", Quantity: 1.25, Unit: "h", Rate: 100, Total: 125},
+			{Description: "meeting", Quantity: 0.5, Unit: "h", Rate: 100, Total: 50},
+		},
+	}
+	for group, want := range cases {
+		t.Run(group, func(t *testing.T) {
+			c := TemplateContext{ItemRate: 100, Rounding: "up:15m", GroupBy: group, VATPaymentPerc: 10}
+			if err := populateFromLog(&c, entries, nil); err != nil {
+				t.Fatalf("populate: %s", err)
+			}
+			if len(c.Items) != len(want) {
+				t.Fatalf("want %d items, got %+v", len(want), c.Items)
+			}
+			for i, w := range want {
+				if c.Items[i] != w {
+					t.Errorf("item %d: want %+v, got %+v", i, w, c.Items[i])
+				}
+			}
+			if c.ItemTotal != 1375 || c.Total != 1512.5 {
+				t.Errorf("unexpected totals: %v, %v", c.ItemTotal, c.Total)
+			}
+		})
+	}
+}
+
+func TestPopulateFromLogRates(t *testing.T) {
+	entries, err := wlog.Parse(strings.NewReader(`# 5 Nov 2021 Friday
+2h a +shop
+1h b @acme
+
+# 6 Nov 2021 Saturday
+1h c +shop
+1h d
+`))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	rates, err := wlog.ParseRateTable(strings.NewReader("+shop 120\nweekend x1.5"))
+	if err != nil {
+		t.Fatalf("parse rates: %s", err)
+	}
+	c := TemplateContext{ItemRate: 100, GroupBy: "project"}
+	if err := populateFromLog(&c, entries, rates); err != nil {
+		t.Fatalf("populate: %s", err)
+	}
+	want := []InvoiceItem{
+		{Description: "shop", Quantity: 2, Unit: "h", Rate: 120, Total: 240},
+		{Description: "Other", Quantity: 1, Unit: "h", Rate: 100, Total: 100},
+		{Description: "shop", Quantity: 1, Unit: "h", Rate: 180, Total: 180},
+		{Description: "Other", Quantity: 1, Unit: "h", Rate: 150, Total: 150},
+	}
+	if len(c.Items) != len(want) {
+		t.Fatalf("want %d items, got %+v", len(want), c.Items)
+	}
+	for i, w := range want {
+		if c.Items[i] != w {
+			t.Errorf("item %d: want %+v, got %+v", i, w, c.Items[i])
+		}
+	}
+	if c.Total != 670 {
+		t.Errorf("want 670 total, got %v", c.Total)
+	}
+}
+
+func TestPopulateFromLogRoundsAmounts(t *testing.T) {
+	entries, err := wlog.Parse(strings.NewReader(`# 1 Nov 2021 Monday
+20m a +one
+20m b +two
+20m c +three
+`))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	c := TemplateContext{ItemRate: 100, GroupBy: "project", VATPaymentPerc: 19}
+	if err := populateFromLog(&c, entries, nil); err != nil {
+		t.Fatalf("populate: %s", err)
+	}
+	for i, it := range c.Items {
+		if it.Total != 33.33 {
+			t.Errorf("item %d: want 33.33 total, got %v", i, it.Total)
+		}
+	}
+	// Totals are the sums of the rounded amounts.
+	if c.ItemTotal != 99.99 || c.VATTotal != 19 || c.Total != 118.99 {
+		t.Errorf("unexpected totals: %v, %v, %v", c.ItemTotal, c.VATTotal, c.Total)
+	}
+}
+
+func TestInvoiceHTMLQuantity(t *testing.T) {
+	entries, err := wlog.Parse(strings.NewReader("# 1 Nov 2021 Monday\n2h50m coding\n"))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	c := TemplateContext{ItemRate: 100, GroupBy: "word", Locale: "de"}
+	if err := populateFromLog(&c, entries, nil); err != nil {
+		t.Fatalf("populate: %s", err)
+	}
+	var b bytes.Buffer
+	if err := invoiceRenderers["html"](&b, c); err != nil {
+		t.Fatalf("render: %s", err)
+	}
+	// Line total is the presented quantity multiplied by the rate.
+	for _, want := range []string{">2,8333<", ">" + c.Money(283.33) + "<"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("document does not contain %s", want)
+		}
+	}
+}
+
+func TestInvoiceHTMLEscapesWorklogNames(t *testing.T) {
+	entries, err := wlog.Parse(strings.NewReader("# 1 Nov 2021 Monday\n2h <script>alert(1)</script> review\n"))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	c := TemplateContext{ItemRate: 100, GroupBy: "word", ToCompany: "R&D <Ltd>"}
+	if err := populateFromLog(&c, entries, nil); err != nil {
+		t.Fatalf("populate: %s", err)
+	}
+	var b bytes.Buffer
+	if err := invoiceRenderers["html"](&b, c); err != nil {
+		t.Fatalf("render: %s", err)
+	}
+	if strings.Contains(b.String(), "<script>") {
+		t.Fatalf("worklog content is not escaped\n%s", b.String())
+	}
+	for _, want := range []string{"<td>&lt;script&gt;alert(1)&lt;/script&gt;</td>", "<td>R&amp;D &lt;Ltd&gt;</td>"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("document does not contain %s", want)
+		}
+	}
+	// Text formats present the name as written.
+	if got := htmlToText(c.Items[0].Description); got != "<script>alert(1)</script>" {
+		t.Errorf("unexpected text description %q", got)
+	}
+}
+
+func TestRenderInvoicePDF(t *testing.T) {
+	c := TemplateContext{
+		InvoiceNumber: "2021-0001",
+		ToCompany:     "ACME",
+		ToAddress:     "Main Street 1\n12345 Berlin",
+		Items: []InvoiceItem{
+			{Description: "Software development.<br><em>(01.11.2021 - 30.11.2021)</em>", Quantity: 10, Unit: "h", Rate: 100, Total: 1000},
+		},
+		ItemTotal: 1000,
+		Total:     1000,
+	}
+	var b bytes.Buffer
+	if err := renderInvoicePDF(&b, c); err != nil {
+		t.Fatalf("render: %s", err)
+	}
+	if !bytes.HasPrefix(b.Bytes(), []byte("%PDF-")) {
+		t.Fatalf("not a pdf document: %q", b.Bytes()[:10])
+	}
+
+	c.SignatureBase64 = "not base64"
+	if err := renderInvoicePDF(&b, c); err == nil {
+		t.Fatal("want invalid signature error")
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	got := htmlToText("Development<br><em>(01.11.2021 - 30.11.2021)</em> &amp; support")
+	if want := "Development\n(01.11.2021 - 30.11.2021) & support"; got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
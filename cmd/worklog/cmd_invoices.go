@@ -0,0 +1,153 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/husio/worklog/wlog"
+)
+
+func cmdInvoices(input io.Reader, output io.Writer, args []string) error {
+	fl := flag.NewFlagSet("invoices", flag.ContinueOnError)
+	registerFl := fl.String("r", "", "Path to the invoice register file. Overrides the Register configuration.")
+	confFl := fl.String("c", "config.txt", "Path to the invoice configuration file, that the register path is read from.")
+	clientFl := fl.String("client", "", "Name of the client profile of the structured configuration.")
+	openFl := fl.Bool("open", false, "List only invoices that are not paid nor void.")
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), "Usage: invoices [<flags>] list|show|mark-paid|void [<args>]")
+		fmt.Fprint(fl.Output(), `
+Manage the register of issued invoices.
+
+	list                          List all invoices and outstanding total.
+	show <number>                 Show invoice details.
+	mark-paid <number> [<date>]   Mark invoice as paid, today by default.
+	void <number> [<reason>]      Void the invoice. Its number is not reused.
+
+The register is found the same way as by the invoice command, using the
+Register value of the configuration, or the WORKLOG_INVOICES environment
+variable if it is not set.
+`)
+		fl.PrintDefaults()
+	}
+	if err := fl.Parse(args); err != nil {
+		return fmt.Errorf("flag parse: %w", err)
+	}
+	if len(fl.Args()) == 0 {
+		fl.Usage()
+		return fmt.Errorf("missing subcommand")
+	}
+	path := *registerFl
+	if path == "" {
+		var err error
+		if path, err = configuredRegister(*confFl, *clientFl); err != nil {
+			return err
+		}
+		if path == "" {
+			return fmt.Errorf("invoice register is not configured, set Register in %s or WORKLOG_INVOICES, or use -r", *confFl)
+		}
+	}
+	reg, err := openRegister(path)
+	if err != nil {
+		return err
+	}
+	sub, rest := fl.Arg(0), fl.Args()[1:]
+
+	switch sub {
+	case "list":
+		wr := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(wr, "NUMBER\tDATE\tCLIENT\tPERIOD\tTOTAL\tSTATUS")
+		var (
+			outstanding float64
+			open        int
+		)
+		for _, inv := range reg.invoices {
+			if inv.Status() == "open" {
+				outstanding += inv.Total
+				open++
+			} else if *openFl {
+				continue
+			}
+			status := inv.Status()
+			if inv.PaidDate != "" {
+				status += " " + inv.PaidDate
+			}
+			fmt.Fprintf(wr, "%s\t%s\t%s\t%s..%s\t%s\t%s\n",
+				inv.Number, inv.Date, inv.Client, inv.From, inv.To, formatMoney(inv.Total), status)
+		}
+		fmt.Fprintf(wr, "outstanding\t\t\t%d invoices\t%s\t\n", open, formatMoney(outstanding))
+		return wr.Flush()
+	case "show":
+		if len(rest) != 1 {
+			return fmt.Errorf("usage: invoices show <number>")
+		}
+		inv := reg.Find(rest[0])
+		if inv == nil {
+			return fmt.Errorf("invoice %s not found", rest[0])
+		}
+		wr := tabwriter.NewWriter(output, 0, 4, 1, ' ', 0)
+		writeInvoiceDetails(wr, inv)
+		return wr.Flush()
+	case "mark-paid":
+		if len(rest) < 1 || len(rest) > 2 {
+			return fmt.Errorf("usage: invoices mark-paid <number> [<date>]")
+		}
+		paid := time.Now().Format("2006-01-02")
+		if len(rest) == 2 {
+			day, err := wlog.ParseDate(rest[1])
+			if err != nil {
+				return err
+			}
+			paid = day.Format("2006-01-02")
+		}
+		return reg.Append(invoiceRecord{Event: "paid", Number: rest[0], PaidDate: paid})
+	case "void":
+		if len(rest) < 1 {
+			return fmt.Errorf("usage: invoices void <number> [<reason>]")
+		}
+		return reg.Append(invoiceRecord{Event: "void", Number: rest[0], Reason: strings.Join(rest[1:], " ")})
+	default:
+		return fmt.Errorf("unknown subcommand %q, valid subcommands are list, show, mark-paid and void", sub)
+	}
+}
+
+// registerPath returns the path of the invoice register. The path set in
+// the invoice configuration takes precedence over the WORKLOG_INVOICES
+// environment variable. Empty path means invoices are not registered.
+func registerPath(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	return os.Getenv("WORKLOG_INVOICES")
+}
+
+// configuredRegister returns the path of the invoice register, resolved the
+// same way as by the invoice command. Missing configuration file is ignored.
+func configuredRegister(confPath, client string) (string, error) {
+	fd, err := os.Open(confPath)
+	if os.IsNotExist(err) {
+		return registerPath(""), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot open %q: %w", confPath, err)
+	}
+	defer fd.Close()
+
+	var c TemplateContext
+	if isStructuredConfig(confPath) {
+		doc, err := readStructuredConfig(confPath, fd)
+		if err != nil {
+			return "", fmt.Errorf("cannot read configuration: %w", err)
+		}
+		if err := populateFromStructuredConfig(&c, doc, client); err != nil {
+			return "", fmt.Errorf("invalid configuration:\n%w", err)
+		}
+	} else if err := populateFromConfig(&c, fd); err != nil {
+		return "", fmt.Errorf("cannot read configuration: %w", err)
+	}
+	return registerPath(c.Register), nil
+}
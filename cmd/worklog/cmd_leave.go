@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/husio/worklog/wlog"
+)
+
+func cmdLeave(input io.Reader, output io.Writer, args []string) error {
+	fl := flag.NewFlagSet("leave", flag.ContinueOnError)
+	allowanceFl := fl.Float64("allowance", 0, "Number of vacation days available in a year. Remaining days are not shown if not set.")
+	carryFl := fl.Float64("carry", 0, "Number of vacation days carried over from the previous year.")
+	verboseFl := fl.Bool("v", false, "List all days off.")
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), "Usage: leave [<flags>] [<year>]")
+		fmt.Fprint(fl.Output(), `
+Show vacation days used and remaining in a year, as well as sick days and
+public holidays. By default the current year is shown. Days off are marked in
+the day header:
+
+	# 24 Dec 2021 Friday [vacation]
+	# 27 Dec 2021 Monday [sick]
+	# 1 Jan 2022 Saturday [holiday]
+	# 3 Jan 2022 Monday [half-day]
+`)
+		fl.PrintDefaults()
+	}
+	if err := fl.Parse(args); err != nil {
+		return fmt.Errorf("flag parse: %w", err)
+	}
+	year := time.Now().Year()
+	switch len(fl.Args()) {
+	case 0:
+	case 1:
+		y, err := strconv.Atoi(fl.Arg(0))
+		if err != nil {
+			return fmt.Errorf("invalid year %q", fl.Arg(0))
+		}
+		year = y
+	default:
+		return fmt.Errorf("too many arguments")
+	}
+
+	entries, err := wlog.Parse(input)
+	if err != nil {
+		return fmt.Errorf("parse log: %s", err)
+	}
+
+	var (
+		vacation float64
+		days     = make(map[wlog.DayStatus]int)
+		marked   []*wlog.Entry
+	)
+	for _, e := range entries {
+		if e.Day.Year() != year || e.Status == wlog.StatusWork {
+			continue
+		}
+		vacation += e.Status.Vacation()
+		days[e.Status]++
+		marked = append(marked, e)
+	}
+
+	wr := tabwriter.NewWriter(output, 0, 4, 1, ' ', 0)
+	if *verboseFl {
+		for _, e := range marked {
+			fmt.Fprintf(wr, "%s\t%s\t%s\n", e.Day.Format("2006-01-02"), e.Day.Format("Mon"), e.Status)
+		}
+		fmt.Fprint(wr, "\n")
+	}
+	fmt.Fprintf(wr, "year\t%d\n", year)
+	fmt.Fprintf(wr, "vacation\t%s\n", formatFloat(vacation))
+	fmt.Fprintf(wr, "half-days\t%d\n", days[wlog.StatusHalfDay])
+	fmt.Fprintf(wr, "sick\t%d\n", days[wlog.StatusSick])
+	fmt.Fprintf(wr, "holidays\t%d\n", days[wlog.StatusHoliday])
+	if *allowanceFl > 0 || *carryFl > 0 {
+		available := *allowanceFl + *carryFl
+		fmt.Fprintf(wr, "allowance\t%s\n", formatFloat(available))
+		fmt.Fprintf(wr, "remaining\t%s\n", formatFloat(available-vacation))
+	}
+	return wr.Flush()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/husio/worklog/wlog"
+)
+
+func cmdLint(input io.Reader, output io.Writer, args []string) error {
+	fl := flag.NewFlagSet("lint", flag.ContinueOnError)
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), "Usage: lint [<file>...]")
+		fmt.Fprintln(fl.Output(), "\nReport problems found in the worklog. If no files are given, the worklog is read.")
+		fl.PrintDefaults()
+	}
+	if err := fl.Parse(args); err != nil {
+		return fmt.Errorf("flag parse: %w", err)
+	}
+
+	var problems int
+	if len(fl.Args()) == 0 {
+		n, err := lintWorklog(output, inputName(input), input)
+		if err != nil {
+			return err
+		}
+		problems += n
+	}
+	for _, path := range fl.Args() {
+		fd, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("open %q: %w", path, err)
+		}
+		n, err := lintWorklog(output, path, fd)
+		fd.Close()
+		if err != nil {
+			return err
+		}
+		problems += n
+	}
+
+	switch problems {
+	case 0:
+		return nil
+	case 1:
+		return errors.New("1 problem found")
+	default:
+		return fmt.Errorf("%d problems found", problems)
+	}
+}
+
+// lintWorklog writes all problems found in the worklog content to the
+// output and returns their count.
+func lintWorklog(output io.Writer, name string, r io.Reader) (int, error) {
+	entries, err := wlog.ParseStrict(r)
+	var diags wlog.Diagnostics
+	if err != nil && !errors.As(err, &diags) {
+		return 0, fmt.Errorf("parse %s: %w", name, err)
+	}
+	diags = append(diags, wlog.Lint(entries, time.Now())...)
+	diags.Sort()
+
+	for _, d := range diags {
+		if _, err := fmt.Fprintf(output, "%s:%d:%d: %s\n", name, d.Line, d.Col, d.Message); err != nil {
+			return 0, err
+		}
+	}
+	return len(diags), nil
+}
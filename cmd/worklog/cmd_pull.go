@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/husio/worklog/wlog"
+)
+
+func cmdPull(input io.Reader, output io.Writer, args []string) error {
+	fl := flag.NewFlagSet("pull", flag.ContinueOnError)
+	urlFl := fl.String("url", "", "Worklog storage URL.")
+	tokenFl := fl.String("token", "", "Worklog storage read token. By default WORKLOG_TOKEN.")
+	stateFl := fl.String("state", "", "Path to the sync state file. By default a hidden file next to the worklog.")
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), "Usage: pull [<flags>]")
+		fmt.Fprint(fl.Output(), `
+Merge changes of the worklog kept by the storage server into the local
+worklog file. Both are compared with the revision of the last push or pull,
+remembered in the sync state file. Changes of different days, or different
+tasks of the same day, are merged. Days that were not changed remotely are
+kept as written, together with notes outside of days. Conflicting changes
+are written to the worklog between conflict markers, local version first:
+
+	<<<<<<< local
+	2h code review
+	=======
+	3h code review
+	>>>>>>> remote
+
+Keep the right version, remove the markers and push the worklog. The lint
+command reports unresolved conflicts.
+
+`)
+		fl.PrintDefaults()
+	}
+	if err := fl.Parse(args); err != nil {
+		return fmt.Errorf("flag parse: %w", err)
+	}
+	if *urlFl == "" {
+		return fmt.Errorf("\"url\" not provided")
+	}
+
+	name := inputName(input)
+	if name == "<stdin>" || name == "<input>" || isURL(name) {
+		return fmt.Errorf("cannot pull into %s, only a local worklog file can be written", name)
+	}
+	content, err := ioutil.ReadAll(input)
+	if err != nil {
+		return fmt.Errorf("read worklog: %w", err)
+	}
+	remote, err := newRemoteWorklog(*urlFl, *tokenFl)
+	if err != nil {
+		return err
+	}
+	statePath := *stateFl
+	if statePath == "" {
+		if statePath, err = defaultSyncStatePath(name); err != nil {
+			return err
+		}
+	}
+	state, err := readSyncState(statePath)
+	if err != nil {
+		return fmt.Errorf("read sync state: %w", err)
+	}
+
+	merged, rev, conflicts, err := pullWorklog(context.Background(), output, remote, state[remote.stateKey()], content)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(merged, content) {
+		if err := writeFileAtomic(name, merged); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+	if rev != "" {
+		state[remote.stateKey()] = rev
+		if err := writeSyncState(statePath, state); err != nil {
+			return fmt.Errorf("write sync state: %w", err)
+		}
+	}
+	return conflictsError(conflicts, name)
+}
+
+// pullWorklog merges the current remote worklog into the local worklog
+// content, using the base revision as the common ancestor. Merge result is
+// reported to the output. It returns the merged content, which is the
+// local content if there is nothing to merge, the remote revision and the
+// number of conflicts.
+func pullWorklog(ctx context.Context, output io.Writer, remote *remoteWorklog, base string, content []byte) ([]byte, string, int, error) {
+	file, err := wlog.ParseFile(bytes.NewReader(content))
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("parse log: %w", err)
+	}
+	if file.HasConflicts() {
+		return nil, "", 0, errors.New("worklog has unresolved merge conflicts, run lint to find them")
+	}
+
+	remoteContent, rev, err := remote.current(ctx)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("remote worklog: %w", err)
+	}
+	if rev == "" {
+		// Nothing was pushed yet.
+		return content, "", 0, nil
+	}
+	if rev == base {
+		fmt.Fprintln(output, "Already up to date.")
+		return content, rev, 0, nil
+	}
+	remoteFile, err := wlog.ParseFile(bytes.NewReader(remoteContent))
+	if err == nil {
+		err = remoteFile.CheckConflicts()
+	}
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("parse remote worklog: %w", err)
+	}
+	// Local text takes precedence, so that the local formatting of tasks
+	// that were not changed is kept.
+	text := newMergeText()
+	local := text.add(file)
+	remoteLog := text.add(remoteFile)
+	var baseEntries []*wlog.Entry
+	if base != "" {
+		if baseEntries, err = remoteEntries(ctx, remote, base); err != nil {
+			return nil, "", 0, err
+		}
+	}
+
+	days, conflicts := mergeEntries(baseEntries, local, remoteLog)
+	merged := formatMerged(file, days, text, wlog.DefaultDurationStyle)
+	if conflicts > 0 {
+		for _, d := range days {
+			if d.conflict || hasConflictingTasks(d) {
+				day := d.entry
+				if day == nil {
+					day = d.local
+				}
+				if day == nil {
+					day = d.remote
+				}
+				fmt.Fprintf(output, "conflict %s\n", day.Day.Format(wlog.TimeFormat))
+			}
+		}
+		return merged, rev, conflicts, nil
+	}
+
+	mergedEntries, err := wlog.Parse(bytes.NewReader(merged))
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("parse merged worklog: %w", err)
+	}
+	n, err := writeEntriesDiff(output, local, mergedEntries, wlog.DefaultDurationStyle)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("write changes: %w", err)
+	}
+	if n == 0 {
+		// Keep the local formatting if the remote brings no changes.
+		fmt.Fprintln(output, "Already up to date.")
+		return content, rev, 0, nil
+	}
+	return merged, rev, 0, nil
+}
+
+// conflictsError returns the error reporting unresolved conflicts, or nil
+// if there are none.
+func conflictsError(conflicts int, name string) error {
+	switch conflicts {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("1 conflict, resolve it in %s and push", name)
+	default:
+		return fmt.Errorf("%d conflicts, resolve them in %s and push", conflicts, name)
+	}
+}
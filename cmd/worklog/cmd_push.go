@@ -3,10 +3,10 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"net/http"
 
 	"github.com/husio/worklog/wlog"
 )
@@ -15,6 +15,19 @@ func cmdPush(input io.Reader, output io.Writer, args []string) error {
 	fl := flag.NewFlagSet("push", flag.ContinueOnError)
 	urlFl := fl.String("url", "", "Worklog storage URL.")
 	tokenFl := fl.String("token", "", "Worklog storage write token,")
+	forceFl := fl.Bool("force", false, "Overwrite the remote worklog even if it was changed since the last push.")
+	stateFl := fl.String("state", "", "Path to the sync state file. By default a hidden file next to the worklog.")
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), "Usage: push [<flags>]")
+		fmt.Fprint(fl.Output(), `
+Upload the worklog to the storage server. The revision of the last push is
+remembered in the sync state file and the push is rejected if the remote
+worklog was changed since, for example by a push from another machine. The
+rejected push reports the remote changes that would be lost.
+
+`)
+		fl.PrintDefaults()
+	}
 	if err := fl.Parse(args); err != nil {
 		return fmt.Errorf("flag parse: %w", err)
 	}
@@ -25,33 +38,86 @@ func cmdPush(input io.Reader, output io.Writer, args []string) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	var body bytes.Buffer
-	if entries, err := wlog.Parse(input); err != nil {
+	file, err := wlog.ParseFile(input)
+	if err != nil {
 		return fmt.Errorf("parse log: %s", err)
-	} else if err := wlog.ToText(&body, entries); err != nil {
+	}
+	if file.HasConflicts() {
+		return errors.New("worklog has unresolved merge conflicts, run lint to find them")
+	}
+	entries := file.Entries()
+	var body bytes.Buffer
+	if err := wlog.ToText(&body, entries); err != nil {
 		return fmt.Errorf("format to text: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "PUT", *urlFl, &body)
+	// Write token grants read access too, which is needed to report
+	// conflicts.
+	remote, err := newRemoteWorklog(*urlFl, *tokenFl)
 	if err != nil {
-		return fmt.Errorf("new request: %w", err)
+		return err
 	}
-	req.Header.Set("write-token", *tokenFl)
-	req.Header.Set("content-type", "text/plain")
-
-	resp, err := http.DefaultClient.Do(req)
+	statePath := *stateFl
+	if statePath == "" {
+		if statePath, err = defaultSyncStatePath(inputName(input)); err != nil {
+			return err
+		}
+	}
+	state, err := readSyncState(statePath)
 	if err != nil {
-		return fmt.Errorf("http PUT: %w", err)
+		return fmt.Errorf("read sync state: %w", err)
 	}
-	defer resp.Body.Close()
+	base := state[remote.stateKey()]
 
-	if resp.StatusCode == http.StatusUnauthorized {
-		return fmt.Errorf("forbidden: invalid push token")
+	rev, err := remote.put(ctx, bytes.NewReader(body.Bytes()), *tokenFl, base, *forceFl)
+	var conflict *conflictError
+	switch {
+	case errors.As(err, &conflict) && conflict.Remote == revisionHash(body.Bytes()):
+		// Remote worklog already has the same content.
+		rev = conflict.Remote
+	case errors.As(err, &conflict):
+		if err := writeConflictReport(ctx, output, remote, base, conflict.Remote, entries); err != nil {
+			return fmt.Errorf("conflict report: %w", err)
+		}
+		return errors.New("push rejected: remote worklog has conflicting changes")
+	case err != nil:
+		return err
 	}
-	if resp.StatusCode > 204 {
-		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1e5))
-		return fmt.Errorf("http response: %d %s", resp.StatusCode, string(b))
+
+	state[remote.stateKey()] = rev
+	if err := writeSyncState(statePath, state); err != nil {
+		return fmt.Errorf("write sync state: %w", err)
+	}
+	return nil
+}
+
+// writeConflictReport writes the changes of the remote worklog, that a
+// push would overwrite. If the base revision is known, changes made
+// remotely since the base are reported. Otherwise the remote worklog is
+// compared with the local one.
+func writeConflictReport(ctx context.Context, w io.Writer, remote *remoteWorklog, base, remoteRev string, local []*wlog.Entry) error {
+	current, err := remoteEntries(ctx, remote, "")
+	if err != nil {
+		return err
 	}
+	style := wlog.DefaultDurationStyle
 
+	if base != "" {
+		if baseEntries, err := remoteEntries(ctx, remote, base); err == nil {
+			fmt.Fprintf(w, "Remote worklog was changed since the last push at revision %s.\n", shortRevision(base))
+			fmt.Fprintf(w, "Changes of the remote revision %s:\n\n", shortRevision(remoteRev))
+			if _, err := writeEntriesDiff(w, baseEntries, current, style); err != nil {
+				return err
+			}
+			fmt.Fprintln(w, "\nRun pull to merge the remote changes or push with -force to overwrite them.")
+			return nil
+		}
+	}
+	fmt.Fprintf(w, "Remote worklog at revision %s was not pushed from this worklog.\n", shortRevision(remoteRev))
+	fmt.Fprintf(w, "Differences between the local and the remote worklog:\n\n")
+	if _, err := writeEntriesDiff(w, local, current, style); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "\nRun pull to merge the remote changes or push with -force to overwrite them.")
 	return nil
 }
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+)
+
+func cmdRestore(input io.Reader, output io.Writer, args []string) error {
+	fl := flag.NewFlagSet("restore", flag.ContinueOnError)
+	urlFl := fl.String("url", "", "Worklog storage URL. By default WORKLOG, if it is a storage server URL.")
+	tokenFl := fl.String("token", "", "Worklog storage write token.")
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), "Usage: restore [<flags>] <rev>")
+		fmt.Fprint(fl.Output(), `
+Make given revision of the worklog kept by the storage server the current
+one. The restored content is pushed as a new revision, so that the history
+is never lost and restore can be undone.
+
+`)
+		fl.PrintDefaults()
+	}
+	if err := fl.Parse(args); err != nil {
+		return fmt.Errorf("flag parse: %w", err)
+	}
+	if fl.NArg() != 1 {
+		fl.Usage()
+		return fmt.Errorf("expected a revision")
+	}
+	if *tokenFl == "" {
+		return fmt.Errorf("\"token\" not provided")
+	}
+
+	// Write token grants read access too.
+	remote, err := newRemoteWorklog(*urlFl, *tokenFl)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	content, err := remote.revision(ctx, fl.Arg(0))
+	if err != nil {
+		return fmt.Errorf("revision %s: %w", fl.Arg(0), err)
+	}
+	if _, err := remote.put(ctx, bytes.NewReader(content), *tokenFl, "", true); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	fmt.Fprintf(output, "restored revision %s\n", shortRevision(revisionHash(content)))
+	return nil
+}
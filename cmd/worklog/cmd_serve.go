@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/husio/worklog/wlog"
+)
+
+func cmdServe(input io.Reader, output io.Writer, args []string) error {
+	fl := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addrFl := fl.String("addr", "localhost:8000", "Address to listen on.")
+	dirFl := fl.String("dir", ".", "Directory that worklogs are stored in.")
+	tokensFl := fl.String("tokens", "", "Path to the access tokens file. By default tokens.txt in the storage directory.")
+	certFl := fl.String("tls-cert", "", "Path to the TLS certificate file. Serve HTTPS if provided together with -tls-key.")
+	keyFl := fl.String("tls-key", "", "Path to the TLS private key file.")
+	dashboardFl := fl.Bool("dashboard", false, "Serve the read-only dashboard of the WORKLOG file at /dashboard/.")
+	dashboardTokenFl := fl.String("dashboard-token", "", "Token required to view the dashboard, plain or with the sha256: prefix. Required unless listening on a loopback address.")
+	holidaysFl := fl.String("holidays", defaultHolidays(), "Holiday region and files with additional days, highlighted in the dashboard.")
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), "Usage: serve [<flags>]")
+		fmt.Fprint(fl.Output(), `
+Run the worklog storage server. Each worklog is stored in the directory as
+<name>.txt and served at /<name>. The push command uploads a worklog:
+
+	worklog push -url https://example.com/jane -token <write-token>
+
+and other commands read it, with the read token in the token parameter or
+in the WORKLOG_TOKEN environment variable:
+
+	WORKLOG=https://example.com/jane?token=<read-token> worklog summary
+
+Every pushed content is kept as a revision in the <name>.history directory.
+The history, diff and restore commands list, compare and bring back
+revisions.
+
+Each line of the access tokens file is the worklog name, the read token and
+the write token. The read token "-" makes the worklog public. A token can be
+given as its SHA-256 hash with the sha256: prefix:
+
+	# name  read-token  write-token
+	jane    r3ad-s3cret  sha256:9f86d081884c7d659a2feaa0c55ad015...
+
+With -dashboard, the calendar heatmap, weekly hours and tags of the local
+WORKLOG file are shown at /dashboard/. Pages reload when the file changes.
+The tokens file is optional then, without it only the dashboard is served.
+Unless the server listens on a loopback address only, the dashboard requires
+the -dashboard-token, given once in the URL:
+
+	https://example.com/dashboard/?token=<dashboard-token>
+
+`)
+		fl.PrintDefaults()
+	}
+	if err := fl.Parse(args); err != nil {
+		return fmt.Errorf("flag parse: %w", err)
+	}
+	if (*certFl == "") != (*keyFl == "") {
+		return errors.New("both -tls-cert and -tls-key must be provided")
+	}
+
+	var storage http.Handler
+	tokensPath := *tokensFl
+	if tokensPath == "" {
+		tokensPath = filepath.Join(*dirFl, "tokens.txt")
+	}
+	fd, err := os.Open(tokensPath)
+	switch {
+	case err == nil:
+		access, err := parseServeTokens(fd)
+		fd.Close()
+		if err != nil {
+			return fmt.Errorf("cannot read tokens: %w", err)
+		}
+		if len(access) == 0 {
+			return fmt.Errorf("%s does not define any worklog", tokensPath)
+		}
+		if _, ok := access["dashboard"]; ok && *dashboardFl {
+			return errors.New("worklog name dashboard is reserved when -dashboard is used")
+		}
+		storage = &worklogServer{dir: *dirFl, access: access}
+		fmt.Fprintf(output, "serving %d worklogs\n", len(access))
+	case errors.Is(err, os.ErrNotExist) && *dashboardFl && *tokensFl == "":
+		// Dashboard only.
+	default:
+		return fmt.Errorf("cannot open %q: %w", tokensPath, err)
+	}
+
+	srv := &http.Server{
+		Addr:              *addrFl,
+		Handler:           storage,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+		WriteTimeout:      time.Minute,
+		IdleTimeout:       2 * time.Minute,
+	}
+	if *dashboardFl {
+		if *dashboardTokenFl == "" && !isLoopbackAddr(*addrFl) {
+			return errors.New("dashboard served on a public address requires -dashboard-token")
+		}
+		path := worklogPath()
+		if isURL(path) {
+			return errors.New("dashboard requires WORKLOG to be a local file")
+		}
+		holidays, err := holidayCalendar(*holidaysFl)
+		if err != nil {
+			return err
+		}
+		dash := newDashboard(path, holidays, wlog.DefaultDurationStyle)
+		dash.token = *dashboardTokenFl
+		// Live reload connections stay open, close them so that the
+		// shutdown does not wait for them.
+		srv.RegisterOnShutdown(dash.Close)
+		mux := http.NewServeMux()
+		mux.Handle("/dashboard/", dash)
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/":
+				http.Redirect(w, r, "/dashboard/", http.StatusFound)
+			case storage != nil:
+				storage.ServeHTTP(w, r)
+			default:
+				http.NotFound(w, r)
+			}
+		})
+		srv.Handler = mux
+		fmt.Fprintf(output, "serving dashboard of %s\n", path)
+	}
+
+	ln, err := net.Listen("tcp", *addrFl)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	scheme := "http"
+	if *certFl != "" {
+		scheme = "https"
+	}
+	fmt.Fprintf(output, "listening at %s://%s\n", scheme, ln.Addr())
+
+	// Stop accepting new connections on interrupt and wait for requests
+	// in progress to finish.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errc := make(chan error, 1)
+	go func() {
+		if *certFl != "" {
+			errc <- srv.ServeTLS(ln, *certFl, *keyFl)
+		} else {
+			errc <- srv.Serve(ln)
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("serve: %w", err)
+	case <-ctx.Done():
+	}
+	fmt.Fprintln(output, "shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	return nil
+}
+
+// isLoopbackAddr returns true if the listen address accepts only local
+// connections.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
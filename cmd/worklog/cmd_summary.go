@@ -1,9 +1,16 @@
 package main
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"text/tabwriter"
 	"time"
 
 	"github.com/husio/worklog/wlog"
@@ -11,25 +18,412 @@ import (
 
 func cmdSummary(input io.Reader, output io.Writer, args []string) error {
 	fl := flag.NewFlagSet("summary", flag.ContinueOnError)
+	durationFl := fl.String("duration", string(wlog.DefaultDurationStyle), "Duration display style: 1h30m, 1.5h or 1:30.")
+	queryFl := fl.String("q", "", "Summarize only tasks matching the query, for example: project = shop and date = last-month.")
+	byFl := fl.String("by", "", "Group summary by day, week, month, year, weekday, tag, project or client.")
+	workdayFl := fl.String("workday", "8h", "Length of a single work day.")
+	formatFl := fl.String("format", "table", "Output format: table, json or csv.")
+	ratesFl := fl.String("rates", "", "Path to the rate table file. If given, the revenue of each group is computed.")
+	rateFl := fl.Float64("rate", 0, "Hourly rate of tasks without a rate in the rate table. If given, the revenue of each group is computed.")
+	roundingFl := fl.String("rounding", "none", "Rounding applied to each task duration before computing the revenue, for example up:15m.")
+	holidaysFl := fl.String("holidays", defaultHolidays(), "Holiday region and files with additional days, excluded from the expected working days.")
 	if err := fl.Parse(args); err != nil {
 		return fmt.Errorf("flag parse: %w", err)
 	}
+	style, err := wlog.ParseDurationStyle(*durationFl)
+	if err != nil {
+		return err
+	}
+	var query *wlog.Query
+	if *queryFl != "" {
+		if query, err = wlog.ParseQuery(*queryFl); err != nil {
+			return err
+		}
+	}
+	workday, err := wlog.ParseDuration(*workdayFl)
+	if err != nil || workday <= 0 {
+		return fmt.Errorf("invalid workday length %q", *workdayFl)
+	}
+	groupKeys, ok := summaryGroups[*byFl]
+	if !ok {
+		return fmt.Errorf("cannot group by %q, valid groups are day, week, month, year, weekday, tag, project and client", *byFl)
+	}
+	holidays, err := holidayCalendar(*holidaysFl)
+	if err != nil {
+		return err
+	}
+	var rates *wlog.RateTable
+	if *ratesFl != "" {
+		if rates, err = readRateTable(*ratesFl); err != nil {
+			return err
+		}
+	}
+	rounding, err := wlog.ParseRounding(*roundingFl)
+	if err != nil {
+		return err
+	}
+	var revenue func(*wlog.Entry, *wlog.Task) float64
+	if rates != nil || *rateFl != 0 {
+		revenue = func(e *wlog.Entry, t *wlog.Task) float64 {
+			rate, hours := billTask(rates, rounding, *rateFl, e, t)
+			return rate * hours
+		}
+	}
 
 	entries, err := wlog.Parse(input)
 	if err != nil {
 		return fmt.Errorf("parse log: %s", err)
 	}
-	var total time.Duration
+	if query != nil {
+		entries = query.Filter(entries)
+	}
+
+	var rows []summaryRow
+	if *byFl != "" {
+		rows = summarize(entries, groupKeys, workday, revenue)
+	}
+	total := summaryRow{Group: "total"}
+	if all := summarize(entries, summaryGroups[""], workday, revenue); len(all) == 1 {
+		total = all[0]
+	}
+	if len(entries) != 0 {
+		span := wlog.DateRange{From: entries[0].Day, To: entries[len(entries)-1].Day}
+		total.ExpectedDays = holidays.WorkingDays(span)
+		for i, r := range rows {
+			if !isTimeGroup(r.Group) {
+				continue
+			}
+			if dr, err := wlog.ParseDateRange(r.Group, time.Now()); err == nil {
+				rows[i].ExpectedDays = holidays.WorkingDays(dr.Intersect(span))
+			}
+		}
+	}
+
+	switch *formatFl {
+	case "table":
+		if *byFl == "" {
+			return writeSummaryTotal(output, total, style, revenue != nil)
+		}
+		return writeSummaryTable(output, append(rows, total), style, revenue != nil)
+	case "json":
+		return writeSummaryJSON(output, rows, total)
+	case "csv":
+		return writeSummaryCSV(output, append(rows, total), revenue != nil)
+	default:
+		return fmt.Errorf("invalid format %q, valid formats are table, json and csv", *formatFl)
+	}
+}
+
+// summaryGroups maps the group name to a function that returns the group
+// keys a task belongs to.
+var summaryGroups = map[string]func(e *wlog.Entry, t *wlog.Task) []string{
+	"": func(e *wlog.Entry, t *wlog.Task) []string {
+		return []string{"total"}
+	},
+	"day": func(e *wlog.Entry, t *wlog.Task) []string {
+		return []string{e.Day.Format("2006-01-02")}
+	},
+	"week": func(e *wlog.Entry, t *wlog.Task) []string {
+		year, week := e.Day.ISOWeek()
+		return []string{fmt.Sprintf("%d-W%02d", year, week)}
+	},
+	"month": func(e *wlog.Entry, t *wlog.Task) []string {
+		return []string{e.Day.Format("2006-01")}
+	},
+	"year": func(e *wlog.Entry, t *wlog.Task) []string {
+		return []string{e.Day.Format("2006")}
+	},
+	"weekday": func(e *wlog.Entry, t *wlog.Task) []string {
+		return []string{e.Day.Weekday().String()}
+	},
+	"tag": func(e *wlog.Entry, t *wlog.Task) []string {
+		if len(t.Tags) == 0 {
+			return []string{"(none)"}
+		}
+		keys := make([]string, 0, len(t.Tags))
+		for _, tag := range t.Tags {
+			keys = append(keys, "#"+tag)
+		}
+		return keys
+	},
+	"project": func(e *wlog.Entry, t *wlog.Task) []string {
+		if t.Project == "" {
+			return []string{"(none)"}
+		}
+		return []string{"+" + t.Project}
+	},
+	"client": func(e *wlog.Entry, t *wlog.Task) []string {
+		if t.Client == "" {
+			return []string{"(none)"}
+		}
+		return []string{"@" + t.Client}
+	},
+}
+
+// summaryRow is the statistic of a group of tasks.
+type summaryRow struct {
+	Group string
+	Total time.Duration
+	// DaysWorked is the number of days with any time logged.
+	DaysWorked int
+	// Workdays is the total time expressed in work days.
+	Workdays float64
+	// ExpectedDays is the number of working days, not counting weekends
+	// and holidays, between the first and the last day of the worklog. It
+	// is known only for time based groups.
+	ExpectedDays int
+	Average      time.Duration
+	Median       time.Duration
+	Longest      time.Duration
+	LongestDay   time.Time
+	// Revenue is the sum of all tasks billed the same way as on an
+	// invoice, see billTask.
+	Revenue float64
+}
+
+// summarize computes statistics of each task group. Time based groups are
+// returned in chronological order, weekdays from Monday to Sunday, others are
+// ordered by the total time. Revenue returns the amount billed for a task and
+// can be nil.
+func summarize(entries []*wlog.Entry, groupKeys func(*wlog.Entry, *wlog.Task) []string, workday time.Duration, revenue func(*wlog.Entry, *wlog.Task) float64) []summaryRow {
+	perDay := make(map[string]map[time.Time]time.Duration)
+	amounts := make(map[string]float64)
 	for _, e := range entries {
 		for _, t := range e.Tasks {
-			total += t.Duration
+			var amount float64
+			if revenue != nil {
+				amount = revenue(e, t)
+			}
+			for _, key := range groupKeys(e, t) {
+				amounts[key] += amount
+				days, ok := perDay[key]
+				if !ok {
+					days = make(map[time.Time]time.Duration)
+					perDay[key] = days
+				}
+				days[e.Day] += t.Duration
+			}
 		}
 	}
-	if _, err := fmt.Fprintln(output, "total", total); err != nil {
-		return err
+	rows := make([]summaryRow, 0, len(perDay))
+	for key, days := range perDay {
+		row := summaryRow{Group: key, Revenue: amounts[key]}
+		lengths := make([]time.Duration, 0, len(days))
+		for day, d := range days {
+			row.Total += d
+			if d <= 0 {
+				continue
+			}
+			lengths = append(lengths, d)
+			if d > row.Longest || (d == row.Longest && day.Before(row.LongestDay)) {
+				row.Longest = d
+				row.LongestDay = day
+			}
+		}
+		row.DaysWorked = len(lengths)
+		row.Workdays = float64(row.Total) / float64(workday)
+		if n := len(lengths); n > 0 {
+			row.Average = row.Total / time.Duration(n)
+			sort.Slice(lengths, func(i, j int) bool { return lengths[i] < lengths[j] })
+			if n%2 == 1 {
+				row.Median = lengths[n/2]
+			} else {
+				row.Median = (lengths[n/2-1] + lengths[n/2]) / 2
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if isTimeGroup(rows[i].Group) && isTimeGroup(rows[j].Group) {
+			return rows[i].Group < rows[j].Group
+		}
+		wi, iok := weekdayGroup(rows[i].Group)
+		wj, jok := weekdayGroup(rows[j].Group)
+		if iok && jok {
+			return wi < wj
+		}
+		if rows[i].Total != rows[j].Total {
+			return rows[i].Total > rows[j].Total
+		}
+		return rows[i].Group < rows[j].Group
+	})
+	return rows
+}
+
+// timeGroupRx matches the day, week, month and year group keys, for example
+// 2021-11-05, 2021-W44, 2021-11 and 2021.
+var timeGroupRx = regexp.MustCompile(`^\d{4}(-W\d{2}|-\d{2}(-\d{2})?)?$`)
+
+// isTimeGroup returns true if the group key is a date, week, month or year.
+func isTimeGroup(key string) bool {
+	return timeGroupRx.MatchString(key)
+}
+
+// weekdayGroup returns the position of the weekday group key in a week
+// starting on Monday.
+func weekdayGroup(key string) (int, bool) {
+	for w := time.Sunday; w <= time.Saturday; w++ {
+		if w.String() == key {
+			return (int(w) + 6) % 7, true
+		}
+	}
+	return 0, false
+}
+
+func writeSummaryTotal(output io.Writer, total summaryRow, style wlog.DurationStyle, withRevenue bool) error {
+	wr := tabwriter.NewWriter(output, 0, 4, 1, ' ', 0)
+	lines := [][2]string{
+		{"total", wlog.FormatDuration(total.Total, style)},
+		{"days", formatFloat(total.Workdays)},
+		{"worked", strconv.Itoa(total.DaysWorked)},
+		{"expected", strconv.Itoa(total.ExpectedDays)},
+		{"average", wlog.FormatDuration(total.Average, style)},
+		{"median", wlog.FormatDuration(total.Median, style)},
+	}
+	if total.DaysWorked > 0 {
+		lines = append(lines, [2]string{"longest", fmt.Sprintf("%s (%s)", wlog.FormatDuration(total.Longest, style), total.LongestDay.Format("2006-01-02"))})
+	}
+	if withRevenue {
+		lines = append(lines, [2]string{"revenue", formatMoney(total.Revenue)})
+	}
+	for _, l := range lines {
+		if _, err := fmt.Fprintf(wr, "%s\t%s\n", l[0], l[1]); err != nil {
+			return err
+		}
 	}
-	if _, err := fmt.Fprintln(output, "days", float32(total/time.Hour/8)); err != nil {
+	return wr.Flush()
+}
+
+func writeSummaryTable(output io.Writer, rows []summaryRow, style wlog.DurationStyle, withRevenue bool) error {
+	wr := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	header := "GROUP\tTOTAL\tDAYS\tWORKED\tEXPECTED\tAVERAGE\tMEDIAN\tLONGEST"
+	if withRevenue {
+		header += "\tREVENUE"
+	}
+	if _, err := fmt.Fprintln(wr, header); err != nil {
 		return err
 	}
+	for _, r := range rows {
+		var longest string
+		if r.DaysWorked > 0 {
+			longest = fmt.Sprintf("%s (%s)", wlog.FormatDuration(r.Longest, style), r.LongestDay.Format("2006-01-02"))
+		}
+		var expected string
+		if isTimeGroup(r.Group) || r.Group == "total" {
+			expected = strconv.Itoa(r.ExpectedDays)
+		}
+		line := fmt.Sprintf("%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s",
+			r.Group,
+			wlog.FormatDuration(r.Total, style),
+			formatFloat(r.Workdays),
+			r.DaysWorked,
+			expected,
+			wlog.FormatDuration(r.Average, style),
+			wlog.FormatDuration(r.Median, style),
+			longest,
+		)
+		if withRevenue {
+			line += "\t" + formatMoney(r.Revenue)
+		}
+		if _, err := fmt.Fprintln(wr, line); err != nil {
+			return err
+		}
+	}
+	return wr.Flush()
+}
+
+type summaryJSONRow struct {
+	Group        string  `json:"group"`
+	TotalHours   float64 `json:"total_hours"`
+	Workdays     float64 `json:"workdays"`
+	DaysWorked   int     `json:"days_worked"`
+	ExpectedDays int     `json:"expected_days"`
+	AverageHours float64 `json:"average_hours"`
+	MedianHours  float64 `json:"median_hours"`
+	LongestHours float64 `json:"longest_hours"`
+	LongestDay   string  `json:"longest_day,omitempty"`
+	Revenue      float64 `json:"revenue,omitempty"`
+}
+
+func toSummaryJSONRow(r summaryRow) summaryJSONRow {
+	row := summaryJSONRow{
+		Group:        r.Group,
+		TotalHours:   r.Total.Hours(),
+		Workdays:     r.Workdays,
+		DaysWorked:   r.DaysWorked,
+		ExpectedDays: r.ExpectedDays,
+		AverageHours: r.Average.Hours(),
+		MedianHours:  r.Median.Hours(),
+		LongestHours: r.Longest.Hours(),
+		Revenue:      math.Round(r.Revenue*100) / 100,
+	}
+	if r.DaysWorked > 0 {
+		row.LongestDay = r.LongestDay.Format("2006-01-02")
+	}
+	return row
+}
+
+func writeSummaryJSON(output io.Writer, rows []summaryRow, total summaryRow) error {
+	res := struct {
+		Groups []summaryJSONRow `json:"groups,omitempty"`
+		Total  summaryJSONRow   `json:"total"`
+	}{
+		Total: toSummaryJSONRow(total),
+	}
+	for _, r := range rows {
+		res.Groups = append(res.Groups, toSummaryJSONRow(r))
+	}
+	b, err := json.MarshalIndent(res, "", "\t")
+	if err != nil {
+		return fmt.Errorf("serialize: %w", err)
+	}
+	if _, err := output.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
 	return nil
 }
+
+func writeSummaryCSV(output io.Writer, rows []summaryRow, withRevenue bool) error {
+	wr := csv.NewWriter(output)
+	header := []string{"group", "total_hours", "workdays", "days_worked", "expected_days", "average_hours", "median_hours", "longest_hours", "longest_day"}
+	if withRevenue {
+		header = append(header, "revenue")
+	}
+	if err := wr.Write(header); err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+	for _, r := range rows {
+		j := toSummaryJSONRow(r)
+		record := []string{
+			j.Group,
+			formatFloat(j.TotalHours),
+			formatFloat(j.Workdays),
+			strconv.Itoa(j.DaysWorked),
+			strconv.Itoa(j.ExpectedDays),
+			formatFloat(j.AverageHours),
+			formatFloat(j.MedianHours),
+			formatFloat(j.LongestHours),
+			j.LongestDay,
+		}
+		if withRevenue {
+			record = append(record, formatMoney(j.Revenue))
+		}
+		if err := wr.Write(record); err != nil {
+			return fmt.Errorf("write row: %w", err)
+		}
+	}
+	wr.Flush()
+	return wr.Error()
+}
+
+// formatMoney returns the amount with two decimal places.
+func formatMoney(f float64) string {
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+// formatFloat returns a number rounded to two decimal places, without
+// trailing zeros.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
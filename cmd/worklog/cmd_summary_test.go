@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/husio/worklog/wlog"
+)
+
+func TestSummaryByWeekday(t *testing.T) {
+	input := `# 7 Nov 2021 Sunday
+1h reading
+
+# 3 Nov 2021 Wednesday
+2h coding
+
+# 1 Nov 2021 Monday
+3h coding
+
+# 8 Nov 2021 Monday
+4h coding
+`
+	var out bytes.Buffer
+	args := []string{"-by", "weekday", "-format", "csv", "-holidays", ""}
+	if err := cmdSummary(strings.NewReader(input), &out, args); err != nil {
+		t.Fatalf("summary: %s", err)
+	}
+	var groups []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n")[1:] {
+		chunks := strings.Split(line, ",")
+		groups = append(groups, chunks[0]+" "+chunks[1])
+	}
+	want := []string{"Monday 7", "Wednesday 2", "Sunday 1", "total 10"}
+	if strings.Join(groups, "|") != strings.Join(want, "|") {
+		t.Fatalf("want groups %q, got %q", want, groups)
+	}
+}
+
+func TestSummaryRevenue(t *testing.T) {
+	input := `# 5 Nov 2021 Friday
+50m a +shop
+1h b
+
+# 6 Nov 2021 Saturday
+1h c
+`
+	rates := filepath.Join(t.TempDir(), "rates.txt")
+	if err := ioutil.WriteFile(rates, []byte("+shop 120\nweekend x1.5\n"), 0644); err != nil {
+		t.Fatalf("write rates: %s", err)
+	}
+	var out bytes.Buffer
+	args := []string{"-rates", rates, "-rate", "100", "-rounding", "up:15m", "-holidays", ""}
+	if err := cmdSummary(strings.NewReader(input), &out, args); err != nil {
+		t.Fatalf("summary: %s", err)
+	}
+	// 1h shop at 120, 1h at the fallback rate 100 and 1h on Saturday at
+	// the fallback rate with the weekend multiplier.
+	if !strings.Contains(out.String(), "revenue  370.00\n") {
+		t.Fatalf("unexpected revenue:\n%s", out.String())
+	}
+}
+
+func TestSummaryRejectsConflicts(t *testing.T) {
+	input := `# 1 Mar 2021 Monday
+<<<<<<< local
+2h review
+=======
+3h review
+>>>>>>> remote
+1h coding
+`
+	err := cmdSummary(strings.NewReader(input), ioutil.Discard, []string{"-holidays", ""})
+	if err == nil || !strings.Contains(err.Error(), wlog.ErrConflict.Error()) {
+		t.Fatalf("want conflict error, got %v", err)
+	}
+}
+
+func TestIsTimeGroup(t *testing.T) {
+	cases := map[string]bool{
+		"2021-11-05": true,
+		"2021-W44":   true,
+		"2021-11":    true,
+		"2021":       true,
+		"2fa":        false,
+		"3d":         false,
+		"2021-11-5":  false,
+		"Monday":     false,
+		"":           false,
+	}
+	for key, want := range cases {
+		if got := isTimeGroup(key); got != want {
+			t.Errorf("%q: want %v, got %v", key, want, got)
+		}
+	}
+}
+
+func TestSummaryByTagWithDigits(t *testing.T) {
+	input := `# 1 Nov 2021 Monday
+1h login #2fa
+3h rendering #3d
+
+# 2 Nov 2021 Tuesday
+2h login #2fa
+`
+	var out bytes.Buffer
+	args := []string{"-by", "tag", "-format", "csv", "-holidays", ""}
+	if err := cmdSummary(strings.NewReader(input), &out, args); err != nil {
+		t.Fatalf("summary: %s", err)
+	}
+	var groups []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n")[1:] {
+		groups = append(groups, strings.Split(line, ",")[0])
+	}
+	// Tags are ordered by the total, not as time periods.
+	want := []string{"#2fa", "#3d", "total"}
+	if strings.Join(groups, "|") != strings.Join(want, "|") {
+		t.Fatalf("want groups %q, got %q", want, groups)
+	}
+}
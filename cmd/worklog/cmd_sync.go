@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/husio/worklog/wlog"
+)
+
+func cmdSync(input io.Reader, output io.Writer, args []string) error {
+	fl := flag.NewFlagSet("sync", flag.ContinueOnError)
+	urlFl := fl.String("url", "", "Worklog storage URL.")
+	tokenFl := fl.String("token", "", "Worklog storage write token.")
+	stateFl := fl.String("state", "", "Path to the sync state file. By default a hidden file next to the worklog.")
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), "Usage: sync [<flags>]")
+		fmt.Fprint(fl.Output(), `
+Merge changes of the worklog kept by the storage server into the local
+worklog file, the same way pull does, and push the result. Nothing is pushed
+if the merge conflicts. Resolve the conflicts written to the worklog and run
+sync again.
+
+`)
+		fl.PrintDefaults()
+	}
+	if err := fl.Parse(args); err != nil {
+		return fmt.Errorf("flag parse: %w", err)
+	}
+	if *urlFl == "" {
+		return fmt.Errorf("\"url\" not provided")
+	}
+	if *tokenFl == "" {
+		return fmt.Errorf("\"token\" not provided")
+	}
+
+	name := inputName(input)
+	if name == "<stdin>" || name == "<input>" || isURL(name) {
+		return fmt.Errorf("cannot sync %s, only a local worklog file can be written", name)
+	}
+	content, err := ioutil.ReadAll(input)
+	if err != nil {
+		return fmt.Errorf("read worklog: %w", err)
+	}
+	// Write token grants read access too.
+	remote, err := newRemoteWorklog(*urlFl, *tokenFl)
+	if err != nil {
+		return err
+	}
+	statePath := *stateFl
+	if statePath == "" {
+		if statePath, err = defaultSyncStatePath(name); err != nil {
+			return err
+		}
+	}
+	state, err := readSyncState(statePath)
+	if err != nil {
+		return fmt.Errorf("read sync state: %w", err)
+	}
+
+	ctx := context.Background()
+	merged, rev, conflicts, err := pullWorklog(ctx, output, remote, state[remote.stateKey()], content)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(merged, content) {
+		if err := writeFileAtomic(name, merged); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+	if rev != "" {
+		state[remote.stateKey()] = rev
+		if err := writeSyncState(statePath, state); err != nil {
+			return fmt.Errorf("write sync state: %w", err)
+		}
+	}
+	if err := conflictsError(conflicts, name); err != nil {
+		return err
+	}
+
+	entries, err := wlog.Parse(bytes.NewReader(merged))
+	if err != nil {
+		return fmt.Errorf("parse log: %w", err)
+	}
+	var body bytes.Buffer
+	if err := wlog.ToText(&body, entries); err != nil {
+		return fmt.Errorf("format to text: %w", err)
+	}
+	if revisionHash(body.Bytes()) == rev {
+		return nil
+	}
+	pushed, err := remote.put(ctx, &body, *tokenFl, rev, false)
+	var conflict *conflictError
+	if errors.As(err, &conflict) {
+		return errors.New("remote worklog was changed during sync, run sync again")
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(output, "Pushed revision %s.\n", shortRevision(pushed))
+
+	state[remote.stateKey()] = pushed
+	if err := writeSyncState(statePath, state); err != nil {
+		return fmt.Errorf("write sync state: %w", err)
+	}
+	return nil
+}
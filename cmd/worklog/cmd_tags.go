@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/husio/worklog/wlog"
+)
+
+func cmdTags(input io.Reader, output io.Writer, args []string) error {
+	fl := flag.NewFlagSet("tags", flag.ContinueOnError)
+	durationFl := fl.String("duration", string(wlog.DefaultDurationStyle), "Duration display style: 1h30m, 1.5h or 1:30.")
+	kindFl := fl.String("kind", "all", "Kind of annotations to list: tag, project, client, attr or all.")
+	if err := fl.Parse(args); err != nil {
+		return fmt.Errorf("flag parse: %w", err)
+	}
+	style, err := wlog.ParseDurationStyle(*durationFl)
+	if err != nil {
+		return err
+	}
+	switch *kindFl {
+	case "all", "tag", "project", "client", "attr":
+	default:
+		return fmt.Errorf("invalid kind %q", *kindFl)
+	}
+	include := func(kind string) bool {
+		return *kindFl == "all" || *kindFl == kind
+	}
+
+	entries, err := wlog.Parse(input)
+	if err != nil {
+		return fmt.Errorf("parse log: %s", err)
+	}
+
+	totals := make(map[string]time.Duration)
+	for _, e := range entries {
+		for _, t := range e.Tasks {
+			if t.Project != "" && include("project") {
+				totals["+"+t.Project] += t.Duration
+			}
+			if t.Client != "" && include("client") {
+				totals["@"+t.Client] += t.Duration
+			}
+			if include("tag") {
+				for _, tag := range t.Tags {
+					totals["#"+tag] += t.Duration
+				}
+			}
+			if include("attr") {
+				for k, v := range t.Attrs {
+					totals[k+":"+v] += t.Duration
+				}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(totals))
+	for name := range totals {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if totals[names[i]] != totals[names[j]] {
+			return totals[names[i]] > totals[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	wr := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		if _, err := fmt.Fprintf(wr, "%s\t%s\n", name, wlog.FormatDuration(totals[name], style)); err != nil {
+			return err
+		}
+	}
+	return wr.Flush()
+}
@@ -0,0 +1,738 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// configKey maps a key of the structured configuration to the
+// TemplateContext field.
+type configKey struct {
+	key   string
+	field string
+}
+
+// configSchema lists keys of each section of the structured invoice
+// configuration. Each clients.<name> section accepts both the client and the
+// defaults keys, so that a client can override any of the defaults.
+var configSchema = map[string][]configKey{
+	"seller": {
+		{"name", "FromName"},
+		{"address", "FromAddress"},
+		{"country", "FromCountry"},
+		{"country_code", "FromCountryCode"},
+		{"tax_id", "FromTaxID"},
+		{"vat_id", "FromVATID"},
+		{"email", "FromEmail"},
+		{"phone", "FromPhone"},
+	},
+	"bank": {
+		{"name", "PaymentName"},
+		{"iban", "PaymentIBAN"},
+		{"bic", "PaymentBIC"},
+		{"bank_name", "PaymentBankName"},
+	},
+	"client": {
+		{"debtor", "Debtor"},
+		{"company", "ToCompany"},
+		{"address", "ToAddress"},
+		{"co", "ToCo"},
+		{"vat_id", "ToVATID"},
+		{"country_code", "ToCountryCode"},
+		{"email", "ToEmail"},
+		{"buyer_reference", "BuyerReference"},
+	},
+	"defaults": {
+		{"item_description", "ItemDescription"},
+		{"item_rate", "ItemRate"},
+		{"item_hours", "ItemHours"},
+		{"rates", "Rates"},
+		{"rounding", "Rounding"},
+		{"group_by", "GroupBy"},
+		{"vat_percent", "VATPaymentPerc"},
+		{"currency", "Currency"},
+		{"locale", "Locale"},
+		{"convert_to", "ConvertTo"},
+		{"exchange_rates", "ExchangeRates"},
+		{"payment_terms", "PaymentTerms"},
+		{"giro_code", "GiroCode"},
+		{"qr_bill", "QRBill"},
+		{"qr_bill_reference", "QRBillReference"},
+		{"register", "Register"},
+		{"number_pattern", "NumberPattern"},
+		{"invoice_number", "InvoiceNumber"},
+		{"invoice_date", "InvoiceDate"},
+		{"bottom_note", "BottomNote"},
+		{"signature", "SignatureBase64"},
+	},
+}
+
+// configSections is the order of sections in the migrated configuration.
+var configSections = []string{"seller", "bank", "defaults"}
+
+// configValue is a single value of the structured configuration. Value is
+// a string, int64, float64 or bool.
+type configValue struct {
+	table string
+	key   string
+	value interface{}
+	line  int
+}
+
+// configDocument is the parsed structured configuration.
+type configDocument struct {
+	path string
+	// tables maps the table name, for example clients.acme, to the line
+	// it is defined at.
+	tables map[string]int
+	values []configValue
+}
+
+func (d *configDocument) addTable(name string, line int) {
+	if _, ok := d.tables[name]; !ok {
+		d.tables[name] = line
+	}
+}
+
+// configError lists all problems found in the configuration. Each problem
+// is prefixed with the file name and the line number.
+type configError []string
+
+func (e configError) Error() string {
+	return strings.Join(e, "\n")
+}
+
+// isStructuredConfig returns true if the file is a structured configuration.
+// YAML files are recognized only to report that the format is not supported.
+func isStructuredConfig(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml", ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// readStructuredConfig reads the TOML or the JSON configuration, depending
+// on the file extension.
+func readStructuredConfig(path string, r io.Reader) (*configDocument, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return nil, fmt.Errorf("%s: YAML configuration is not supported, use TOML or JSON", path)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return parseJSONConfig(path, b)
+	}
+	return parseTOMLConfig(path, b)
+}
+
+// populateFromStructuredConfig validates the configuration against the
+// schema and sets the context fields. Sections are applied in order
+// defaults, seller, bank and the client, so that client values take
+// precedence. The client can be omitted if only one is defined.
+func populateFromStructuredConfig(c *TemplateContext, doc *configDocument, client string) error {
+	type lineProblem struct {
+		line int
+		msg  string
+	}
+	var (
+		problems []lineProblem
+		seen     = make(map[string]int)
+		clients  []string
+	)
+	// Problems without the line number refer to the whole file.
+	problem := func(line int, format string, args ...interface{}) {
+		problems = append(problems, lineProblem{line: line, msg: fmt.Sprintf(format, args...)})
+	}
+	v := reflect.ValueOf(c).Elem()
+
+	tables := make([]string, 0, len(doc.tables))
+	for name := range doc.tables {
+		tables = append(tables, name)
+	}
+	sort.Slice(tables, func(i, j int) bool { return doc.tables[tables[i]] < doc.tables[tables[j]] })
+	for _, name := range tables {
+		switch {
+		case name == "clients":
+		case strings.HasPrefix(name, "clients.") && configSectionKeys(name) != nil:
+			clients = append(clients, name[len("clients."):])
+		case configSectionKeys(name) != nil:
+		default:
+			problem(doc.tables[name], "unknown section [%s], valid sections are seller, bank, defaults and clients.<name>", name)
+		}
+	}
+
+	for _, cv := range doc.values {
+		keys := configSectionKeys(cv.table)
+		switch {
+		case cv.table == "":
+			problem(cv.line, "%s must be defined in a section", cv.key)
+			continue
+		case cv.table == "clients":
+			problem(cv.line, "%s must be defined in a [clients.<name>] section", cv.key)
+			continue
+		case keys == nil:
+			// Unknown section is already reported.
+			continue
+		}
+		id := cv.table + "." + cv.key
+		if line, ok := seen[id]; ok {
+			problem(cv.line, "%s is already defined at line %d", cv.key, line)
+			continue
+		}
+		seen[id] = cv.line
+
+		field, ok := configField(keys, cv.key)
+		if !ok {
+			problem(cv.line, "unknown key %q in [%s]", cv.key, cv.table)
+			continue
+		}
+		if want := configType(v.FieldByName(field).Kind(), cv.value); want != "" {
+			problem(cv.line, "%s must be %s", cv.key, want)
+		}
+	}
+
+	switch {
+	case client == "" && len(clients) == 1:
+		client = clients[0]
+	case client == "" && len(clients) > 1:
+		problem(0, "select the client with -client, one of %s", strings.Join(clients, ", "))
+	case client != "" && doc.tables["clients."+client] == 0:
+		problem(0, "client %q is not defined, add the [clients.%s] section", client, client)
+	}
+	if _, ok := seen["seller.name"]; !ok {
+		problem(0, "name is required in [seller]")
+	}
+	if _, ok := seen["clients."+client+".company"]; client != "" && doc.tables["clients."+client] != 0 && !ok {
+		problem(doc.tables["clients."+client], "company is required in [clients.%s]", client)
+	}
+	if len(problems) > 0 {
+		sort.SliceStable(problems, func(i, j int) bool {
+			a, b := problems[i].line, problems[j].line
+			return a != 0 && (b == 0 || a < b)
+		})
+		var err configError
+		for _, p := range problems {
+			if p.line == 0 {
+				err = append(err, fmt.Sprintf("%s: %s", doc.path, p.msg))
+			} else {
+				err = append(err, fmt.Sprintf("%s:%d: %s", doc.path, p.line, p.msg))
+			}
+		}
+		return err
+	}
+
+	c.Debtor = client
+	for _, table := range []string{"defaults", "seller", "bank", "clients." + client} {
+		for _, cv := range doc.values {
+			if cv.table != table {
+				continue
+			}
+			field, _ := configField(configSectionKeys(table), cv.key)
+			f := v.FieldByName(field)
+			switch val := cv.value.(type) {
+			case string:
+				f.SetString(val)
+			case bool:
+				f.SetBool(val)
+			case int64:
+				if f.Kind() == reflect.Float64 {
+					f.SetFloat(float64(val))
+				} else {
+					f.SetInt(val)
+				}
+			case float64:
+				f.SetFloat(val)
+			}
+		}
+	}
+	return nil
+}
+
+// configSectionKeys returns keys of the section, or nil if the section is
+// not known.
+func configSectionKeys(table string) []configKey {
+	switch {
+	case table == "seller" || table == "bank" || table == "defaults":
+		return configSchema[table]
+	case strings.HasPrefix(table, "clients.") && !strings.Contains(table[len("clients."):], "."):
+		keys := append([]configKey{}, configSchema["client"]...)
+		return append(keys, configSchema["defaults"]...)
+	}
+	return nil
+}
+
+func configField(keys []configKey, key string) (string, bool) {
+	for _, k := range keys {
+		if k.key == key {
+			return k.field, true
+		}
+	}
+	return "", false
+}
+
+// configType returns the description of the expected type if the value
+// cannot be assigned to the field of given kind.
+func configType(kind reflect.Kind, value interface{}) string {
+	switch value.(type) {
+	case string:
+		if kind == reflect.String {
+			return ""
+		}
+	case bool:
+		if kind == reflect.Bool {
+			return ""
+		}
+	case int64:
+		if kind == reflect.Int || kind == reflect.Float64 {
+			return ""
+		}
+	case float64:
+		if kind == reflect.Float64 {
+			return ""
+		}
+	}
+	switch kind {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int:
+		return "an integer"
+	default:
+		return "a number"
+	}
+}
+
+// parseTOMLConfig parses the subset of TOML used by the configuration:
+// tables, keys with string, integer, float and boolean values and comments.
+func parseTOMLConfig(path string, b []byte) (*configDocument, error) {
+	doc := &configDocument{path: path, tables: make(map[string]int)}
+	fail := func(line int, format string, args ...interface{}) error {
+		return fmt.Errorf("%s:%d: %s", path, line, fmt.Sprintf(format, args...))
+	}
+	if !utf8.Valid(b) {
+		return nil, fmt.Errorf("%s: file is not valid UTF-8", path)
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(b), "\r\n", "\n"), "\n")
+	var table string
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if strings.HasPrefix(line, "[[") {
+				return nil, fail(lineNo, "arrays of tables are not supported")
+			}
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return nil, fail(lineNo, "missing ] closing the table name")
+			}
+			if rest := strings.TrimSpace(line[end+1:]); rest != "" && rest[0] != '#' {
+				return nil, fail(lineNo, "unexpected %q after the table name", rest)
+			}
+			var parts []string
+			for _, part := range strings.Split(line[1:end], ".") {
+				part = strings.TrimSpace(part)
+				if len(part) >= 2 && part[0] == '"' && part[len(part)-1] == '"' {
+					part = part[1 : len(part)-1]
+				} else if !isTOMLBareKey(part) {
+					return nil, fail(lineNo, "invalid table name %q", line[1:end])
+				}
+				parts = append(parts, part)
+			}
+			table = strings.Join(parts, ".")
+			if defined, ok := doc.tables[table]; ok {
+				return nil, fail(lineNo, "table [%s] is already defined at line %d", table, defined)
+			}
+			doc.addTable(table, lineNo)
+			continue
+		}
+
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return nil, fail(lineNo, "expected <key> = <value>")
+		}
+		key := strings.TrimSpace(line[:eq])
+		if len(key) >= 2 && key[0] == '"' && key[len(key)-1] == '"' {
+			key = key[1 : len(key)-1]
+		} else if !isTOMLBareKey(key) {
+			return nil, fail(lineNo, "invalid key %q, dotted keys are not supported", key)
+		}
+		raw := strings.TrimSpace(line[eq+1:])
+		if raw == "" {
+			return nil, fail(lineNo, "missing value of %s", key)
+		}
+
+		var (
+			value interface{}
+			rest  string
+		)
+		switch {
+		case strings.HasPrefix(raw, `"""`) || strings.HasPrefix(raw, "'''"):
+			delim, text, start := raw[:3], raw[3:], i
+			for !strings.Contains(text, delim) {
+				if i++; i == len(lines) {
+					return nil, fail(start+1, "missing %s closing the multi-line string", delim)
+				}
+				text += "\n" + lines[i]
+			}
+			end := strings.Index(text, delim)
+			// Newline right after the opening delimiter is trimmed.
+			text, rest = strings.TrimPrefix(text[:end], "\n"), text[end+3:]
+			if delim == `"""` {
+				s, err := unescapeTOML(text)
+				if err != nil {
+					return nil, fail(start+1, "%s", err)
+				}
+				text = s
+			}
+			value = text
+		case raw[0] == '"':
+			end := 1
+			for ; end < len(raw) && raw[end] != '"'; end++ {
+				if raw[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(raw) {
+				return nil, fail(lineNo, "missing \" closing the string")
+			}
+			s, err := unescapeTOML(raw[1:end])
+			if err != nil {
+				return nil, fail(lineNo, "%s", err)
+			}
+			value, rest = s, raw[end+1:]
+		case raw[0] == '\'':
+			end := strings.IndexByte(raw[1:], '\'')
+			if end < 0 {
+				return nil, fail(lineNo, "missing ' closing the string")
+			}
+			value, rest = raw[1:end+1], raw[end+2:]
+		case raw[0] == '[' || raw[0] == '{':
+			return nil, fail(lineNo, "arrays and inline tables are not supported")
+		default:
+			token := raw
+			if i := strings.IndexAny(raw, " \t#"); i >= 0 {
+				token, rest = raw[:i], raw[i:]
+			}
+			v, err := parseTOMLScalar(token)
+			if err != nil {
+				return nil, fail(lineNo, "%s", err)
+			}
+			value = v
+		}
+		if rest = strings.TrimSpace(rest); rest != "" && rest[0] != '#' {
+			return nil, fail(lineNo, "unexpected %q after the value", rest)
+		}
+		doc.values = append(doc.values, configValue{table: table, key: key, value: value, line: lineNo})
+	}
+	return doc, nil
+}
+
+func isTOMLBareKey(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTOMLScalar parses the boolean, integer or float value.
+func parseTOMLScalar(s string) (interface{}, error) {
+	switch s {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	digits := strings.ReplaceAll(s, "_", "")
+	if n, err := strconv.ParseInt(digits, 10, 64); err == nil {
+		return n, nil
+	}
+	if strings.ContainsAny(digits, ".eE") {
+		if f, err := strconv.ParseFloat(digits, 64); err == nil {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid value %q, strings must be quoted", s)
+}
+
+// unescapeTOML replaces escape sequences of the TOML basic string.
+func unescapeTOML(s string) (string, error) {
+	if !strings.ContainsRune(s, '\\') {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i++; i == len(s) {
+			return "", errors.New("invalid escape sequence at the end of the string")
+		}
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '"', '\\':
+			b.WriteByte(s[i])
+		case 'u', 'U':
+			size := 4
+			if s[i] == 'U' {
+				size = 8
+			}
+			if i+size >= len(s) {
+				return "", fmt.Errorf("invalid escape sequence \\%s", s[i:])
+			}
+			n, err := strconv.ParseUint(s[i+1:i+1+size], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(n)) {
+				return "", fmt.Errorf("invalid escape sequence \\%s", s[i:i+1+size])
+			}
+			b.WriteRune(rune(n))
+			i += size
+		default:
+			return "", fmt.Errorf("invalid escape sequence \\%c", s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// parseJSONConfig parses the JSON configuration. Objects are tables, so that
+// {"clients": {"acme": {...}}} defines the clients.acme table.
+func parseJSONConfig(path string, b []byte) (*configDocument, error) {
+	doc := &configDocument{path: path, tables: make(map[string]int)}
+	lineAt := func(offset int64) int {
+		return bytes.Count(b[:offset], []byte("\n")) + 1
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	fail := func(err error) error {
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			return fmt.Errorf("%s:%d: %s", path, lineAt(syntax.Offset), syntax)
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("%s:%d: %s", path, lineAt(dec.InputOffset()), err)
+	}
+
+	var walk func(table string) error
+	walk = func(table string) error {
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return fail(err)
+			}
+			key := tok.(string)
+			line := lineAt(dec.InputOffset())
+			if tok, err = dec.Token(); err != nil {
+				return fail(err)
+			}
+			name := key
+			if table != "" {
+				name = table + "." + key
+			}
+			cv := configValue{table: table, key: key, line: line}
+			switch tok := tok.(type) {
+			case json.Delim:
+				if tok == '[' {
+					return fmt.Errorf("%s:%d: arrays are not supported", path, line)
+				}
+				doc.addTable(name, line)
+				if err := walk(name); err != nil {
+					return err
+				}
+				continue
+			case json.Number:
+				if n, err := tok.Int64(); err == nil {
+					cv.value = n
+				} else if f, err := tok.Float64(); err == nil {
+					cv.value = f
+				} else {
+					return fmt.Errorf("%s:%d: invalid number %s", path, line, tok)
+				}
+			case nil:
+				return fmt.Errorf("%s:%d: null is not supported, remove %s instead", path, line, key)
+			default:
+				cv.value = tok
+			}
+			doc.values = append(doc.values, cv)
+		}
+		// Closing delimiter of the object.
+		if _, err := dec.Token(); err != nil {
+			return fail(err)
+		}
+		return nil
+	}
+
+	if tok, err := dec.Token(); err != nil {
+		return nil, fail(err)
+	} else if tok != json.Delim('{') {
+		return nil, fmt.Errorf("%s:1: configuration must be a JSON object", path)
+	}
+	if err := walk(""); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("%s:%d: unexpected data after the configuration object", path, lineAt(dec.InputOffset()))
+	}
+	return doc, nil
+}
+
+// writeTOMLConfig writes the context as the structured configuration, with
+// the client section named after the client. Only fields with a non zero
+// value are written.
+func writeTOMLConfig(w io.Writer, c *TemplateContext, client string) error {
+	v := reflect.ValueOf(c).Elem()
+	var b strings.Builder
+	section := func(title string, keys []configKey) {
+		var body strings.Builder
+		for _, k := range keys {
+			f := v.FieldByName(k.field)
+			if f.IsZero() {
+				continue
+			}
+			var value string
+			switch f.Kind() {
+			case reflect.String:
+				value = tomlString(f.String())
+			case reflect.Bool:
+				value = strconv.FormatBool(f.Bool())
+			case reflect.Int:
+				value = strconv.FormatInt(f.Int(), 10)
+			case reflect.Float64:
+				value = strconv.FormatFloat(f.Float(), 'f', -1, 64)
+				if !strings.ContainsAny(value, ".e") {
+					value += ".0"
+				}
+			}
+			fmt.Fprintf(&body, "%s = %s\n", k.key, value)
+		}
+		if body.Len() > 0 {
+			fmt.Fprintf(&b, "\n[%s]\n%s", title, body.String())
+		}
+	}
+	for _, name := range configSections {
+		section(name, configSchema[name])
+	}
+	section("clients."+client, configSchema["client"])
+	_, err := io.WriteString(w, strings.TrimPrefix(b.String(), "\n"))
+	return err
+}
+
+// tomlString returns the value as the TOML string. Values with new lines
+// are written as multi-line strings.
+func tomlString(s string) string {
+	if strings.Contains(s, "\n") && !strings.ContainsAny(s, `\"`) {
+		return `"""` + "\n" + s + `"""`
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, c := range s {
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, c)
+		default:
+			b.WriteRune(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// migrateConfig writes the configuration read from the key = value file as
+// the TOML structured configuration. The client is named after the debtor
+// if no name is given.
+func migrateConfig(w io.Writer, c *TemplateContext, path, client string) error {
+	if client == "" {
+		client = configClientName(c.Debtor)
+	}
+	if client == "" {
+		client = configClientName(c.ToCompany)
+	}
+	if client == "" {
+		client = "default"
+	}
+	if !isTOMLBareKey(client) {
+		return fmt.Errorf("client name %q must contain only letters, digits, - and _", client)
+	}
+	fmt.Fprintf(w, "# Invoice configuration migrated from %s. Select the client with\n# worklog invoice -c <file> -client %s\n", filepath.Base(path), client)
+	if c.ToCompany == "" {
+		// The client section requires the company name. Without the
+		// section the client cannot be selected.
+		c.ToCompany = client
+		fmt.Fprintf(w, "#\n# ToCompany is not set in %s, replace the company placeholder.\n", filepath.Base(path))
+	}
+	fmt.Fprintln(w)
+	if err := writeTOMLConfig(w, c, client); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+
+	// Fields generated from the worklog are not part of the schema.
+	migrated := make(map[string]bool)
+	for _, keys := range configSchema {
+		for _, k := range keys {
+			migrated[k.field] = true
+		}
+	}
+	v := reflect.ValueOf(c).Elem()
+	var skipped []string
+	for i := 0; i < v.NumField(); i++ {
+		if name := v.Type().Field(i).Name; !migrated[name] && !v.Field(i).IsZero() {
+			skipped = append(skipped, name)
+		}
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(w, "\n# Not migrated, generated from the worklog: %s.\n", strings.Join(skipped, ", "))
+	}
+	return nil
+}
+
+// configClientName returns the name of the client section derived from the
+// company name, for example "ACME GmbH" becomes acme-gmbh.
+func configClientName(s string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}) {
+		if b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteString(word)
+	}
+	return b.String()
+}
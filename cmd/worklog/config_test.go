@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const exampleTOMLConfig = `# Invoice configuration.
+[seller]
+name = "Jane Doe"
+address = """
+Nebenweg 2
+80331 München"""
+country_code = 'DE'
+
+[bank]
+iban = "DE89 3704 0044 0532 0130 00" # Main account.
+
+[defaults]
+item_rate = 100
+item_hours = 1_000
+vat_percent = 19
+currency = "EUR"
+bottom_note = "Tab\tand \"quote\" \u00e9"
+
+[clients.acme]
+company = "ACME GmbH"
+address = "Hauptstraße 1\n10115 Berlin"
+item_rate = 120
+giro_code = true
+
+[clients.beta]
+company = "Beta AG"
+currency = "CHF"
+`
+
+func TestParseTOMLConfig(t *testing.T) {
+	doc, err := parseTOMLConfig("invoice.toml", []byte(exampleTOMLConfig))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	want := []configValue{
+		{"seller", "name", "Jane Doe", 3},
+		{"seller", "address", "Nebenweg 2\n80331 München", 4},
+		{"seller", "country_code", "DE", 7},
+		{"bank", "iban", "DE89 3704 0044 0532 0130 00", 10},
+		{"defaults", "item_rate", int64(100), 13},
+		{"defaults", "item_hours", int64(1000), 14},
+		{"defaults", "vat_percent", int64(19), 15},
+		{"defaults", "currency", "EUR", 16},
+		{"defaults", "bottom_note", "Tab\tand \"quote\" é", 17},
+		{"clients.acme", "company", "ACME GmbH", 20},
+		{"clients.acme", "address", "Hauptstraße 1\n10115 Berlin", 21},
+		{"clients.acme", "item_rate", int64(120), 22},
+		{"clients.acme", "giro_code", true, 23},
+		{"clients.beta", "company", "Beta AG", 26},
+		{"clients.beta", "currency", "CHF", 27},
+	}
+	if !reflect.DeepEqual(doc.values, want) {
+		t.Fatalf("want\n%#v\ngot\n%#v", want, doc.values)
+	}
+	if line := doc.tables["clients.beta"]; line != 25 {
+		t.Fatalf("want [clients.beta] at line 25, got %d", line)
+	}
+
+	invalid := map[string]string{
+		"missing equal":        "[seller]\nname",
+		"unquoted string":      "[seller]\nname = Jane",
+		"unterminated string":  "[seller]\nname = \"Jane",
+		"invalid escape":       "[seller]\nname = \"\\x\"",
+		"trailing data":        "[seller]\nname = \"Jane\" x",
+		"duplicated table":     "[seller]\n[seller]",
+		"array":                "[seller]\nname = [1]",
+		"array of tables":      "[seller]\n[[clients]]",
+		"unterminated table":   "[seller]\n[clients",
+		"unterminated literal": "[seller]\nname = '''\nJane",
+	}
+	for name, conf := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseTOMLConfig("invoice.toml", []byte(conf))
+			if err == nil || !strings.HasPrefix(err.Error(), "invoice.toml:2: ") {
+				t.Fatalf("want line 2 error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestParseJSONConfig(t *testing.T) {
+	doc, err := parseJSONConfig("invoice.json", []byte(`{
+  "seller": {"name": "Jane Doe"},
+  "defaults": {
+    "item_rate": 100,
+    "item_hours": 1.5
+  },
+  "clients": {
+    "acme": {"company": "ACME GmbH", "giro_code": true}
+  }
+}`))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	want := []configValue{
+		{"seller", "name", "Jane Doe", 2},
+		{"defaults", "item_rate", int64(100), 4},
+		{"defaults", "item_hours", 1.5, 5},
+		{"clients.acme", "company", "ACME GmbH", 8},
+		{"clients.acme", "giro_code", true, 8},
+	}
+	if !reflect.DeepEqual(doc.values, want) {
+		t.Fatalf("want\n%#v\ngot\n%#v", want, doc.values)
+	}
+
+	_, err = parseJSONConfig("invoice.json", []byte("{\n\"seller\": {\"name\": null}\n}"))
+	if err == nil || !strings.HasPrefix(err.Error(), "invoice.json:2: ") {
+		t.Fatalf("want line 2 error, got %v", err)
+	}
+	_, err = parseJSONConfig("invoice.json", []byte("{\n\"seller\": {\"name\": \"x\",}\n}"))
+	if err == nil || !strings.HasPrefix(err.Error(), "invoice.json:2: ") {
+		t.Fatalf("want line 2 error, got %v", err)
+	}
+}
+
+func TestPopulateFromStructuredConfig(t *testing.T) {
+	doc, err := parseTOMLConfig("invoice.toml", []byte(exampleTOMLConfig))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+
+	var c TemplateContext
+	if err := populateFromStructuredConfig(&c, doc, "acme"); err != nil {
+		t.Fatalf("populate: %s", err)
+	}
+	if c.Debtor != "acme" || c.ToCompany != "ACME GmbH" || c.FromName != "Jane Doe" || c.PaymentIBAN == "" {
+		t.Fatalf("seller, bank and client are not merged: %+v", c)
+	}
+	if c.ItemRate != 120 || c.VATPaymentPerc != 19 || c.ItemHours != 1000 || !c.GiroCode {
+		t.Fatalf("client must override defaults: %+v", c)
+	}
+
+	c = TemplateContext{}
+	if err := populateFromStructuredConfig(&c, doc, "beta"); err != nil {
+		t.Fatalf("populate: %s", err)
+	}
+	if c.ItemRate != 100 || c.Currency != "CHF" || c.GiroCode {
+		t.Fatalf("unexpected beta client: %+v", c)
+	}
+
+	if err := populateFromStructuredConfig(&TemplateContext{}, doc, ""); err == nil {
+		t.Fatal("want error, client must be selected")
+	}
+	if err := populateFromStructuredConfig(&TemplateContext{}, doc, "gamma"); err == nil {
+		t.Fatal("want error, client is not defined")
+	}
+}
+
+func TestStructuredConfigErrors(t *testing.T) {
+	doc, err := parseTOMLConfig("invoice.toml", []byte(`[seller]
+nme = "Jane"
+[defaults]
+item_rate = "100"
+giro_code = 1
+[clients.acme]
+company = "ACME"
+company = "ACME GmbH"
+[client]
+[clients.beta]
+address = "Street 1"
+`))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	err = populateFromStructuredConfig(&TemplateContext{}, doc, "beta")
+	want := []string{
+		`invoice.toml:2: unknown key "nme" in [seller]`,
+		`invoice.toml:4: item_rate must be an integer`,
+		`invoice.toml:5: giro_code must be true or false`,
+		`invoice.toml:8: company is already defined at line 7`,
+		`invoice.toml:9: unknown section [client], valid sections are seller, bank, defaults and clients.<name>`,
+		`invoice.toml:10: company is required in [clients.beta]`,
+		`invoice.toml: name is required in [seller]`,
+	}
+	if err == nil || err.Error() != strings.Join(want, "\n") {
+		t.Fatalf("want\n%s\ngot\n%v", strings.Join(want, "\n"), err)
+	}
+}
+
+func TestPopulateFromConfigErrors(t *testing.T) {
+	cases := map[string]string{
+		"missing equal": "FromName = Jane\nJane Doe\n",
+		"invalid int":   "FromName = Jane\nItemRate = x",
+		"unknown field": "FromName = Jane\nName = Jane",
+	}
+	for name, conf := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := populateFromConfig(&TemplateContext{}, strings.NewReader(conf))
+			if err == nil || !strings.HasPrefix(err.Error(), "line 2: ") {
+				t.Fatalf("want line 2 error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMigrateConfig(t *testing.T) {
+	var c TemplateContext
+	err := populateFromConfig(&c, strings.NewReader(`Debtor = ACME
+ToCompany = ACME GmbH
+ToAddress = Hauptstraße 1\n10115 Berlin
+FromName = Jane Doe
+FromAddress = Nebenweg 2\n80331 München
+PaymentIBAN = DE89 3704 0044 0532 0130 00
+ItemRate = 95
+ItemHours = 10.5
+GiroCode = true
+BottomNote = Say "thanks"
+Total = 100`))
+	if err != nil {
+		t.Fatalf("read: %s", err)
+	}
+
+	var b bytes.Buffer
+	if err := migrateConfig(&b, &c, "config.txt", ""); err != nil {
+		t.Fatalf("migrate: %s", err)
+	}
+	if !strings.Contains(b.String(), "[clients.acme]") || !strings.Contains(b.String(), "Not migrated, generated from the worklog: Total.") {
+		t.Fatalf("unexpected configuration\n%s", b.String())
+	}
+
+	doc, err := parseTOMLConfig("invoice.toml", b.Bytes())
+	if err != nil {
+		t.Fatalf("parse migrated: %s\n%s", err, b.String())
+	}
+	var migrated TemplateContext
+	if err := populateFromStructuredConfig(&migrated, doc, ""); err != nil {
+		t.Fatalf("populate migrated: %s", err)
+	}
+	c.Total = 0
+	c.Debtor = "ACME"
+	if !reflect.DeepEqual(c, migrated) {
+		t.Fatalf("want\n%+v\ngot\n%+v", c, migrated)
+	}
+}
+
+func TestMigrateConfigWithoutClient(t *testing.T) {
+	var c TemplateContext
+	if err := populateFromConfig(&c, strings.NewReader("FromName = Jane Doe\nItemRate = 95\n")); err != nil {
+		t.Fatalf("read: %s", err)
+	}
+	var b bytes.Buffer
+	if err := migrateConfig(&b, &c, "config.txt", "acme"); err != nil {
+		t.Fatalf("migrate: %s", err)
+	}
+	// The client suggested in the header must be defined.
+	doc, err := parseTOMLConfig("invoice.toml", b.Bytes())
+	if err != nil {
+		t.Fatalf("parse migrated: %s\n%s", err, b.String())
+	}
+	var migrated TemplateContext
+	if err := populateFromStructuredConfig(&migrated, doc, "acme"); err != nil {
+		t.Fatalf("populate migrated: %s\n%s", err, b.String())
+	}
+	if migrated.ToCompany != "acme" {
+		t.Fatalf("want placeholder company, got %q", migrated.ToCompany)
+	}
+}
+
+func TestYAMLConfigNotSupported(t *testing.T) {
+	_, err := readStructuredConfig("invoice.yaml", strings.NewReader("seller:\n  name: Jane Doe\n"))
+	if err == nil || !strings.Contains(err.Error(), "YAML configuration is not supported") {
+		t.Fatalf("want unsupported format error, got %v", err)
+	}
+}
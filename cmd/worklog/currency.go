@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/husio/worklog/wlog"
+)
+
+// currency is an ISO 4217 currency.
+type currency struct {
+	code   string
+	symbol string
+	// minorUnits is the number of decimal places of amounts.
+	minorUnits int
+}
+
+// currencies lists currencies that invoices can be issued in.
+var currencies = map[string]currency{
+	"CHF": {"CHF", "CHF", 2},
+	"CZK": {"CZK", "Kč", 2},
+	"DKK": {"DKK", "kr.", 2},
+	"EUR": {"EUR", "€", 2},
+	"GBP": {"GBP", "£", 2},
+	"JPY": {"JPY", "¥", 0},
+	"NOK": {"NOK", "kr", 2},
+	"PLN": {"PLN", "zł", 2},
+	"SEK": {"SEK", "kr", 2},
+	"USD": {"USD", "$", 2},
+}
+
+// locale defines how numbers and amounts are written.
+type locale struct {
+	decimal string
+	group   string
+	// minGrouping is the smallest number of integer digits that are
+	// grouped. For example in Polish 1234 is not grouped, but 12 345 is.
+	minGrouping int
+	symbolFirst bool
+	symbolSpace bool
+}
+
+// nbsp is the non-breaking space, that separates groups of digits and the
+// currency symbol, so that an amount is never wrapped.
+const nbsp = "\u00a0"
+
+// locales lists supported locales.
+var locales = map[string]locale{
+	"de": {decimal: ",", group: ".", minGrouping: 4, symbolSpace: true},
+	"en": {decimal: ".", group: ",", minGrouping: 4, symbolFirst: true},
+	"pl": {decimal: ",", group: nbsp, minGrouping: 5, symbolSpace: true},
+	"fr": {decimal: ",", group: nbsp, minGrouping: 4, symbolSpace: true},
+	"ch": {decimal: ".", group: "'", minGrouping: 4, symbolFirst: true, symbolSpace: true},
+}
+
+// formatNumber returns the number rounded to given decimal places, with the
+// integer part grouped by thousands.
+func (l locale) formatNumber(f float64, decimals int) string {
+	s := strconv.FormatFloat(math.Abs(f), 'f', decimals, 64)
+	integer, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		integer, fraction = s[:i], s[i+1:]
+	}
+	if len(integer) >= l.minGrouping {
+		var b strings.Builder
+		for i, c := range integer {
+			if i > 0 && (len(integer)-i)%3 == 0 {
+				b.WriteString(l.group)
+			}
+			b.WriteRune(c)
+		}
+		integer = b.String()
+	}
+	if fraction != "" {
+		integer += l.decimal + fraction
+	}
+	// Negative zero is written without the sign.
+	if f < 0 && strings.Trim(s, "0.") != "" {
+		return "-" + integer
+	}
+	return integer
+}
+
+// formatQuantity returns the number with up to four decimal places, without
+// the trailing zeros.
+func (l locale) formatQuantity(f float64) string {
+	s := l.formatNumber(f, 4)
+	if strings.Contains(s, l.decimal) {
+		s = strings.TrimSuffix(strings.TrimRight(s, "0"), l.decimal)
+	}
+	return s
+}
+
+// money formats amounts of the currency using the locale.
+type money struct {
+	currency currency
+	locale   locale
+}
+
+// newMoney returns the format of the currency and the locale, defaulting to
+// EUR and the German locale.
+func newMoney(code, lang string) (money, error) {
+	if code == "" {
+		code = "EUR"
+	}
+	cur, ok := currencies[strings.ToUpper(code)]
+	if !ok {
+		return money{}, fmt.Errorf("currency %q is not supported, valid currencies are %s", code, strings.Join(currencyCodes(), ", "))
+	}
+	if lang == "" {
+		lang = "de"
+	}
+	loc, ok := locales[strings.ToLower(lang)]
+	if !ok {
+		return money{}, fmt.Errorf("locale %q is not supported, valid locales are de, en, pl, fr and ch", lang)
+	}
+	return money{currency: cur, locale: loc}, nil
+}
+
+func currencyCodes() []string {
+	codes := make([]string, 0, len(currencies))
+	for code := range currencies {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// round returns the amount rounded to the minor units of the currency.
+func (m money) round(f float64) float64 {
+	p := math.Pow10(m.currency.minorUnits)
+	return math.Round(f*p) / p
+}
+
+// format returns the amount with the currency symbol.
+func (m money) format(f float64) string {
+	amount := m.locale.formatNumber(f, m.currency.minorUnits)
+	symbol := m.currency.symbol
+	if !m.locale.symbolFirst {
+		return amount + nbsp + symbol
+	}
+	// Symbols written with letters, for example Kč, are replaced by the
+	// currency code when they precede the amount.
+	if unicode.IsLetter([]rune(symbol)[0]) {
+		symbol = m.currency.code
+	}
+	if m.locale.symbolSpace || symbol == m.currency.code {
+		symbol += nbsp
+	}
+	if strings.HasPrefix(amount, "-") {
+		return "-" + symbol + amount[1:]
+	}
+	return symbol + amount
+}
+
+// exchangeRate is the amount of the target currency for one unit of the
+// source currency, effective from the day.
+type exchangeRate struct {
+	day  time.Time
+	from string
+	to   string
+	rate float64
+}
+
+// parseExchangeRates reads the exchange rates file. Each line is the day,
+// the source and the target currency code and the rate. Lines starting with
+// # followed by a space are comments. For example:
+//
+//	# Average rates of the central bank.
+//	2021-11-29 EUR PLN 4.6834
+//	2021-11-30 EUR PLN 4.6360
+func parseExchangeRates(r io.Reader) ([]exchangeRate, error) {
+	var rates []exchangeRate
+	sc := bufio.NewScanner(r)
+	var lineNo int
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line == "#" || strings.HasPrefix(line, "# ") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("exchange rates:%d: expected <YYYY-MM-DD> <from> <to> <rate>", lineNo)
+		}
+		day, err := wlog.ParseDate(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("exchange rates:%d: %w", lineNo, err)
+		}
+		from, to := strings.ToUpper(fields[1]), strings.ToUpper(fields[2])
+		for _, code := range []string{from, to} {
+			if _, ok := currencies[code]; !ok {
+				return nil, fmt.Errorf("exchange rates:%d: currency %q is not supported", lineNo, code)
+			}
+		}
+		rate, err := strconv.ParseFloat(fields[3], 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("exchange rates:%d: invalid rate %q", lineNo, fields[3])
+		}
+		rates = append(rates, exchangeRate{day: day, from: from, to: to, rate: rate})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	return rates, nil
+}
+
+// findExchangeRate returns the latest rate effective on the day. A rate of
+// the reverse pair is inverted if there is no direct rate.
+func findExchangeRate(rates []exchangeRate, from, to string, day time.Time) (exchangeRate, bool) {
+	var (
+		found exchangeRate
+		ok    bool
+	)
+	for _, r := range rates {
+		if r.day.After(day) || ok && r.day.Before(found.day) {
+			continue
+		}
+		switch {
+		case r.from == from && r.to == to:
+		case r.from == to && r.to == from:
+			// Direct rate of the same day takes precedence.
+			if ok && r.day.Equal(found.day) {
+				continue
+			}
+			r = exchangeRate{day: r.day, from: from, to: to, rate: 1 / r.rate}
+		default:
+			continue
+		}
+		found, ok = r, true
+	}
+	return found, ok
+}
+
+// populateConversion sets the conversion line of the invoice total to the
+// ConvertTo currency, using the exchange rate of the invoice date.
+func populateConversion(c *TemplateContext) error {
+	src, err := newMoney(c.Currency, c.Locale)
+	if err != nil {
+		return err
+	}
+	dst, err := newMoney(c.ConvertTo, c.Locale)
+	if err != nil {
+		return fmt.Errorf("ConvertTo: %w", err)
+	}
+	if c.ExchangeRates == "" {
+		return fmt.Errorf("ExchangeRates file is required to convert to %s", dst.currency.code)
+	}
+	day, err := time.Parse("2006-01-02", c.InvoiceDate)
+	if err != nil {
+		return fmt.Errorf("InvoiceDate: %q is not a valid date, expected YYYY-MM-DD", c.InvoiceDate)
+	}
+
+	fd, err := os.Open(c.ExchangeRates)
+	if err != nil {
+		return fmt.Errorf("cannot open %q: %w", c.ExchangeRates, err)
+	}
+	defer fd.Close()
+	rates, err := parseExchangeRates(fd)
+	if err != nil {
+		return fmt.Errorf("cannot read exchange rates: %w", err)
+	}
+	rate, ok := findExchangeRate(rates, src.currency.code, dst.currency.code, day)
+	if !ok {
+		return fmt.Errorf("no %s/%s exchange rate effective on %s", src.currency.code, dst.currency.code, c.InvoiceDate)
+	}
+
+	total := dst.round(src.round(c.Total) * rate.rate)
+	c.Conversion = fmt.Sprintf("Total in %s: %s (exchange rate 1 %s = %s %s of %s)",
+		dst.currency.code, dst.format(total),
+		src.currency.code, dst.locale.formatNumber(rate.rate, 4), dst.currency.code,
+		rate.day.Format("2006-01-02"))
+	return nil
+}
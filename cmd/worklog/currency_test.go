@@ -0,0 +1,160 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMoneyFormat(t *testing.T) {
+	cases := map[string]struct {
+		currency string
+		locale   string
+		amount   float64
+		want     string
+	}{
+		"default":            {"", "", 1234567.891, "1.234.567,89 €"},
+		"german small":       {"EUR", "de", 999.5, "999,50 €"},
+		"english":            {"EUR", "en", 1234.5, "€1,234.50"},
+		"english code":       {"CHF", "en", 1234.5, "CHF 1,234.50"},
+		"english letters":    {"PLN", "en", 12, "PLN 12.00"},
+		"english negative":   {"USD", "en", -1234.5, "-$1,234.50"},
+		"polish":             {"PLN", "pl", 12345.678, "12 345,68 zł"},
+		"polish no grouping": {"PLN", "pl", 1234, "1234,00 zł"},
+		"french":             {"EUR", "fr", 1234.5, "1 234,50 €"},
+		"swiss":              {"CHF", "ch", 1234567.5, "CHF 1'234'567.50"},
+		"swiss negative":     {"CHF", "ch", -12, "-CHF 12.00"},
+		"no minor units":     {"JPY", "en", 123456.7, "¥123,457"},
+		"negative zero":      {"EUR", "de", -0.001, "0,00 €"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m, err := newMoney(tc.currency, tc.locale)
+			if err != nil {
+				t.Fatalf("new money: %s", err)
+			}
+			got := strings.ReplaceAll(m.format(tc.amount), nbsp, " ")
+			if got != tc.want {
+				t.Fatalf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+
+	if _, err := newMoney("XXX", "de"); err == nil {
+		t.Error("want unsupported currency error")
+	}
+	if _, err := newMoney("EUR", "it"); err == nil {
+		t.Error("want unsupported locale error")
+	}
+}
+
+func TestFormatQuantity(t *testing.T) {
+	cases := map[string]struct {
+		locale   string
+		quantity float64
+		want     string
+	}{
+		"german":         {"de", 2.8333, "2,8333"},
+		"german integer": {"de", 1000, "1.000"},
+		"english":        {"en", 1234.5, "1,234.5"},
+		"english small":  {"en", 0.25, "0.25"},
+		"rounded":        {"de", 1.123456, "1,1235"},
+		"zero":           {"de", 0, "0"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := locales[tc.locale].formatQuantity(tc.quantity); got != tc.want {
+				t.Fatalf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseExchangeRates(t *testing.T) {
+	rates, err := parseExchangeRates(strings.NewReader(`
+# Average rates.
+2021-11-29 EUR PLN 4.6834
+2021-11-30 eur pln 4.6360
+2021-11-30 CHF EUR 0.9600
+2021-12-01 EUR PLN 4.6000
+`))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	day := time.Date(2021, 11, 30, 0, 0, 0, 0, time.UTC)
+	cases := map[string]struct {
+		from, to string
+		day      time.Time
+		want     float64
+		ok       bool
+	}{
+		"same day":       {"EUR", "PLN", day, 4.6360, true},
+		"previous day":   {"EUR", "PLN", day.AddDate(0, 0, -1), 4.6834, true},
+		"latest":         {"EUR", "PLN", day.AddDate(1, 0, 0), 4.6000, true},
+		"inverted":       {"EUR", "CHF", day, 1 / 0.96, true},
+		"before first":   {"EUR", "PLN", day.AddDate(0, 0, -2), 0, false},
+		"unknown pair":   {"EUR", "USD", day, 0, false},
+		"inverted later": {"CHF", "EUR", day.AddDate(0, 0, -1), 0, false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, ok := findExchangeRate(rates, tc.from, tc.to, tc.day)
+			if ok != tc.ok {
+				t.Fatalf("want found %v, got %v", tc.ok, ok)
+			}
+			if got.rate != tc.want {
+				t.Fatalf("want rate %v, got %v", tc.want, got.rate)
+			}
+		})
+	}
+
+	invalid := map[string]string{
+		"missing rate":  "2021-11-30 EUR PLN",
+		"invalid date":  "30.11.2021 EUR PLN 4.6",
+		"unknown code":  "2021-11-30 EUR XXX 4.6",
+		"negative rate": "2021-11-30 EUR PLN -4.6",
+	}
+	for name, line := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseExchangeRates(strings.NewReader("# Rates.\n" + line))
+			if err == nil || !strings.HasPrefix(err.Error(), "exchange rates:2:") {
+				t.Fatalf("want line 2 error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPopulateConversion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "worklog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rates.txt")
+	if err := ioutil.WriteFile(path, []byte("2021-11-29 EUR PLN 4.6834\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := TemplateContext{
+		InvoiceDate:   "2021-11-30",
+		Total:         1190,
+		Locale:        "pl",
+		ConvertTo:     "pln",
+		ExchangeRates: path,
+	}
+	if err := populateConversion(&c); err != nil {
+		t.Fatalf("conversion: %s", err)
+	}
+	want := "Total in PLN: 5573,25 zł (exchange rate 1 EUR = 4,6834 PLN of 2021-11-29)"
+	if got := strings.ReplaceAll(c.Conversion, nbsp, " "); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+
+	c.InvoiceDate = "2021-11-28"
+	if err := populateConversion(&c); err == nil {
+		t.Fatal("want missing rate error")
+	}
+}
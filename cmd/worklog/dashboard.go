@@ -0,0 +1,631 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/husio/worklog/wlog"
+)
+
+//go:embed dashboard.html
+var dashboardHTML string
+
+// dashboard is the read-only web UI of a local worklog file, served at
+// /dashboard/. The year page shows the calendar heatmap, weekly hours and
+// tags, the month page shows days of the month. Pages reload when the
+// worklog file changes.
+type dashboard struct {
+	path     string
+	holidays *wlog.HolidayCalendar
+	style    wlog.DurationStyle
+	tmpl     *template.Template
+	// poll is how often the worklog file is checked for changes.
+	poll time.Duration
+	// token is required to view the dashboard, unless empty. It can be
+	// given as its SHA-256 hash with the sha256: prefix.
+	token string
+
+	// mu serializes rendering, because the holiday calendar is not safe
+	// for concurrent use.
+	mu sync.Mutex
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newDashboard(path string, holidays *wlog.HolidayCalendar, style wlog.DurationStyle) *dashboard {
+	return &dashboard{
+		path:     path,
+		holidays: holidays,
+		style:    style,
+		tmpl:     template.Must(template.New("dashboard").Funcs(templateFuncs(style, holidays)).Parse(dashboardHTML)),
+		poll:     time.Second,
+		done:     make(chan struct{}),
+	}
+}
+
+// dashboardCookie keeps the dashboard token in the browser, so that links
+// and the live reload connection do not have to carry it.
+const dashboardCookie = "worklog-dashboard"
+
+// authorize checks the dashboard token, sent in the token query parameter,
+// the read-token header or the cookie. A valid token in the query is moved
+// to the cookie and the browser is redirected to the page without it. It
+// returns false if the response was written.
+func (d *dashboard) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if d.token == "" {
+		return true
+	}
+	if token := r.URL.Query().Get("token"); token != "" && validToken(d.token, token) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     dashboardCookie,
+			Value:    token,
+			Path:     "/dashboard/",
+			Secure:   r.TLS != nil,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+		u := *r.URL
+		q := u.Query()
+		q.Del("token")
+		u.RawQuery = q.Encode()
+		http.Redirect(w, r, u.RequestURI(), http.StatusSeeOther)
+		return false
+	}
+	if validToken(d.token, r.Header.Get("read-token")) {
+		return true
+	}
+	if c, err := r.Cookie(dashboardCookie); err == nil && validToken(d.token, c.Value) {
+		return true
+	}
+	http.Error(w, "invalid dashboard token, open /dashboard/?token=<token>", http.StatusUnauthorized)
+	return false
+}
+
+// Close ends all live reload connections, so that the server can shut
+// down.
+func (d *dashboard) Close() {
+	d.closeOnce.Do(func() { close(d.done) })
+}
+
+func (d *dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !d.authorize(w, r) {
+		return
+	}
+	page := strings.Trim(strings.TrimPrefix(r.URL.Path, "/dashboard"), "/")
+	if page == "events" {
+		d.serveEvents(w, r)
+		return
+	}
+
+	version, err := d.version()
+	if err != nil {
+		log.Printf("dashboard: %s: %s", d.path, err)
+		http.Error(w, "cannot read worklog", http.StatusInternalServerError)
+		return
+	}
+	entries, err := d.entries()
+	if err != nil {
+		log.Printf("dashboard: %s: %s", d.path, err)
+		// Worklog in the middle of a merge must be fixed by the
+		// user, who should learn where the conflict is.
+		if errors.Is(err, wlog.ErrConflict) {
+			http.Error(w, fmt.Sprintf("cannot read worklog: %s", err), http.StatusConflict)
+			return
+		}
+		http.Error(w, "cannot read worklog", http.StatusInternalServerError)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var (
+		p    *dashboardPage
+		name string
+	)
+	switch {
+	case page == "":
+		year := time.Now().Year()
+		if len(entries) > 0 {
+			year = entries[len(entries)-1].Day.Year()
+		}
+		p, name = d.yearPage(entries, year), "year"
+	case len(page) == 4:
+		year, err := strconv.Atoi(page)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		p, name = d.yearPage(entries, year), "year"
+	default:
+		month, err := time.Parse("2006-01", page)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		p, name = d.monthPage(entries, month), "month"
+	}
+	p.Version = version
+
+	w.Header().Set("content-type", "text/html; charset=utf-8")
+	if err := d.tmpl.ExecuteTemplate(w, name, p); err != nil {
+		http.Error(w, "cannot render page", http.StatusInternalServerError)
+	}
+}
+
+// version returns a value that changes whenever the worklog file changes.
+func (d *dashboard) version() (string, error) {
+	info, err := os.Stat(d.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "none", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size()), nil
+}
+
+// entries returns days of the worklog in the chronological order. Missing
+// worklog is empty.
+func (d *dashboard) entries() ([]*wlog.Entry, error) {
+	fd, err := os.Open(d.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	entries, err := wlog.Parse(fd)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Day.Before(entries[j].Day) })
+	return entries, nil
+}
+
+// serveEvents streams server-sent events, sending a message when the
+// worklog file no longer matches the version the page was rendered from.
+func (d *dashboard) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("content-type", "text/event-stream")
+	w.Header().Set("cache-control", "no-cache")
+	// The connection is dropped by the server write timeout. Browsers
+	// reconnect and changes made in the meantime are detected because the
+	// version is part of the request.
+	fmt.Fprint(w, "retry: 1000\n\n")
+	flusher.Flush()
+
+	seen := r.URL.Query().Get("v")
+	ticker := time.NewTicker(d.poll)
+	defer ticker.Stop()
+	for {
+		if current, err := d.version(); err == nil && current != seen {
+			fmt.Fprintf(w, "data: %s\n\n", current)
+			flusher.Flush()
+			seen = current
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-d.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dashboardPage is the template context of the dashboard pages.
+type dashboardPage struct {
+	Title   string
+	Version string
+	Entries []*wlog.Entry
+	Total   time.Duration
+	// Worked is the number of days with any time logged.
+	Worked int
+
+	// Year page.
+	Year     int
+	Prev     string
+	Next     string
+	Heatmap  template.HTML
+	Weeks    template.HTML
+	Months   []monthSummary
+	Tags     template.HTML
+	TagShare []tagShare
+
+	// Month page.
+	Month time.Time
+	Days  template.HTML
+}
+
+type monthSummary struct {
+	Month  time.Time
+	Total  time.Duration
+	Worked int
+}
+
+func (d *dashboard) yearPage(all []*wlog.Entry, year int) *dashboardPage {
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(1, 0, 0)
+	entries := entriesBetween(all, from, to)
+	p := &dashboardPage{
+		Title:   strconv.Itoa(year),
+		Year:    year,
+		Entries: entries,
+		Heatmap: template.HTML(heatmapSVG(entries, year, d.holidays, d.style)),
+		Weeks:   template.HTML(weeksSVG(entries, year, d.style)),
+	}
+	p.Total, p.Worked = entriesTotal(entries)
+	shares := tagShares(entries)
+	p.Tags, p.TagShare = template.HTML(pieSVG(shares, d.style)), shares
+	if len(entriesBetween(all, from.AddDate(-1, 0, 0), from)) > 0 {
+		p.Prev = strconv.Itoa(year - 1)
+	}
+	if len(entriesBetween(all, to, to.AddDate(1, 0, 0))) > 0 {
+		p.Next = strconv.Itoa(year + 1)
+	}
+	for m := from; m.Before(to); m = m.AddDate(0, 1, 0) {
+		s := monthSummary{Month: m}
+		s.Total, s.Worked = entriesTotal(entriesBetween(entries, m, m.AddDate(0, 1, 0)))
+		if s.Total > 0 {
+			p.Months = append(p.Months, s)
+		}
+	}
+	return p
+}
+
+func (d *dashboard) monthPage(all []*wlog.Entry, month time.Time) *dashboardPage {
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	entries := entriesBetween(all, from, to)
+	p := &dashboardPage{
+		Title:   from.Format("January 2006"),
+		Year:    from.Year(),
+		Month:   from,
+		Entries: entries,
+		Days:    template.HTML(daysSVG(entries, from, d.holidays, d.style)),
+		Prev:    from.AddDate(0, -1, 0).Format("2006-01"),
+		Next:    to.Format("2006-01"),
+	}
+	p.Total, p.Worked = entriesTotal(entries)
+	shares := tagShares(entries)
+	p.Tags, p.TagShare = template.HTML(pieSVG(shares, d.style)), shares
+	return p
+}
+
+// entriesBetween returns entries of days in the range [from, to).
+func entriesBetween(entries []*wlog.Entry, from, to time.Time) []*wlog.Entry {
+	var res []*wlog.Entry
+	for _, e := range entries {
+		if !e.Day.Before(from) && e.Day.Before(to) {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// entriesTotal returns the total time and the number of days with any time
+// logged.
+func entriesTotal(entries []*wlog.Entry) (time.Duration, int) {
+	var (
+		total  time.Duration
+		worked = make(map[time.Time]bool)
+	)
+	for _, e := range entries {
+		if d := e.TotalDuration(); d > 0 {
+			total += d
+			worked[e.Day] = true
+		}
+	}
+	return total, len(worked)
+}
+
+// dailyTotals returns time logged and the status of each day.
+func dailyTotals(entries []*wlog.Entry) (map[time.Time]time.Duration, map[time.Time]wlog.DayStatus) {
+	totals := make(map[time.Time]time.Duration)
+	statuses := make(map[time.Time]wlog.DayStatus)
+	for _, e := range entries {
+		totals[e.Day] += e.TotalDuration()
+		if e.Status != wlog.StatusWork {
+			statuses[e.Day] = e.Status
+		}
+	}
+	return totals, statuses
+}
+
+// heatmapColors are the cell colors of the heatmap, from no time logged to
+// a full day.
+var heatmapColors = []string{"#EBEDF0", "#C6E48B", "#7BC96F", "#239A3B", "#196127"}
+
+// heatmapLevel returns the heatmap color index of the time logged in a day.
+func heatmapLevel(d time.Duration) int {
+	switch {
+	case d <= 0:
+		return 0
+	case d < 3*time.Hour:
+		return 1
+	case d < 6*time.Hour:
+		return 2
+	case d < 8*time.Hour:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// heatmapSVG returns the calendar heatmap of the year. Each column is a
+// week starting on Monday and each cell is a day, colored by the time
+// logged. Cells link to the month page.
+func heatmapSVG(entries []*wlog.Entry, year int, holidays *wlog.HolidayCalendar, style wlog.DurationStyle) string {
+	const (
+		cell = 11
+		step = 13
+		left = 30
+		top  = 18
+	)
+	totals, statuses := dailyTotals(entries)
+	first := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	start := first.AddDate(0, 0, -weekdayIndex(first))
+	last := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	weeks := int(last.Sub(start).Hours()/24)/7 + 1
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="heatmap" xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		left+weeks*step, top+7*step, left+weeks*step, top+7*step)
+	for i, name := range []string{"Mon", "", "Wed", "", "Fri", "", ""} {
+		if name != "" {
+			fmt.Fprintf(&b, `<text x="0" y="%d" class="label">%s</text>`, top+i*step+cell-1, name)
+		}
+	}
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		week := int(day.Sub(start).Hours()/24) / 7
+		x, y := left+week*step, top+weekdayIndex(day)*step
+		if day.Day() == 1 {
+			fmt.Fprintf(&b, `<a href="%s"><text x="%d" y="%d" class="label">%s</text></a>`,
+				day.Format("2006-01"), x, top-6, day.Format("Jan"))
+		}
+
+		total := totals[day]
+		color := heatmapColors[heatmapLevel(total)]
+		title := day.Format("Mon, 2 Jan 2006")
+		if total > 0 {
+			title += ": " + wlog.FormatDuration(total, style)
+		}
+		holiday, _ := holidays.Holiday(day)
+		if status, ok := statuses[day]; ok {
+			title += " [" + string(status) + "]"
+		}
+		if holiday != "" {
+			title += " " + holiday
+		}
+		class := "day"
+		if _, ok := statuses[day]; (ok || holiday != "") && total == 0 {
+			class += " off"
+		}
+		fmt.Fprintf(&b, `<a href="%s"><rect class="%s" x="%d" y="%d" width="%d" height="%d" rx="2" fill="%s"><title>%s</title></rect></a>`,
+			day.Format("2006-01"), class, x, y, cell, cell, color, html.EscapeString(title))
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+// weekdayIndex returns the day of the week, with Monday being 0.
+func weekdayIndex(day time.Time) int {
+	return (int(day.Weekday()) + 6) % 7
+}
+
+// weeksSVG returns the bar chart of time logged in each week of the year.
+// Weeks are numbered as in ISO 8601 and belong to the year of their Monday.
+func weeksSVG(entries []*wlog.Entry, year int, style wlog.DurationStyle) string {
+	first := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	start := first.AddDate(0, 0, -weekdayIndex(first))
+	var bars []chartBar
+	for monday := start; monday.Year() <= year; monday = monday.AddDate(0, 0, 7) {
+		var total time.Duration
+		for _, e := range entriesBetween(entries, monday, monday.AddDate(0, 0, 7)) {
+			total += e.TotalDuration()
+		}
+		_, week := monday.AddDate(0, 0, 3).ISOWeek()
+		bars = append(bars, chartBar{
+			Label: strconv.Itoa(week),
+			Title: fmt.Sprintf("Week %d, from %s", week, monday.Format("2 Jan 2006")),
+			Value: total,
+			Link:  monday.Format("2006-01"),
+		})
+	}
+	return barChartSVG("weeks", bars, 40*time.Hour, 4, style)
+}
+
+// daysSVG returns the bar chart of time logged in each day of the month.
+func daysSVG(entries []*wlog.Entry, month time.Time, holidays *wlog.HolidayCalendar, style wlog.DurationStyle) string {
+	totals, statuses := dailyTotals(entries)
+	var bars []chartBar
+	for day := month; day.Month() == month.Month(); day = day.AddDate(0, 0, 1) {
+		title := day.Format("Mon, 2 Jan 2006")
+		if status, ok := statuses[day]; ok {
+			title += " [" + string(status) + "]"
+		}
+		if name, _ := holidays.Holiday(day); name != "" {
+			title += " " + name
+		}
+		bars = append(bars, chartBar{
+			Label: strconv.Itoa(day.Day()),
+			Title: title,
+			Value: totals[day],
+			Link:  "#" + day.Format("2006-01-02"),
+			Muted: day.Weekday() == time.Saturday || day.Weekday() == time.Sunday,
+		})
+	}
+	return barChartSVG("days", bars, 8*time.Hour, 1, style)
+}
+
+type chartBar struct {
+	Label string
+	Title string
+	Value time.Duration
+	Link  string
+	// Muted bars are drawn in a lighter color, for example weekends.
+	Muted bool
+}
+
+// barChartSVG returns the bar chart. Scale is at least the reference
+// value, which is also drawn as a dashed line. Every labelEvery bar is
+// labeled.
+func barChartSVG(class string, bars []chartBar, reference time.Duration, labelEvery int, style wlog.DurationStyle) string {
+	const (
+		height = 120
+		step   = 14
+		width  = 10
+		left   = 40
+		bottom = 16
+		top    = 6
+	)
+	scale := reference
+	for _, bar := range bars {
+		if bar.Value > scale {
+			scale = bar.Value
+		}
+	}
+	y := func(d time.Duration) float64 {
+		return top + float64(height)*(1-float64(d)/float64(scale))
+	}
+
+	var b strings.Builder
+	total := left + len(bars)*step
+	fmt.Fprintf(&b, `<svg class="bars %s" xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		class, total, top+height+bottom, total, top+height+bottom)
+	for _, d := range []time.Duration{0, reference, scale} {
+		fmt.Fprintf(&b, `<text x="0" y="%.1f" class="label">%s</text>`, y(d)+4, wlog.FormatDuration(d, style))
+		if d == scale && scale != reference {
+			break
+		}
+	}
+	fmt.Fprintf(&b, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" class="reference"/>`, left, total, y(reference), y(reference))
+	for i, bar := range bars {
+		x := left + i*step
+		class := "bar"
+		if bar.Muted {
+			class += " muted"
+		}
+		title := bar.Title
+		if bar.Value > 0 {
+			title += ": " + wlog.FormatDuration(bar.Value, style)
+		}
+		fmt.Fprintf(&b, `<a href="%s"><rect class="%s" x="%d" y="%.1f" width="%d" height="%.1f"><title>%s</title></rect></a>`,
+			html.EscapeString(bar.Link), class, x, y(bar.Value), width, y(0)-y(bar.Value), html.EscapeString(title))
+		if i%labelEvery == 0 {
+			fmt.Fprintf(&b, `<text x="%d" y="%d" class="label">%s</text>`, x, top+height+bottom-2, html.EscapeString(bar.Label))
+		}
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+// tagShare is the time logged with a tag.
+type tagShare struct {
+	Tag      string
+	Duration time.Duration
+	Percent  float64
+	Color    string
+}
+
+// pieColors are colors of the tag pie chart slices.
+var pieColors = []string{"#4E79A7", "#F28E2B", "#E15759", "#76B7B2", "#59A14F", "#EDC948", "#B07AA1", "#FF9DA7", "#9C755F"}
+
+// tagShares returns time logged by tag, largest first. Time of a task with
+// several tags is split equally between them, so that shares add up to the
+// total time. Tags beyond the number of colors are combined.
+func tagShares(entries []*wlog.Entry) []tagShare {
+	byTag := make(map[string]time.Duration)
+	var total time.Duration
+	for _, e := range entries {
+		for _, t := range e.Tasks {
+			total += t.Duration
+			if len(t.Tags) == 0 {
+				byTag["untagged"] += t.Duration
+				continue
+			}
+			for _, tag := range t.Tags {
+				byTag["#"+tag] += t.Duration / time.Duration(len(t.Tags))
+			}
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+	shares := make([]tagShare, 0, len(byTag))
+	for tag, d := range byTag {
+		shares = append(shares, tagShare{Tag: tag, Duration: d})
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].Duration != shares[j].Duration {
+			return shares[i].Duration > shares[j].Duration
+		}
+		return shares[i].Tag < shares[j].Tag
+	})
+	if n := len(pieColors); len(shares) > n {
+		other := tagShare{Tag: "other"}
+		for _, s := range shares[n-1:] {
+			other.Duration += s.Duration
+		}
+		shares = append(shares[:n-1], other)
+	}
+	for i := range shares {
+		shares[i].Percent = 100 * float64(shares[i].Duration) / float64(total)
+		shares[i].Color = pieColors[i]
+	}
+	return shares
+}
+
+// pieSVG returns the pie chart of tag shares.
+func pieSVG(shares []tagShare, style wlog.DurationStyle) string {
+	const r = 80.0
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="pie" xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="%d %d %d %d">`,
+		int(2*r), int(2*r), int(-r), int(-r), int(2*r), int(2*r))
+	if len(shares) == 0 {
+		fmt.Fprintf(&b, `<circle r="%g" fill="%s"/>`, r, heatmapColors[0])
+	}
+	angle := -math.Pi / 2
+	for _, s := range shares {
+		title := html.EscapeString(fmt.Sprintf("%s: %s, %.0f%%", s.Tag, wlog.FormatDuration(s.Duration, style), s.Percent))
+		if s.Percent >= 99.995 {
+			fmt.Fprintf(&b, `<circle r="%g" fill="%s"><title>%s</title></circle>`, r, s.Color, title)
+			break
+		}
+		sweep := 2 * math.Pi * s.Percent / 100
+		large := 0
+		if sweep > math.Pi {
+			large = 1
+		}
+		x1, y1 := r*math.Cos(angle), r*math.Sin(angle)
+		angle += sweep
+		x2, y2 := r*math.Cos(angle), r*math.Sin(angle)
+		fmt.Fprintf(&b, `<path d="M0 0 L%.2f %.2f A%g %g 0 %d 1 %.2f %.2f Z" fill="%s"><title>%s</title></path>`,
+			x1, y1, r, r, large, x2, y2, s.Color, title)
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
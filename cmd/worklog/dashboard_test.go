@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/husio/worklog/wlog"
+)
+
+func TestDashboard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "worklog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "worklog.txt")
+	content := "# 1 Nov 2021 Monday\n6h coding #dev\n2h review #dev #team\n\n# 2 Nov 2021 Tuesday [vacation]\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dash := newDashboard(path, nil, wlog.DurationHM)
+	dash.poll = 10 * time.Millisecond
+	srv := httptest.NewServer(dash)
+	defer srv.Close()
+	defer dash.Close()
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %s", path, err)
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+
+	code, body := get("/dashboard/")
+	if code != http.StatusOK {
+		t.Fatalf("want year page, got %d", code)
+	}
+	for _, want := range []string{
+		`<svg class="heatmap"`,
+		`<title>Mon, 1 Nov 2021: 8h</title>`,
+		`<title>Tue, 2 Nov 2021 [vacation]</title>`,
+		`<svg class="bars weeks"`,
+		`<title>Week 44, from 1 Nov 2021: 8h</title>`,
+		`<svg class="pie"`,
+		`#dev 7h (88%)`,
+		`#team 1h (12%)`,
+		`<a href="2021-11">November</a>`,
+		`new EventSource("events?v=`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("year page does not contain %q", want)
+		}
+	}
+
+	code, body = get("/dashboard/2021-11")
+	if code != http.StatusOK {
+		t.Fatalf("want month page, got %d", code)
+	}
+	for _, want := range []string{
+		`<svg class="bars days"`,
+		`<title>Mon, 1 Nov 2021: 8h</title>`,
+		`6h coding #dev`,
+		`<span class="marker">vacation</span>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("month page does not contain %q", want)
+		}
+	}
+
+	if code, _ := get("/dashboard/november"); code != http.StatusNotFound {
+		t.Fatalf("want not found, got %d", code)
+	}
+
+	// Live reload is triggered by a change of the worklog file.
+	version, err := dash.version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(srv.URL + "/dashboard/events?v=" + version)
+	if err != nil {
+		t.Fatalf("events: %s", err)
+	}
+	defer resp.Body.Close()
+	if err := ioutil.WriteFile(path, []byte(content+"\n# 3 Nov 2021 Wednesday\n1h coding\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	events := bufio.NewScanner(resp.Body)
+	for events.Scan() {
+		if strings.HasPrefix(events.Text(), "data: ") {
+			return
+		}
+	}
+	t.Fatalf("no reload event: %v", events.Err())
+}
+
+func TestDashboardConflict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "worklog.txt")
+	content := "# 1 Nov 2021 Monday\n<<<<<<< local\n2h review\n=======\n3h review\n>>>>>>> remote\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dash := newDashboard(path, nil, wlog.DurationHM)
+	defer dash.Close()
+
+	rec := httptest.NewRecorder()
+	dash.ServeHTTP(rec, httptest.NewRequest("GET", "/dashboard/", nil))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("want conflict status, got %d", rec.Code)
+	}
+	if want := "line 2: " + wlog.ErrConflict.Error(); !strings.Contains(rec.Body.String(), want) {
+		t.Fatalf("want the conflict line in the response, got %q", rec.Body.String())
+	}
+}
+
+func TestDashboardToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "worklog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "worklog.txt")
+	if err := ioutil.WriteFile(path, []byte("# 1 Nov 2021 Monday\n8h coding\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dash := newDashboard(path, nil, wlog.DurationHM)
+	dash.token = "s3cret"
+	srv := httptest.NewServer(dash)
+	defer srv.Close()
+	defer dash.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Jar: jar}
+	get := func(path, token string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", srv.URL+path, nil)
+		if token != "" {
+			req.Header.Set("read-token", token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %s", path, err)
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+
+	for _, path := range []string{"/dashboard/", "/dashboard/2021-11", "/dashboard/events", "/dashboard/?token=wrong"} {
+		if code, body := get(path, ""); code != http.StatusUnauthorized || strings.Contains(body, "coding") {
+			t.Fatalf("%s: want unauthorized, got %d", path, code)
+		}
+	}
+	if code, _ := get("/dashboard/2021-11", "wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("want wrong header token rejected, got %d", code)
+	}
+	if code, body := get("/dashboard/2021-11", "s3cret"); code != http.StatusOK || !strings.Contains(body, "coding") {
+		t.Fatalf("want header token accepted, got %d", code)
+	}
+
+	// Token in the URL is kept in the cookie for the following requests.
+	if code, body := get("/dashboard/2021-11?token=s3cret", ""); code != http.StatusOK || !strings.Contains(body, "coding") {
+		t.Fatalf("want query token accepted, got %d", code)
+	}
+	if code, _ := get("/dashboard/2021", ""); code != http.StatusOK {
+		t.Fatalf("want cookie accepted, got %d", code)
+	}
+
+	err = cmdServe(nil, ioutil.Discard, []string{"-dashboard", "-addr", ":0", "-dir", dir})
+	if err == nil || !strings.Contains(err.Error(), "-dashboard-token") {
+		t.Fatalf("want public dashboard without a token refused, got %v", err)
+	}
+}
+
+func TestIsLoopbackAddr(t *testing.T) {
+	cases := map[string]bool{
+		"localhost:8000":  true,
+		"127.0.0.1:8000":  true,
+		"[::1]:8000":      true,
+		":8000":           false,
+		"0.0.0.0:8000":    false,
+		"example.com:443": false,
+		"localhost":       false,
+	}
+	for addr, want := range cases {
+		if got := isLoopbackAddr(addr); got != want {
+			t.Errorf("%q: want %v, got %v", addr, want, got)
+		}
+	}
+}
+
+func TestTagShares(t *testing.T) {
+	entries, err := wlog.Parse(strings.NewReader("# 1 Nov 2021 Monday\n2h one #a #b\n1h two #a\n1h three\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shares := tagShares(entries)
+	want := []tagShare{
+		{Tag: "#a", Duration: 2 * time.Hour, Percent: 50},
+		{Tag: "#b", Duration: time.Hour, Percent: 25},
+		{Tag: "untagged", Duration: time.Hour, Percent: 25},
+	}
+	if len(shares) != len(want) {
+		t.Fatalf("want %d shares, got %+v", len(want), shares)
+	}
+	for i, s := range shares {
+		if s.Tag != want[i].Tag || s.Duration != want[i].Duration || s.Percent != want[i].Percent {
+			t.Errorf("share %d: want %+v, got %+v", i, want[i], s)
+		}
+	}
+}
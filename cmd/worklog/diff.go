@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/husio/worklog/wlog"
+)
+
+// unifiedDiff returns the difference between two texts in the unified diff
+// format, with three lines of context. Empty result means the texts are
+// equal.
+func unifiedDiff(fromName, toName string, from, to []byte) []byte {
+	a := splitLines(string(from))
+	b := splitLines(string(to))
+	ops := diffLines(a, b)
+
+	const context = 3
+
+	var out bytes.Buffer
+	for i := 0; i < len(ops); {
+		// Find the next change.
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		// Extend the hunk as long as changes are separated by no more
+		// than twice the context.
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*context {
+				end += context
+				if end > len(ops) {
+					end = len(ops)
+				}
+				break
+			}
+			end = run
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		hunk := ops[start:end]
+		fromLine, toLine := hunk[0].from, hunk[0].to
+		var fromCount, toCount int
+		for _, op := range hunk {
+			if op.kind != '+' {
+				fromCount++
+			}
+			if op.kind != '-' {
+				toCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(fromLine, fromCount), hunkRange(toLine, toCount))
+		for _, op := range hunk {
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			if !strings.HasSuffix(op.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return out.Bytes()
+}
+
+func hunkRange(line, count int) string {
+	if count == 0 {
+		// Empty range points at the line before.
+		return fmt.Sprintf("%d,0", line)
+	}
+	if count == 1 {
+		return fmt.Sprint(line + 1)
+	}
+	return fmt.Sprintf("%d,%d", line+1, count)
+}
+
+// splitLines splits the text into lines, keeping the line terminators.
+func splitLines(s string) []string {
+	var lines []string
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			lines = append(lines, s)
+			break
+		}
+		lines = append(lines, s[:i+1])
+		s = s[i+1:]
+	}
+	return lines
+}
+
+type diffOp struct {
+	// kind is one of ' ', '-' or '+'.
+	kind byte
+	text string
+	// from and to are the zero based positions in both texts at which the
+	// operation is applied.
+	from int
+	to   int
+}
+
+// diffLines computes the shortest edit script that transforms a into b,
+// using the linear space variant of the Myers algorithm. Within each change,
+// removed lines come before the added lines.
+func diffLines(a, b []string) []diffOp {
+	var ops []diffOp
+	diffRange(&ops, a, b, 0, 0)
+
+	// Bisection decides the order of removed and added lines, that
+	// must be consistent for the output to be readable.
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		j := i
+		var removed, added []string
+		for ; j < len(ops) && ops[j].kind != ' '; j++ {
+			if ops[j].kind == '-' {
+				removed = append(removed, ops[j].text)
+			} else {
+				added = append(added, ops[j].text)
+			}
+		}
+		from, to := ops[i].from, ops[i].to
+		for _, op := range ops[i:j] {
+			if op.from < from {
+				from = op.from
+			}
+			if op.to < to {
+				to = op.to
+			}
+		}
+		k := i
+		for n, text := range removed {
+			ops[k] = diffOp{kind: '-', text: text, from: from + n, to: to}
+			k++
+		}
+		for n, text := range added {
+			ops[k] = diffOp{kind: '+', text: text, from: from + len(removed), to: to + n}
+			k++
+		}
+		i = j
+	}
+	return ops
+}
+
+// diffRange appends to ops the edit script that transforms a into b. Both
+// are parts of the compared texts, starting at the from and to positions.
+func diffRange(ops *[]diffOp, a, b []string, from, to int) {
+	var prefix int
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		*ops = append(*ops, diffOp{kind: ' ', text: a[prefix], from: from + prefix, to: to + prefix})
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+	from, to = from+prefix, to+prefix
+
+	var suffix int
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	switch {
+	case len(a) == 0:
+		for i, text := range b {
+			*ops = append(*ops, diffOp{kind: '+', text: text, from: from, to: to + i})
+		}
+	case len(b) == 0:
+		for i, text := range a {
+			*ops = append(*ops, diffOp{kind: '-', text: text, from: from + i, to: to})
+		}
+	default:
+		x, y := diffBisect(a, b)
+		diffRange(ops, a[:x], b[:y], from, to)
+		diffRange(ops, a[x:], b[y:], from+x, to+y)
+	}
+
+	for i, text := range common {
+		*ops = append(*ops, diffOp{kind: ' ', text: text, from: from + len(a) + i, to: to + len(b) + i})
+	}
+}
+
+// diffBisect returns the point at which the shortest edit script that
+// transforms a into b can be split into two, so that each part can be
+// computed separately. Paths are searched from both ends at the same time
+// until they meet, keeping only the furthest reaching position of each
+// diagonal. Both a and b must not be empty.
+func diffBisect(a, b []string) (int, int) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	forward := make([]int, 2*maxD+2)
+	backward := make([]int, 2*maxD+2)
+	for i := range forward {
+		forward[i] = -1
+		backward[i] = -1
+	}
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+
+	delta := n - m
+	// If the difference of lengths is odd, the forward path is the one
+	// that reaches the overlap first.
+	front := delta%2 != 0
+	// Diagonals that left the edit graph are no longer searched.
+	var kForwardStart, kForwardEnd, kBackwardStart, kBackwardEnd int
+	for d := 0; d < maxD; d++ {
+		for k := -d + kForwardStart; k <= d-kForwardEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+			switch {
+			case x > n:
+				kForwardEnd += 2
+			case y > m:
+				kForwardStart += 2
+			case front:
+				kb := offset + delta - k
+				if kb >= 0 && kb < len(backward) && backward[kb] != -1 && x >= n-backward[kb] {
+					return x, y
+				}
+			}
+		}
+
+		for k := -d + kBackwardStart; k <= d-kBackwardEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+			switch {
+			case x > n:
+				kBackwardEnd += 2
+			case y > m:
+				kBackwardStart += 2
+			case !front:
+				kf := offset + delta - k
+				if kf >= 0 && kf < len(forward) && forward[kf] != -1 {
+					fx := forward[kf]
+					fy := offset + fx - kf
+					if fx >= n-x {
+						return fx, fy
+					}
+				}
+			}
+		}
+	}
+	// No common lines, everything is replaced.
+	return n, 0
+}
+
+// writeEntriesDiff writes day and task level changes between two versions of
+// a worklog. Added days and tasks are prefixed with +, removed with - and
+// changed with ~. Days are matched by the date and tasks by the
+// description, so that a changed duration is reported as a single change.
+// It returns the number of changed days.
+func writeEntriesDiff(w io.Writer, from, to []*wlog.Entry, style wlog.DurationStyle) (int, error) {
+	fromDays := entriesByDay(from)
+	toDays := entriesByDay(to)
+	var days []string
+	for day := range fromDays {
+		days = append(days, day)
+	}
+	for day := range toDays {
+		if _, ok := fromDays[day]; !ok {
+			days = append(days, day)
+		}
+	}
+	sort.Strings(days)
+
+	var changed int
+	for _, day := range days {
+		a, b := fromDays[day], toDays[day]
+		var out bytes.Buffer
+		switch {
+		case a == nil:
+			fmt.Fprintf(&out, "+ %s\n", entryHeader(b, style))
+			for _, t := range b.Tasks {
+				fmt.Fprintf(&out, "  + %s\n", taskLine(t.Duration, t.Description, style))
+			}
+		case b == nil:
+			fmt.Fprintf(&out, "- %s\n", entryHeader(a, style))
+			for _, t := range a.Tasks {
+				fmt.Fprintf(&out, "  - %s\n", taskLine(t.Duration, t.Description, style))
+			}
+		default:
+			tasks := diffTasks(a.Tasks, b.Tasks, style)
+			if len(tasks) == 0 && a.Status == b.Status {
+				continue
+			}
+			fmt.Fprintf(&out, "~ %s\n", entryHeader(b, style))
+			if a.Status != b.Status {
+				fmt.Fprintf(&out, "  ~ status %s -> %s\n", statusName(a.Status), statusName(b.Status))
+			}
+			for _, line := range tasks {
+				fmt.Fprintf(&out, "  %s\n", line)
+			}
+		}
+		changed++
+		if _, err := w.Write(out.Bytes()); err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// entriesByDay indexes entries by the day. Tasks of a day present more than
+// once are combined. Empty work days are ignored, same as when writing the
+// worklog.
+func entriesByDay(entries []*wlog.Entry) map[string]*wlog.Entry {
+	days := make(map[string]*wlog.Entry)
+	for _, e := range entries {
+		if len(e.Tasks) == 0 && e.Status == wlog.StatusWork {
+			continue
+		}
+		key := e.Day.Format("2006-01-02")
+		if prev, ok := days[key]; ok {
+			combined := *prev
+			combined.Tasks = append(append([]*wlog.Task(nil), prev.Tasks...), e.Tasks...)
+			if combined.Status == wlog.StatusWork {
+				combined.Status = e.Status
+			}
+			days[key] = &combined
+			continue
+		}
+		days[key] = e
+	}
+	return days
+}
+
+// diffTasks returns lines describing changes between tasks of the same day.
+func diffTasks(from, to []*wlog.Task, style wlog.DurationStyle) []string {
+	key := func(t *wlog.Task) string {
+		return wlog.FormatDuration(t.Duration, style) + " " + t.Description
+	}
+	a := make([]string, len(from))
+	for i, t := range from {
+		a[i] = key(t)
+	}
+	b := make([]string, len(to))
+	for i, t := range to {
+		b[i] = key(t)
+	}
+	ops := diffLines(a, b)
+
+	// Removed and added tasks with the same description are reported as a
+	// change of the duration.
+	changedTo := make(map[int]int)
+	matched := make(map[int]bool)
+	for i, op := range ops {
+		if op.kind != '-' {
+			continue
+		}
+		for j, other := range ops {
+			if other.kind == '+' && !matched[j] && to[other.to].Description == from[op.from].Description {
+				changedTo[i] = j
+				matched[j] = true
+				break
+			}
+		}
+	}
+
+	var lines []string
+	for i, op := range ops {
+		switch op.kind {
+		case '-':
+			t := from[op.from]
+			if j, ok := changedTo[i]; ok {
+				lines = append(lines, fmt.Sprintf("~ %s -> %s",
+					wlog.FormatDuration(t.Duration, style),
+					taskLine(to[ops[j].to].Duration, t.Description, style)))
+			} else {
+				lines = append(lines, "- "+taskLine(t.Duration, t.Description, style))
+			}
+		case '+':
+			if !matched[i] {
+				t := to[op.to]
+				lines = append(lines, "+ "+taskLine(t.Duration, t.Description, style))
+			}
+		}
+	}
+	return lines
+}
+
+func statusName(s wlog.DayStatus) string {
+	if s == wlog.StatusWork {
+		return "work"
+	}
+	return string(s)
+}
+
+func entryHeader(e *wlog.Entry, style wlog.DurationStyle) string {
+	header := e.Day.Format(wlog.TimeFormat)
+	if e.Status != wlog.StatusWork {
+		header += " [" + string(e.Status) + "]"
+	}
+	return header + " (" + wlog.FormatDuration(e.TotalDuration(), style) + ")"
+}
+
+// taskLine returns the task in a single line, with multiline descriptions
+// joined.
+func taskLine(d time.Duration, description string, style wlog.DurationStyle) string {
+	return wlog.FormatDuration(d, style) + " " + strings.Join(strings.Fields(description), " ")
+}
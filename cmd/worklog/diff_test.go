@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/husio/worklog/wlog"
+)
+
+func TestWriteEntriesDiff(t *testing.T) {
+	from, err := wlog.Parse(strings.NewReader(`
+# 1 Nov 2021 Monday
+8h coding
+
+# 2 Nov 2021 Tuesday
+4h meeting
+4h coding
+
+# 3 Nov 2021 Wednesday
+8h coding
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := wlog.Parse(strings.NewReader(`
+# 1 Nov 2021 Monday
+8h coding
+
+# 2 Nov 2021 Tuesday [half-day]
+4h coding
+
+# 4 Nov 2021 Thursday
+1h30m review
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	n, err := writeEntriesDiff(&b, from, to, wlog.DurationHM)
+	if err != nil {
+		t.Fatalf("diff: %s", err)
+	}
+	want := `~ # 2 Nov 2021 Tuesday [half-day] (4h)
+  ~ status work -> half-day
+  - 4h meeting
+- # 3 Nov 2021 Wednesday (8h)
+  - 8h coding
++ # 4 Nov 2021 Thursday (1h30m)
+  + 1h30m review
+`
+	if b.String() != want {
+		t.Fatalf("want\n%s\ngot\n%s", want, b.String())
+	}
+	if n != 3 {
+		t.Fatalf("want 3 changed days, got %d", n)
+	}
+
+	b.Reset()
+	if n, _ := writeEntriesDiff(&b, from, from, wlog.DurationHM); n != 0 || b.Len() != 0 {
+		t.Fatalf("want no changes, got %q", b.String())
+	}
+}
@@ -0,0 +1,673 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/husio/worklog/pdf"
+)
+
+// XRechnung identifiers, used by both the UBL and the CII syntax.
+const (
+	xrechnungCustomizationID = "urn:cen.eu:en16931:2017#compliant#urn:xeinkauf.de:kosit:xrechnung_3.0"
+	xrechnungProfileID       = "urn:fdc:peppol.eu:2017:poacc:billing:01:1.0"
+)
+
+// eInvoice is the invoice data validated and prepared for the electronic
+// invoice formats. All amounts are rounded to cents.
+type eInvoice struct {
+	number    string
+	issued    time.Time
+	seller    eInvoiceAddress
+	buyer     eInvoiceAddress
+	lines     []eInvoiceLine
+	net       float64
+	vat       float64
+	total     float64
+	category  string
+	percent   int
+	exemption string
+	iban      string
+	currency  string
+}
+
+type eInvoiceAddress struct {
+	street     string
+	additional string
+	postcode   string
+	city       string
+	country    string
+}
+
+type eInvoiceLine struct {
+	name     string
+	quantity float64
+	unit     string
+	price    float64
+	amount   float64
+}
+
+// eInvoiceError lists all problems that prevent creating a valid electronic
+// invoice. Each problem is prefixed with the name of the configuration
+// field.
+type eInvoiceError []string
+
+func (e eInvoiceError) Error() string {
+	return "invoice is not a valid XRechnung:\n\t" + strings.Join(e, "\n\t")
+}
+
+// newEInvoice validates the invoice data required by XRechnung and returns
+// it in the form ready for serialization.
+func newEInvoice(c *TemplateContext) (*eInvoice, error) {
+	var problems eInvoiceError
+	require := func(field, value, msg string) {
+		if strings.TrimSpace(value) == "" {
+			problems = append(problems, field+": "+msg)
+		}
+	}
+	inv := &eInvoice{number: c.InvoiceNumber}
+	if m, err := newMoney(c.Currency, ""); err != nil {
+		problems = append(problems, fmt.Sprintf("Currency: %q is not a supported currency code", c.Currency))
+	} else {
+		inv.currency = m.currency.code
+	}
+
+	require("InvoiceNumber", c.InvoiceNumber, "invoice number is required")
+	if day, err := time.Parse("2006-01-02", c.InvoiceDate); err != nil {
+		problems = append(problems, fmt.Sprintf("InvoiceDate: %q is not a valid date, expected YYYY-MM-DD", c.InvoiceDate))
+	} else {
+		inv.issued = day
+	}
+
+	require("FromName", c.FromName, "seller name is required")
+	inv.seller = parseEInvoiceAddress(c.FromAddress, c.FromCountryCode)
+	if inv.seller.street == "" || inv.seller.city == "" || inv.seller.postcode == "" {
+		problems = append(problems, "FromAddress: seller address is required, with the last line in the form of <postal code> <city>")
+	}
+	switch {
+	case c.FromCountryCode == "":
+		problems = append(problems, "FromCountryCode: seller country code is required, for example DE")
+	case !isCountryCode(c.FromCountryCode):
+		problems = append(problems, fmt.Sprintf("FromCountryCode: %q is not a valid country code, expected two letter code, for example DE", c.FromCountryCode))
+	}
+	switch {
+	case c.FromVATID != "":
+		if !isVATID(c.FromVATID) {
+			problems = append(problems, fmt.Sprintf("FromVATID: %q is not a valid VAT ID, it must start with the country code, for example DE123456789", c.FromVATID))
+		}
+	case c.FromTaxID == "":
+		problems = append(problems, "FromVATID: seller VAT ID is required, or the tax number if VAT ID was not assigned (FromTaxID)")
+	}
+	require("FromEmail", c.FromEmail, "seller email is required, it is the electronic address of the seller")
+	require("FromPhone", c.FromPhone, "seller phone number is required")
+
+	require("ToCompany", c.ToCompany, "buyer name is required")
+	inv.buyer = parseEInvoiceAddress(c.ToAddress, c.ToCountryCode)
+	if inv.buyer.city == "" {
+		problems = append(problems, "ToAddress: buyer address is required, with the last line in the form of <postal code> <city>")
+	}
+	switch {
+	case c.ToCountryCode == "":
+		problems = append(problems, "ToCountryCode: buyer country code is required, for example DE")
+	case !isCountryCode(c.ToCountryCode):
+		problems = append(problems, fmt.Sprintf("ToCountryCode: %q is not a valid country code, expected two letter code, for example DE", c.ToCountryCode))
+	}
+	if c.ToVATID != "" && !isVATID(c.ToVATID) {
+		problems = append(problems, fmt.Sprintf("ToVATID: %q is not a valid VAT ID, it must start with the country code, for example DE123456789", c.ToVATID))
+	}
+	require("ToEmail", c.ToEmail, "buyer email is required, it is the electronic address of the buyer")
+	require("BuyerReference", c.BuyerReference, "buyer reference is required, for example the Leitweg-ID of a public sector buyer")
+
+	require("PaymentTerms", c.PaymentTerms, "payment terms are required, for example Payable within 14 days without deduction.")
+	if iban, err := normalizeIBAN(c.PaymentIBAN); err != nil {
+		problems = append(problems, "PaymentIBAN: "+err.Error())
+	} else {
+		inv.iban = iban
+	}
+
+	if len(c.Items) == 0 {
+		problems = append(problems, "Items: invoice must contain at least one item")
+	}
+	for _, it := range c.Items {
+		unit := "HUR"
+		if it.Unit != "h" {
+			unit = "C62"
+		}
+		// Amounts are already rounded by populateFromLog, so that
+		// they match the other invoice formats.
+		line := eInvoiceLine{
+			name:     strings.ReplaceAll(htmlToText(it.Description), "\n", " "),
+			quantity: it.Quantity,
+			unit:     unit,
+			price:    it.Rate,
+			amount:   it.Total,
+		}
+		inv.lines = append(inv.lines, line)
+	}
+	inv.net = c.ItemTotal
+	inv.vat = c.VATTotal
+	inv.total = c.Total
+	inv.percent = c.VATPaymentPerc
+	if c.VATPaymentPerc > 0 {
+		inv.category = "S"
+	} else {
+		// Without VAT the invoice must state the reason of the
+		// exemption, for example the small business regulation.
+		inv.category = "E"
+		inv.exemption = htmlToText(c.BottomNote)
+		require("BottomNote", inv.exemption, "VAT exemption reason is required when VATPaymentPerc is 0")
+	}
+
+	if len(problems) != 0 {
+		return nil, problems
+	}
+	return inv, nil
+}
+
+// parseEInvoiceAddress splits the multi line address into the street, the
+// postal code and the city. The last line must contain the postal code
+// followed by the city.
+func parseEInvoiceAddress(address, country string) eInvoiceAddress {
+	var lines []string
+	for _, l := range strings.Split(address, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	a := eInvoiceAddress{country: strings.ToUpper(country)}
+	if len(lines) == 0 {
+		return a
+	}
+	last := lines[len(lines)-1]
+	if fields := strings.Fields(last); len(fields) > 1 && strings.ContainsAny(fields[0], "0123456789") {
+		a.postcode = fields[0]
+		a.city = strings.Join(fields[1:], " ")
+	} else {
+		a.city = last
+	}
+	if len(lines) > 1 {
+		a.street = lines[0]
+		a.additional = strings.Join(lines[1:len(lines)-1], ", ")
+	}
+	return a
+}
+
+func isCountryCode(s string) bool {
+	if len(s) != 2 {
+		return false
+	}
+	for _, c := range strings.ToUpper(s) {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func isVATID(s string) bool {
+	if len(s) < 4 || !isCountryCode(s[:2]) {
+		return false
+	}
+	for _, c := range s[2:] {
+		if !(c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+// ibanLengths is the length of IBAN in countries where it is commonly used.
+// IBANs of other countries are verified using the checksum only.
+var ibanLengths = map[string]int{
+	"AT": 20, "BE": 16, "CH": 21, "CZ": 24, "DE": 22, "DK": 18, "ES": 24,
+	"FI": 18, "FR": 27, "GB": 22, "IE": 22, "IT": 27, "LI": 21, "LU": 20,
+	"NL": 18, "NO": 15, "PL": 28, "PT": 25, "SE": 24,
+}
+
+// normalizeIBAN returns the IBAN without spaces, in upper case. An error is
+// returned if the IBAN is not valid.
+func normalizeIBAN(s string) (string, error) {
+	iban := strings.ToUpper(strings.Join(strings.Fields(s), ""))
+	if iban == "" {
+		return "", fmt.Errorf("IBAN is required")
+	}
+	if len(iban) < 15 || len(iban) > 34 || !isCountryCode(iban[:2]) {
+		return "", fmt.Errorf("%q is not a valid IBAN", s)
+	}
+	if n, ok := ibanLengths[iban[:2]]; ok && len(iban) != n {
+		return "", fmt.Errorf("%q is not a valid IBAN, %s IBAN must have %d characters", s, iban[:2], n)
+	}
+	// The country code and the check digits are moved to the end and
+	// letters are replaced with numbers, A is 10, B is 11 and so on.
+	var digits strings.Builder
+	for _, c := range iban[4:] + iban[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			digits.WriteRune(c)
+		case c >= 'A' && c <= 'Z':
+			digits.WriteString(strconv.Itoa(int(c-'A') + 10))
+		default:
+			return "", fmt.Errorf("%q is not a valid IBAN, unexpected character %q", s, c)
+		}
+	}
+	n, _ := new(big.Int).SetString(digits.String(), 10)
+	if new(big.Int).Mod(n, big.NewInt(97)).Int64() != 1 {
+		return "", fmt.Errorf("%q is not a valid IBAN, checksum does not match", s)
+	}
+	return iban, nil
+}
+
+func roundCents(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+// xmlAmount formats the amount with two decimal places.
+func xmlAmount(f float64) string {
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+// xmlDecimal formats the number with up to four decimal places.
+func xmlDecimal(f float64) string {
+	s := strconv.FormatFloat(f, 'f', 4, 64)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// xmlElement is a node of the generated XML document. Nil children are
+// ignored, which allows to build optional elements inline.
+type xmlElement struct {
+	name     string
+	attrs    []string
+	text     string
+	children []*xmlElement
+}
+
+// xel returns an element containing other elements.
+func xel(name string, children ...*xmlElement) *xmlElement {
+	return &xmlElement{name: name, children: children}
+}
+
+// xtext returns an element containing text. Attributes are given as name
+// and value pairs.
+func xtext(name, text string, attrs ...string) *xmlElement {
+	return &xmlElement{name: name, text: text, attrs: attrs}
+}
+
+// xopt returns an element containing text or nil if the text is empty.
+func xopt(name, text string, attrs ...string) *xmlElement {
+	if text == "" {
+		return nil
+	}
+	return xtext(name, text, attrs...)
+}
+
+// xif returns the element if the condition is true, nil otherwise.
+func xif(cond bool, e *xmlElement) *xmlElement {
+	if !cond {
+		return nil
+	}
+	return e
+}
+
+func (e *xmlElement) write(b *bytes.Buffer, depth int) {
+	indent := strings.Repeat("  ", depth)
+	b.WriteString(indent + "<" + e.name)
+	for i := 0; i+1 < len(e.attrs); i += 2 {
+		b.WriteString(" " + e.attrs[i] + `="`)
+		xml.EscapeText(b, []byte(e.attrs[i+1]))
+		b.WriteString(`"`)
+	}
+	b.WriteString(">")
+	var children bool
+	for _, c := range e.children {
+		if c == nil {
+			continue
+		}
+		if !children {
+			b.WriteString("\n")
+			children = true
+		}
+		c.write(b, depth+1)
+	}
+	if children {
+		b.WriteString(indent)
+	} else {
+		xml.EscapeText(b, []byte(e.text))
+	}
+	b.WriteString("</" + e.name + ">\n")
+}
+
+func writeXML(w io.Writer, root *xmlElement) error {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	root.write(&b, 0)
+	if _, err := b.WriteTo(w); err != nil {
+		return fmt.Errorf("write xml: %w", err)
+	}
+	return nil
+}
+
+// renderInvoiceUBL writes the invoice as XRechnung in the UBL 2.1 syntax.
+func renderInvoiceUBL(w io.Writer, c TemplateContext) error {
+	inv, err := newEInvoice(&c)
+	if err != nil {
+		return err
+	}
+	cur := []string{"currencyID", inv.currency}
+	amount := func(name string, f float64) *xmlElement {
+		return xtext(name, xmlAmount(f), cur...)
+	}
+	address := func(a eInvoiceAddress) *xmlElement {
+		return xel("cac:PostalAddress",
+			xopt("cbc:StreetName", a.street),
+			xopt("cbc:AdditionalStreetName", a.additional),
+			xtext("cbc:CityName", a.city),
+			xopt("cbc:PostalZone", a.postcode),
+			xel("cac:Country", xtext("cbc:IdentificationCode", a.country)),
+		)
+	}
+	taxScheme := xel("cac:TaxScheme", xtext("cbc:ID", "VAT"))
+	taxCategory := func(name string) *xmlElement {
+		return xel(name,
+			xtext("cbc:ID", inv.category),
+			xtext("cbc:Percent", strconv.Itoa(inv.percent)),
+			xif(name == "cac:TaxCategory", xopt("cbc:TaxExemptionReason", inv.exemption)),
+			taxScheme,
+		)
+	}
+	var sellerTax *xmlElement
+	if c.FromVATID != "" {
+		sellerTax = xel("cac:PartyTaxScheme", xtext("cbc:CompanyID", c.FromVATID), taxScheme)
+	} else {
+		sellerTax = xel("cac:PartyTaxScheme", xtext("cbc:CompanyID", c.FromTaxID), xel("cac:TaxScheme", xtext("cbc:ID", "FC")))
+	}
+
+	root := xel("ubl:Invoice",
+		xtext("cbc:CustomizationID", xrechnungCustomizationID),
+		xtext("cbc:ProfileID", xrechnungProfileID),
+		xtext("cbc:ID", inv.number),
+		xtext("cbc:IssueDate", inv.issued.Format("2006-01-02")),
+		xtext("cbc:InvoiceTypeCode", "380"),
+		xopt("cbc:Note", htmlToText(c.BottomNote)),
+		xtext("cbc:DocumentCurrencyCode", inv.currency),
+		xtext("cbc:BuyerReference", c.BuyerReference),
+		xif(!c.PeriodStart.IsZero(), xel("cac:InvoicePeriod",
+			xtext("cbc:StartDate", c.PeriodStart.Format("2006-01-02")),
+			xtext("cbc:EndDate", c.PeriodEnd.Format("2006-01-02")),
+		)),
+		xel("cac:AccountingSupplierParty", xel("cac:Party",
+			xtext("cbc:EndpointID", c.FromEmail, "schemeID", "EM"),
+			address(inv.seller),
+			sellerTax,
+			xel("cac:PartyLegalEntity", xtext("cbc:RegistrationName", c.FromName)),
+			xel("cac:Contact",
+				xtext("cbc:Name", c.FromName),
+				xtext("cbc:Telephone", c.FromPhone),
+				xtext("cbc:ElectronicMail", c.FromEmail),
+			),
+		)),
+		xel("cac:AccountingCustomerParty", xel("cac:Party",
+			xtext("cbc:EndpointID", c.ToEmail, "schemeID", "EM"),
+			address(inv.buyer),
+			xif(c.ToVATID != "", xel("cac:PartyTaxScheme", xtext("cbc:CompanyID", c.ToVATID), taxScheme)),
+			xel("cac:PartyLegalEntity", xtext("cbc:RegistrationName", c.ToCompany)),
+		)),
+		xel("cac:PaymentMeans",
+			xtext("cbc:PaymentMeansCode", "58"),
+			xtext("cbc:PaymentID", inv.number),
+			xel("cac:PayeeFinancialAccount",
+				xtext("cbc:ID", inv.iban),
+				xopt("cbc:Name", c.PaymentName),
+				xif(c.PaymentBIC != "", xel("cac:FinancialInstitutionBranch", xtext("cbc:ID", c.PaymentBIC))),
+			),
+		),
+		xel("cac:PaymentTerms", xtext("cbc:Note", c.PaymentTerms)),
+		xel("cac:TaxTotal",
+			amount("cbc:TaxAmount", inv.vat),
+			xel("cac:TaxSubtotal",
+				amount("cbc:TaxableAmount", inv.net),
+				amount("cbc:TaxAmount", inv.vat),
+				taxCategory("cac:TaxCategory"),
+			),
+		),
+		xel("cac:LegalMonetaryTotal",
+			amount("cbc:LineExtensionAmount", inv.net),
+			amount("cbc:TaxExclusiveAmount", inv.net),
+			amount("cbc:TaxInclusiveAmount", inv.total),
+			amount("cbc:PayableAmount", inv.total),
+		),
+	)
+	root.attrs = []string{
+		"xmlns:ubl", "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2",
+		"xmlns:cac", "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2",
+		"xmlns:cbc", "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2",
+	}
+	for i, l := range inv.lines {
+		root.children = append(root.children, xel("cac:InvoiceLine",
+			xtext("cbc:ID", strconv.Itoa(i+1)),
+			xtext("cbc:InvoicedQuantity", xmlDecimal(l.quantity), "unitCode", l.unit),
+			amount("cbc:LineExtensionAmount", l.amount),
+			xel("cac:Item",
+				xtext("cbc:Name", l.name),
+				taxCategory("cac:ClassifiedTaxCategory"),
+			),
+			xel("cac:Price", xtext("cbc:PriceAmount", xmlDecimal(l.price), cur...)),
+		))
+	}
+	return writeXML(w, root)
+}
+
+// renderInvoiceCII writes the invoice as XRechnung in the UN/CEFACT Cross
+// Industry Invoice syntax. The same document is embedded in ZUGFeRD
+// invoices.
+func renderInvoiceCII(w io.Writer, c TemplateContext) error {
+	inv, err := newEInvoice(&c)
+	if err != nil {
+		return err
+	}
+	date := func(name string, t time.Time) *xmlElement {
+		return xel(name, xtext("udt:DateTimeString", t.Format("20060102"), "format", "102"))
+	}
+	address := func(a eInvoiceAddress) *xmlElement {
+		return xel("ram:PostalTradeAddress",
+			xopt("ram:PostcodeCode", a.postcode),
+			xopt("ram:LineOne", a.street),
+			xopt("ram:LineTwo", a.additional),
+			xtext("ram:CityName", a.city),
+			xtext("ram:CountryID", a.country),
+		)
+	}
+	email := func(addr string) *xmlElement {
+		return xel("ram:URIUniversalCommunication", xtext("ram:URIID", addr, "schemeID", "EM"))
+	}
+	var sellerTax *xmlElement
+	if c.FromVATID != "" {
+		sellerTax = xel("ram:SpecifiedTaxRegistration", xtext("ram:ID", c.FromVATID, "schemeID", "VA"))
+	} else {
+		sellerTax = xel("ram:SpecifiedTaxRegistration", xtext("ram:ID", c.FromTaxID, "schemeID", "FC"))
+	}
+
+	transaction := xel("rsm:SupplyChainTradeTransaction")
+	for i, l := range inv.lines {
+		transaction.children = append(transaction.children, xel("ram:IncludedSupplyChainTradeLineItem",
+			xel("ram:AssociatedDocumentLineDocument", xtext("ram:LineID", strconv.Itoa(i+1))),
+			xel("ram:SpecifiedTradeProduct", xtext("ram:Name", l.name)),
+			xel("ram:SpecifiedLineTradeAgreement",
+				xel("ram:NetPriceProductTradePrice", xtext("ram:ChargeAmount", xmlDecimal(l.price))),
+			),
+			xel("ram:SpecifiedLineTradeDelivery",
+				xtext("ram:BilledQuantity", xmlDecimal(l.quantity), "unitCode", l.unit),
+			),
+			xel("ram:SpecifiedLineTradeSettlement",
+				xel("ram:ApplicableTradeTax",
+					xtext("ram:TypeCode", "VAT"),
+					xtext("ram:CategoryCode", inv.category),
+					xtext("ram:RateApplicablePercent", strconv.Itoa(inv.percent)),
+				),
+				xel("ram:SpecifiedTradeSettlementLineMonetarySummation",
+					xtext("ram:LineTotalAmount", xmlAmount(l.amount)),
+				),
+			),
+		))
+	}
+	transaction.children = append(transaction.children,
+		xel("ram:ApplicableHeaderTradeAgreement",
+			xtext("ram:BuyerReference", c.BuyerReference),
+			xel("ram:SellerTradeParty",
+				xtext("ram:Name", c.FromName),
+				xel("ram:DefinedTradeContact",
+					xtext("ram:PersonName", c.FromName),
+					xel("ram:TelephoneUniversalCommunication", xtext("ram:CompleteNumber", c.FromPhone)),
+					xel("ram:EmailURIUniversalCommunication", xtext("ram:URIID", c.FromEmail)),
+				),
+				address(inv.seller),
+				email(c.FromEmail),
+				sellerTax,
+			),
+			xel("ram:BuyerTradeParty",
+				xtext("ram:Name", c.ToCompany),
+				address(inv.buyer),
+				email(c.ToEmail),
+				xif(c.ToVATID != "", xel("ram:SpecifiedTaxRegistration", xtext("ram:ID", c.ToVATID, "schemeID", "VA"))),
+			),
+		),
+		xel("ram:ApplicableHeaderTradeDelivery"),
+		xel("ram:ApplicableHeaderTradeSettlement",
+			xtext("ram:PaymentReference", inv.number),
+			xtext("ram:InvoiceCurrencyCode", inv.currency),
+			xel("ram:SpecifiedTradeSettlementPaymentMeans",
+				xtext("ram:TypeCode", "58"),
+				xel("ram:PayeePartyCreditorFinancialAccount",
+					xtext("ram:IBANID", inv.iban),
+					xopt("ram:AccountName", c.PaymentName),
+				),
+				xif(c.PaymentBIC != "", xel("ram:PayeeSpecifiedCreditorFinancialInstitution", xtext("ram:BICID", c.PaymentBIC))),
+			),
+			xel("ram:ApplicableTradeTax",
+				xtext("ram:CalculatedAmount", xmlAmount(inv.vat)),
+				xtext("ram:TypeCode", "VAT"),
+				xopt("ram:ExemptionReason", inv.exemption),
+				xtext("ram:BasisAmount", xmlAmount(inv.net)),
+				xtext("ram:CategoryCode", inv.category),
+				xtext("ram:RateApplicablePercent", strconv.Itoa(inv.percent)),
+			),
+			xif(!c.PeriodStart.IsZero(), xel("ram:BillingSpecifiedPeriod",
+				date("ram:StartDateTime", c.PeriodStart),
+				date("ram:EndDateTime", c.PeriodEnd),
+			)),
+			xel("ram:SpecifiedTradePaymentTerms", xtext("ram:Description", c.PaymentTerms)),
+			xel("ram:SpecifiedTradeSettlementHeaderMonetarySummation",
+				xtext("ram:LineTotalAmount", xmlAmount(inv.net)),
+				xtext("ram:TaxBasisTotalAmount", xmlAmount(inv.net)),
+				xtext("ram:TaxTotalAmount", xmlAmount(inv.vat), "currencyID", inv.currency),
+				xtext("ram:GrandTotalAmount", xmlAmount(inv.total)),
+				xtext("ram:DuePayableAmount", xmlAmount(inv.total)),
+			),
+		),
+	)
+
+	root := xel("rsm:CrossIndustryInvoice",
+		xel("rsm:ExchangedDocumentContext",
+			xel("ram:BusinessProcessSpecifiedDocumentContextParameter", xtext("ram:ID", xrechnungProfileID)),
+			xel("ram:GuidelineSpecifiedDocumentContextParameter", xtext("ram:ID", xrechnungCustomizationID)),
+		),
+		xel("rsm:ExchangedDocument",
+			xtext("ram:ID", inv.number),
+			xtext("ram:TypeCode", "380"),
+			date("ram:IssueDateTime", inv.issued),
+			xif(c.BottomNote != "", xel("ram:IncludedNote", xtext("ram:Content", htmlToText(c.BottomNote)))),
+		),
+		transaction,
+	)
+	root.attrs = []string{
+		"xmlns:rsm", "urn:un:unece:uncefact:data:standard:CrossIndustryInvoice:100",
+		"xmlns:ram", "urn:un:unece:uncefact:data:standard:ReusableAggregateBusinessInformationEntity:100",
+		"xmlns:udt", "urn:un:unece:uncefact:data:standard:UnqualifiedDataType:100",
+		"xmlns:qdt", "urn:un:unece:uncefact:data:standard:QualifiedDataType:100",
+	}
+	return writeXML(w, root)
+}
+
+// renderInvoiceZUGFeRD writes the invoice as a PDF/A-3 document with the
+// CII invoice embedded, which makes it a ZUGFeRD/Factur-X invoice of the
+// XRECHNUNG profile.
+func renderInvoiceZUGFeRD(w io.Writer, c TemplateContext) error {
+	var cii bytes.Buffer
+	if err := renderInvoiceCII(&cii, c); err != nil {
+		return err
+	}
+	doc, err := invoicePDF(c)
+	if err != nil {
+		return err
+	}
+	doc.PDFA = true
+	doc.XMPExtension = facturXMetadata
+	doc.Attachments = []pdf.Attachment{{
+		Name:         "xrechnung.xml",
+		Description:  "XRechnung invoice",
+		MimeType:     "text/xml",
+		Relationship: "Alternative",
+		Data:         cii.Bytes(),
+	}}
+	if _, err := doc.WriteTo(w); err != nil {
+		return fmt.Errorf("write pdf: %w", err)
+	}
+	return nil
+}
+
+// facturXMetadata describes the embedded invoice in the XMP metadata, as
+// required by the Factur-X specification.
+const facturXMetadata = `
+<rdf:Description rdf:about="" xmlns:fx="urn:factur-x:pdfa:CrossIndustryDocument:invoice:1p0#">
+<fx:DocumentType>INVOICE</fx:DocumentType>
+<fx:DocumentFileName>xrechnung.xml</fx:DocumentFileName>
+<fx:Version>1.0</fx:Version>
+<fx:ConformanceLevel>XRECHNUNG</fx:ConformanceLevel>
+</rdf:Description>
+<rdf:Description rdf:about="" xmlns:pdfaExtension="http://www.aiim.org/pdfa/ns/extension/" xmlns:pdfaSchema="http://www.aiim.org/pdfa/ns/schema#" xmlns:pdfaProperty="http://www.aiim.org/pdfa/ns/property#">
+<pdfaExtension:schemas>
+<rdf:Bag>
+<rdf:li rdf:parseType="Resource">
+<pdfaSchema:schema>Factur-X PDFA Extension Schema</pdfaSchema:schema>
+<pdfaSchema:namespaceURI>urn:factur-x:pdfa:CrossIndustryDocument:invoice:1p0#</pdfaSchema:namespaceURI>
+<pdfaSchema:prefix>fx</pdfaSchema:prefix>
+<pdfaSchema:property>
+<rdf:Seq>
+<rdf:li rdf:parseType="Resource">
+<pdfaProperty:name>DocumentFileName</pdfaProperty:name>
+<pdfaProperty:valueType>Text</pdfaProperty:valueType>
+<pdfaProperty:category>external</pdfaProperty:category>
+<pdfaProperty:description>Name of the embedded XML invoice file</pdfaProperty:description>
+</rdf:li>
+<rdf:li rdf:parseType="Resource">
+<pdfaProperty:name>DocumentType</pdfaProperty:name>
+<pdfaProperty:valueType>Text</pdfaProperty:valueType>
+<pdfaProperty:category>external</pdfaProperty:category>
+<pdfaProperty:description>INVOICE</pdfaProperty:description>
+</rdf:li>
+<rdf:li rdf:parseType="Resource">
+<pdfaProperty:name>Version</pdfaProperty:name>
+<pdfaProperty:valueType>Text</pdfaProperty:valueType>
+<pdfaProperty:category>external</pdfaProperty:category>
+<pdfaProperty:description>Version of the Factur-X XML schema</pdfaProperty:description>
+</rdf:li>
+<rdf:li rdf:parseType="Resource">
+<pdfaProperty:name>ConformanceLevel</pdfaProperty:name>
+<pdfaProperty:valueType>Text</pdfaProperty:valueType>
+<pdfaProperty:category>external</pdfaProperty:category>
+<pdfaProperty:description>Conformance level of the embedded invoice</pdfaProperty:description>
+</rdf:li>
+</rdf:Seq>
+</pdfaSchema:property>
+</rdf:li>
+</rdf:Bag>
+</pdfaExtension:schemas>
+</rdf:Description>
+`
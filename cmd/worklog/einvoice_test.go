@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/husio/worklog/wlog"
+)
+
+func validEInvoiceContext() TemplateContext {
+	return TemplateContext{
+		InvoiceNumber:   "2021-0001",
+		InvoiceDate:     "2021-11-30",
+		FromName:        "Jane Doe",
+		FromAddress:     "Nebenweg 2\n80331 München",
+		FromCountryCode: "DE",
+		FromVATID:       "DE123456789",
+		FromPhone:       "+49 89 123456",
+		FromEmail:       "jane@example.com",
+		ToCompany:       "ACME GmbH",
+		ToAddress:       "Hauptstraße 1\n10115 Berlin",
+		ToCountryCode:   "DE",
+		ToEmail:         "invoices@acme.example",
+		BuyerReference:  "991-12345-67",
+		PaymentIBAN:     "DE89 3704 0044 0532 0130 00",
+		PaymentTerms:    "Payable within 14 days.",
+		VATPaymentPerc:  19,
+		Items: []InvoiceItem{
+			{Description: "Development<br><em>(01.11.2021 - 30.11.2021)</em>", Quantity: 10.5, Unit: "h", Rate: 100.333, Total: 1053.50},
+		},
+		ItemTotal:   1053.50,
+		VATTotal:    200.17,
+		Total:       1253.67,
+		PeriodStart: time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC),
+		PeriodEnd:   time.Date(2021, 11, 30, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestRenderInvoiceUBL(t *testing.T) {
+	var b bytes.Buffer
+	if err := renderInvoiceUBL(&b, validEInvoiceContext()); err != nil {
+		t.Fatalf("render: %s", err)
+	}
+	for _, want := range []string{
+		`<cbc:ID>2021-0001</cbc:ID>`,
+		`<cbc:BuyerReference>991-12345-67</cbc:BuyerReference>`,
+		`<cbc:ID>DE89370400440532013000</cbc:ID>`,
+		`<cbc:Name>Development (01.11.2021 - 30.11.2021)</cbc:Name>`,
+		`<cbc:InvoicedQuantity unitCode="HUR">10.5</cbc:InvoicedQuantity>`,
+		`<cbc:LineExtensionAmount currencyID="EUR">1053.50</cbc:LineExtensionAmount>`,
+		`<cbc:TaxAmount currencyID="EUR">200.17</cbc:TaxAmount>`,
+		`<cbc:PayableAmount currencyID="EUR">1253.67</cbc:PayableAmount>`,
+		`<cbc:StreetName>Hauptstraße 1</cbc:StreetName>`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("document does not contain %s", want)
+		}
+	}
+}
+
+func TestRenderInvoiceCII(t *testing.T) {
+	c := validEInvoiceContext()
+	c.VATPaymentPerc = 0
+	c.VATTotal = 0
+	c.Total = c.ItemTotal
+	c.BottomNote = "No VAT according to § 19 UStG."
+	var b bytes.Buffer
+	if err := renderInvoiceCII(&b, c); err != nil {
+		t.Fatalf("render: %s", err)
+	}
+	for _, want := range []string{
+		`<ram:ExemptionReason>No VAT according to § 19 UStG.</ram:ExemptionReason>`,
+		`<ram:CategoryCode>E</ram:CategoryCode>`,
+		`<ram:DuePayableAmount>1053.50</ram:DuePayableAmount>`,
+		`<udt:DateTimeString format="102">20211101</udt:DateTimeString>`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("document does not contain %s", want)
+		}
+	}
+}
+
+func TestEInvoiceValidation(t *testing.T) {
+	c := validEInvoiceContext()
+	c.FromVATID = ""
+	c.BuyerReference = ""
+	c.PaymentTerms = ""
+	c.PaymentIBAN = "DE89 3704 0044 0532 0130 01"
+	c.VATPaymentPerc = 0
+
+	_, err := newEInvoice(&c)
+	problems, ok := err.(eInvoiceError)
+	if !ok {
+		t.Fatalf("want validation error, got %v", err)
+	}
+	want := []string{"FromVATID", "BuyerReference", "PaymentTerms", "PaymentIBAN", "BottomNote"}
+	if len(problems) != len(want) {
+		t.Fatalf("want %d problems, got %q", len(want), problems)
+	}
+	for i, field := range want {
+		if !strings.HasPrefix(problems[i], field+": ") {
+			t.Errorf("want %s problem, got %q", field, problems[i])
+		}
+	}
+
+	// Tax number is accepted if the VAT ID was not assigned.
+	c = validEInvoiceContext()
+	c.FromVATID = ""
+	c.FromTaxID = "143/123/12345"
+	if _, err := newEInvoice(&c); err != nil {
+		t.Fatalf("want tax number to be accepted, got %s", err)
+	}
+}
+
+func TestNormalizeIBAN(t *testing.T) {
+	cases := map[string]string{
+		"DE89 3704 0044 0532 0130 00":        "DE89370400440532013000",
+		"gb82west12345698765432":             "GB82WEST12345698765432",
+		"PL61 1090 1014 0000 0712 1981 2874": "PL61109010140000071219812874",
+		"DE89 3704 0044 0532 0130 01":        "",
+		"DE89 3704 0044 0532 0130":           "",
+		"DE89-3704-0044-0532-0130-00":        "",
+		"":                                   "",
+	}
+	for input, want := range cases {
+		got, err := normalizeIBAN(input)
+		if want == "" {
+			if err == nil {
+				t.Errorf("%q: want error, got %q", input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", input, err)
+		} else if got != want {
+			t.Errorf("%q: want %q, got %q", input, want, got)
+		}
+	}
+}
+
+func TestEInvoiceTotalsMatchHTML(t *testing.T) {
+	entries, err := wlog.Parse(strings.NewReader(`# 1 Nov 2021 Monday
+20m a +one
+20m b +two
+20m c +three
+`))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	c := validEInvoiceContext()
+	c.ItemRate = 100
+	c.GroupBy = "project"
+	if err := populateFromLog(&c, entries, nil); err != nil {
+		t.Fatalf("populate: %s", err)
+	}
+
+	var html, ubl bytes.Buffer
+	if err := invoiceRenderers["html"](&html, c); err != nil {
+		t.Fatalf("render html: %s", err)
+	}
+	if err := renderInvoiceUBL(&ubl, c); err != nil {
+		t.Fatalf("render ubl: %s", err)
+	}
+	// Three items of 33.33 each, not 100 as the unrounded sum.
+	if want := c.Money(118.99); !strings.Contains(html.String(), want) {
+		t.Errorf("html document does not contain the %s total", want)
+	}
+	if want := `<cbc:PayableAmount currencyID="EUR">118.99</cbc:PayableAmount>`; !strings.Contains(ubl.String(), want) {
+		t.Errorf("ubl document does not contain %s", want)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"image/png"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/husio/worklog/qrcode"
+)
+
+// giroCodePayload returns the content of the GiroCode, a SEPA credit
+// transfer QR code as defined by the EPC069-12 guideline. The remittance
+// text is the invoice number.
+func giroCodePayload(c *TemplateContext) (string, error) {
+	name := strings.TrimSpace(c.PaymentName)
+	if name == "" {
+		return "", errors.New("PaymentName is required")
+	}
+	if utf8.RuneCountInString(name) > 70 {
+		return "", errors.New("PaymentName must not be longer than 70 characters")
+	}
+	iban, err := normalizeIBAN(c.PaymentIBAN)
+	if err != nil {
+		return "", fmt.Errorf("PaymentIBAN: %w", err)
+	}
+	if c.Currency != "" && !strings.EqualFold(c.Currency, "EUR") {
+		return "", fmt.Errorf("Currency: GiroCode requires EUR, got %s", c.Currency)
+	}
+	bic := strings.ToUpper(strings.Join(strings.Fields(c.PaymentBIC), ""))
+	if bic != "" && len(bic) != 8 && len(bic) != 11 {
+		return "", fmt.Errorf("PaymentBIC: %q is not a valid BIC, it must have 8 or 11 characters", c.PaymentBIC)
+	}
+	amount := roundCents(c.Total)
+	if amount < 0.01 || amount > 999999999.99 {
+		return "", fmt.Errorf("total amount %s is out of the 0.01 to 999999999.99 range", formatMoney(amount))
+	}
+	if utf8.RuneCountInString(c.InvoiceNumber) > 140 {
+		return "", errors.New("invoice number must not be longer than 140 characters")
+	}
+
+	lines := []string{
+		"BCD",
+		"002",
+		"1", // UTF-8
+		"SCT",
+		bic,
+		name,
+		iban,
+		fmt.Sprintf("EUR%.2f", amount),
+		"", // Purpose
+		"", // Structured reference
+		c.InvoiceNumber,
+	}
+	payload := strings.Join(lines, "\n")
+	if len(payload) > 331 {
+		return "", errors.New("payment information is longer than 331 bytes")
+	}
+	return payload, nil
+}
+
+// giroCode returns the GiroCode of the invoice. EPC069-12 requires the
+// medium error correction level.
+func giroCode(c *TemplateContext) (*qrcode.Code, error) {
+	payload, err := giroCodePayload(c)
+	if err != nil {
+		return nil, err
+	}
+	return qrcode.Encode([]byte(payload), qrcode.M)
+}
+
+// populateGiroCode sets the GiroCode image of the invoice, encoded as a data
+// URI that can be embedded in the html invoice.
+func populateGiroCode(c *TemplateContext) error {
+	code, err := giroCode(c)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, code.Image(4)); err != nil {
+		return fmt.Errorf("encode png: %w", err)
+	}
+	c.GiroCodeImage = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(b.Bytes()))
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGiroCodePayload(t *testing.T) {
+	c := TemplateContext{
+		InvoiceNumber: "2021-0001",
+		PaymentName:   "Jane Doe",
+		PaymentIBAN:   "DE89 3704 0044 0532 0130 00",
+		PaymentBIC:    "COBADEFFXXX",
+		Total:         1497.905,
+	}
+	got, err := giroCodePayload(&c)
+	if err != nil {
+		t.Fatalf("payload: %s", err)
+	}
+	want := "BCD\n002\n1\nSCT\nCOBADEFFXXX\nJane Doe\nDE89370400440532013000\nEUR1497.91\n\n\n2021-0001"
+	if got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+
+	if err := populateGiroCode(&c); err != nil {
+		t.Fatalf("populate: %s", err)
+	}
+	if !strings.HasPrefix(string(c.GiroCodeImage), "data:image/png;base64,") {
+		t.Fatalf("unexpected image: %.40s", c.GiroCodeImage)
+	}
+}
+
+func TestGiroCodePayloadErrors(t *testing.T) {
+	valid := TemplateContext{
+		InvoiceNumber: "2021-0001",
+		PaymentName:   "Jane Doe",
+		PaymentIBAN:   "DE89370400440532013000",
+		Total:         100,
+	}
+	cases := map[string]func(c *TemplateContext){
+		"missing name":    func(c *TemplateContext) { c.PaymentName = "" },
+		"long name":       func(c *TemplateContext) { c.PaymentName = strings.Repeat("x", 71) },
+		"invalid iban":    func(c *TemplateContext) { c.PaymentIBAN = "DE00370400440532013000" },
+		"invalid bic":     func(c *TemplateContext) { c.PaymentBIC = "COBADE" },
+		"zero total":      func(c *TemplateContext) { c.Total = 0 },
+		"long remittance": func(c *TemplateContext) { c.InvoiceNumber = strings.Repeat("1", 141) },
+	}
+	for name, modify := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := valid
+			modify(&c)
+			if _, err := giroCodePayload(&c); err == nil {
+				t.Fatal("want error")
+			}
+		})
+	}
+}
@@ -24,11 +24,9 @@ func main() {
 		os.Exit(2)
 	}
 
-	input, err := worklogReader(os.Stdin)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	}
+	// The worklog is opened on the first read, so that commands that do not
+	// need it can run without one.
+	input := &worklogInput{stdin: os.Stdin}
 	defer input.Close()
 
 	// Skip first two arguments. Second argument is the command name that
@@ -41,12 +39,25 @@ func main() {
 
 // A list of all registered commands available by this program.
 var commands = map[string]func(input io.Reader, output io.Writer, args []string) error{
-	"filter":  cmdFilter,
-	"fmt":     cmdFmt,
-	"invoice": cmdInvoice,
-	"open":    cmdOpen,
-	"push":    cmdPush,
-	"summary": cmdSummary,
+	"balance":   cmdBalance,
+	"diff":      cmdDiff,
+	"filter":    cmdFilter,
+	"fmt":       cmdFmt,
+	"history":   cmdHistory,
+	"holidays":  cmdHolidays,
+	"invoice":   cmdInvoice,
+	"invoices":  cmdInvoices,
+	"leave":     cmdLeave,
+	"lint":      cmdLint,
+	"open":      cmdOpen,
+	"pull":      cmdPull,
+	"push":      cmdPush,
+	"restore":   cmdRestore,
+	"serve":     cmdServe,
+	"summary":   cmdSummary,
+	"sync":      cmdSync,
+	"tags":      cmdTags,
+	"templates": cmdTemplates,
 }
 
 // availableCmds returns a sorted list of all available commands.
@@ -63,25 +74,82 @@ func availableCmds() []string {
 // content is being piped and if not use the default location configured via
 // the WORKLOG environment variable.
 func worklogReader(r io.ReadCloser) (io.ReadCloser, error) {
-	if s, ok := r.(interface{ Stat() (os.FileInfo, error) }); ok {
-		if info, err := s.Stat(); err == nil {
-			if isPipe := (info.Mode() & os.ModeCharDevice) == 0; isPipe {
-				return r, nil
-			}
-		}
+	if isPiped(r) {
+		return r, nil
 	}
 	pathOrURL := worklogPath()
-	if strings.HasPrefix(pathOrURL, "http://") || strings.HasPrefix(pathOrURL, "https://") {
-		resp, err := http.Get(pathOrURL)
+	if isURL(pathOrURL) {
+		req, err := http.NewRequest("GET", pathOrURL, nil)
 		if err != nil {
 			return nil, err
 		}
+		// Read token of the worklog storage server.
+		if token := os.Getenv("WORKLOG_TOKEN"); token != "" {
+			req.Header.Set("read-token", token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("cannot read worklog: http response: %s", resp.Status)
+		}
 		return resp.Body, nil
 	} else {
 		return os.Open(pathOrURL)
 	}
 }
 
+// isURL returns true if given worklog location is a HTTP address.
+func isURL(pathOrURL string) bool {
+	return strings.HasPrefix(pathOrURL, "http://") || strings.HasPrefix(pathOrURL, "https://")
+}
+
+// isPiped returns true if given reader is a file that the content is being
+// piped to.
+func isPiped(r io.Reader) bool {
+	if s, ok := r.(interface{ Stat() (os.FileInfo, error) }); ok {
+		if info, err := s.Stat(); err == nil {
+			return (info.Mode() & os.ModeCharDevice) == 0
+		}
+	}
+	return false
+}
+
+// worklogInput is the worklog reader that opens the worklog on the first
+// read.
+type worklogInput struct {
+	stdin io.ReadCloser
+	rc    io.ReadCloser
+	err   error
+}
+
+func (in *worklogInput) Read(b []byte) (int, error) {
+	if in.rc == nil && in.err == nil {
+		in.rc, in.err = worklogReader(in.stdin)
+	}
+	if in.err != nil {
+		return 0, in.err
+	}
+	return in.rc.Read(b)
+}
+
+func (in *worklogInput) Close() error {
+	if in.rc == nil {
+		return nil
+	}
+	return in.rc.Close()
+}
+
+// Name returns the worklog location.
+func (in *worklogInput) Name() string {
+	if isPiped(in.stdin) {
+		return "<stdin>"
+	}
+	return worklogPath()
+}
+
 func worklogPath() string {
 	path, ok := os.LookupEnv("WORKLOG")
 	if ok {
@@ -89,3 +157,12 @@ func worklogPath() string {
 	}
 	return filepath.Join(os.Getenv("HOME"), "/worklog.txt")
 }
+
+// inputName returns a human readable name of the worklog input, suitable for
+// error messages.
+func inputName(input io.Reader) string {
+	if n, ok := input.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return "<input>"
+}
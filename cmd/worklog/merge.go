@@ -0,0 +1,544 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/husio/worklog/wlog"
+)
+
+// mergedDay is a single day of the three-way merge result.
+type mergedDay struct {
+	// entry is the merged day. It is nil if the day was removed or the day
+	// conflicts as a whole.
+	entry *wlog.Entry
+	// segments hold tasks of the merged day, some of which can conflict.
+	segments []mergeSegment
+
+	// local and remote are set if the day conflicts as a whole, for
+	// example because it was removed on one side and changed on the other.
+	// Either can be nil.
+	conflict      bool
+	local, remote *wlog.Entry
+}
+
+// mergeSegment is a run of merged tasks of a day. If conflict is set, local
+// and remote hold the conflicting versions of the tasks.
+type mergeSegment struct {
+	tasks         []*wlog.Task
+	conflict      bool
+	local, remote []*wlog.Task
+}
+
+// mergeEntries merges the local and the remote worklog, that both derive
+// from the base. Days are matched by the date and tasks of a day are merged
+// the same way lines are merged by diff3. Changes of different days or of
+// different tasks of the same day are combined. Tasks added on both sides at
+// the same place are all kept. Other changes made on both sides to the
+// same day or task conflict. Base can be nil if the worklogs were never
+// synchronized.
+func mergeEntries(base, local, remote []*wlog.Entry) ([]mergedDay, int) {
+	baseDays := entriesByDay(base)
+	localDays := entriesByDay(local)
+	remoteDays := entriesByDay(remote)
+
+	seen := make(map[string]bool)
+	var days []string
+	for _, m := range []map[string]*wlog.Entry{baseDays, localDays, remoteDays} {
+		for day := range m {
+			if !seen[day] {
+				seen[day] = true
+				days = append(days, day)
+			}
+		}
+	}
+	sort.Strings(days)
+
+	var (
+		merged    []mergedDay
+		conflicts int
+	)
+	for _, day := range days {
+		b, l, r := baseDays[day], localDays[day], remoteDays[day]
+		var m mergedDay
+		switch {
+		case sameEntry(l, r), sameEntry(r, b):
+			m.entry = l
+		case sameEntry(l, b):
+			m.entry = r
+		case l == nil || r == nil:
+			m = mergedDay{conflict: true, local: l, remote: r}
+		default:
+			m = mergeDay(b, l, r)
+		}
+		if m.entry == nil && !m.conflict {
+			continue
+		}
+		if m.entry != nil && m.segments == nil {
+			m.segments = []mergeSegment{{tasks: m.entry.Tasks}}
+		}
+		if m.conflict {
+			conflicts++
+		}
+		for _, s := range m.segments {
+			if s.conflict {
+				conflicts++
+			}
+		}
+		merged = append(merged, m)
+	}
+	return merged, conflicts
+}
+
+// mergeDay merges a day changed both locally and remotely.
+func mergeDay(b, l, r *wlog.Entry) mergedDay {
+	status := l.Status
+	if l.Status != r.Status {
+		switch {
+		case b != nil && l.Status == b.Status:
+			status = r.Status
+		case b != nil && r.Status == b.Status:
+			status = l.Status
+		default:
+			return mergedDay{conflict: true, local: l, remote: r}
+		}
+	}
+
+	var baseTasks []*wlog.Task
+	if b != nil {
+		baseTasks = b.Tasks
+	}
+	// Without the base, tasks added on both sides are most likely the same
+	// work logged twice.
+	segments := mergeTasks(baseTasks, l.Tasks, r.Tasks, b != nil)
+
+	entry := &wlog.Entry{Day: l.Day, Status: status}
+	for _, s := range segments {
+		entry.Tasks = append(entry.Tasks, s.tasks...)
+	}
+	return mergedDay{entry: entry, segments: segments}
+}
+
+// hasConflictingTasks returns true if tasks of the day conflict.
+func hasConflictingTasks(d mergedDay) bool {
+	for _, s := range d.segments {
+		if s.conflict {
+			return true
+		}
+	}
+	return false
+}
+
+// sameEntry returns true if both entries describe the same day content. Nil
+// entry is a missing day.
+func sameEntry(a, b *wlog.Entry) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if a.Status != b.Status || len(a.Tasks) != len(b.Tasks) {
+		return false
+	}
+	for i := range a.Tasks {
+		if taskKey(a.Tasks[i]) != taskKey(b.Tasks[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func taskKey(t *wlog.Task) string {
+	return wlog.FormatDuration(t.Duration, wlog.DurationHM) + " " + t.Description
+}
+
+func taskKeys(tasks []*wlog.Task) []string {
+	keys := make([]string, len(tasks))
+	for i, t := range tasks {
+		keys[i] = taskKey(t)
+	}
+	return keys
+}
+
+// taskHunk is a change of one side: tasks in the base range [start, end)
+// are replaced with tasks.
+type taskHunk struct {
+	start, end int
+	tasks      []*wlog.Task
+}
+
+// taskHunks returns changes that transform base into side tasks.
+func taskHunks(base, side []*wlog.Task) []taskHunk {
+	var (
+		hunks []taskHunk
+		hunk  *taskHunk
+	)
+	for _, op := range diffLines(taskKeys(base), taskKeys(side)) {
+		if op.kind == ' ' {
+			hunk = nil
+			continue
+		}
+		if hunk == nil {
+			hunks = append(hunks, taskHunk{start: op.from, end: op.from})
+			hunk = &hunks[len(hunks)-1]
+		}
+		if op.kind == '-' {
+			hunk.end++
+		} else {
+			hunk.tasks = append(hunk.tasks, side[op.to])
+		}
+	}
+	return hunks
+}
+
+// applyHunks returns base tasks of the range [start, end) with hunks
+// applied.
+func applyHunks(base []*wlog.Task, start, end int, hunks []taskHunk) []*wlog.Task {
+	var tasks []*wlog.Task
+	pos := start
+	for _, h := range hunks {
+		tasks = append(tasks, base[pos:h.start]...)
+		tasks = append(tasks, h.tasks...)
+		pos = h.end
+	}
+	return append(tasks, base[pos:end]...)
+}
+
+// mergeTasks merges tasks of a day changed on both sides. Local and remote
+// changes are grouped into regions of the base that they overlap. A region
+// changed on one side only takes that change, a region changed on both
+// sides conflicts, unless both made the same change or, if unionInserts is
+// set, both only added tasks.
+func mergeTasks(base, local, remote []*wlog.Task, unionInserts bool) []mergeSegment {
+	lh, rh := taskHunks(base, local), taskHunks(base, remote)
+
+	var segments []mergeSegment
+	resolved := func(tasks []*wlog.Task) {
+		if len(tasks) == 0 {
+			return
+		}
+		if n := len(segments); n > 0 && !segments[n-1].conflict {
+			segments[n-1].tasks = append(segments[n-1].tasks, tasks...)
+			return
+		}
+		segments = append(segments, mergeSegment{tasks: append([]*wlog.Task(nil), tasks...)})
+	}
+
+	pos := 0
+	for len(lh) > 0 || len(rh) > 0 {
+		var start int
+		switch {
+		case len(rh) == 0 || (len(lh) > 0 && lh[0].start <= rh[0].start):
+			start = lh[0].start
+		default:
+			start = rh[0].start
+		}
+		// Grow the region until no other change overlaps it.
+		end := start
+		var inLocal, inRemote []taskHunk
+		for grown := true; grown; {
+			grown = false
+			if len(lh) > 0 && (lh[0].start < end || lh[0].start == start) {
+				inLocal = append(inLocal, lh[0])
+				if lh[0].end > end {
+					end = lh[0].end
+				}
+				lh = lh[1:]
+				grown = true
+			}
+			if len(rh) > 0 && (rh[0].start < end || rh[0].start == start) {
+				inRemote = append(inRemote, rh[0])
+				if rh[0].end > end {
+					end = rh[0].end
+				}
+				rh = rh[1:]
+				grown = true
+			}
+		}
+
+		resolved(base[pos:start])
+		localTasks := applyHunks(base, start, end, inLocal)
+		remoteTasks := applyHunks(base, start, end, inRemote)
+		switch {
+		case len(inRemote) == 0:
+			resolved(localTasks)
+		case len(inLocal) == 0:
+			resolved(remoteTasks)
+		case strings.Join(taskKeys(localTasks), "\n") == strings.Join(taskKeys(remoteTasks), "\n"):
+			resolved(localTasks)
+		case unionInserts && start == end:
+			// Both sides only added tasks. Keep all of them, without
+			// duplicates.
+			added := make(map[string]bool)
+			for _, t := range localTasks {
+				added[taskKey(t)] = true
+			}
+			tasks := localTasks
+			for _, t := range remoteTasks {
+				if !added[taskKey(t)] {
+					tasks = append(tasks, t)
+				}
+			}
+			resolved(tasks)
+		default:
+			segments = append(segments, mergeSegment{conflict: true, local: localTasks, remote: remoteTasks})
+		}
+		pos = end
+	}
+	resolved(base[pos:])
+	return segments
+}
+
+// mergeText holds the source text of days and tasks of the merged worklogs,
+// so that the merge result keeps them as they were written. Text of the first
+// added worklog takes precedence.
+type mergeText struct {
+	headers map[string]string
+	tasks   map[string]string
+}
+
+func newMergeText() *mergeText {
+	return &mergeText{
+		headers: make(map[string]string),
+		tasks:   make(map[string]string),
+	}
+}
+
+// add remembers the source text of days and tasks of the document and
+// returns its entries.
+func (m *mergeText) add(f *wlog.File) []*wlog.Entry {
+	entries := f.Entries()
+	for _, e := range entries {
+		if key := headerKey(e); m.headers[key] == "" {
+			m.headers[key] = lineText(f.Lines[e.Line-1])
+		}
+		for _, t := range e.Tasks {
+			if key := taskKey(t); m.tasks[key] == "" {
+				m.tasks[key] = taskSource(f, t.Line)
+			}
+		}
+	}
+	return entries
+}
+
+// writeHeader writes the header of the day, as written in the source if
+// known.
+func (m *mergeText) writeHeader(b *bytes.Buffer, e *wlog.Entry) {
+	if text, ok := m.headers[headerKey(e)]; ok {
+		b.WriteString(text)
+		return
+	}
+	b.WriteString(e.Day.Format(wlog.TimeFormat))
+	if e.Status != wlog.StatusWork {
+		b.WriteString(" [" + string(e.Status) + "]")
+	}
+	b.WriteString("\n")
+}
+
+// writeTasks writes tasks, as written in the source if known.
+func (m *mergeText) writeTasks(b *bytes.Buffer, tasks []*wlog.Task, style wlog.DurationStyle) {
+	for _, t := range tasks {
+		if text, ok := m.tasks[taskKey(t)]; ok {
+			b.WriteString(text)
+			continue
+		}
+		writeTasksText(b, []*wlog.Task{t}, style)
+	}
+}
+
+func headerKey(e *wlog.Entry) string {
+	return e.Day.Format("2006-01-02") + " " + string(e.Status)
+}
+
+// lineText returns the source text of the line, always ending with a new
+// line character.
+func lineText(l *wlog.Line) string {
+	if l.EOL == "" {
+		return l.Text + "\n"
+	}
+	return l.Text + l.EOL
+}
+
+// taskSource returns the source text of the task starting at given line,
+// including its continuation lines.
+func taskSource(f *wlog.File, num int) string {
+	text := lineText(f.Lines[num-1])
+	var blank string
+	for _, l := range f.Lines[num:] {
+		switch l.Kind {
+		case wlog.ContinuationLine:
+			text += blank + lineText(l)
+			blank = ""
+		case wlog.BlankLine:
+			blank += lineText(l)
+		default:
+			return text
+		}
+	}
+	return text
+}
+
+// formatMerged returns the merge result in the worklog text format. The
+// local document is kept line by line, only days changed by the merge are
+// replaced and days added remotely are inserted in chronological order.
+// Conflicts are written between the conflict markers, with the local version
+// first.
+func formatMerged(local *wlog.File, days []mergedDay, text *mergeText, style wlog.DurationStyle) []byte {
+	// Ignore empty days, unless marked as a day off, same as when writing
+	// the worklog.
+	var nonEmpty []mergedDay
+	for _, d := range days {
+		if d.conflict || hasConflictingTasks(d) || d.entry.TotalDuration() != 0 || d.entry.Status != wlog.StatusWork {
+			nonEmpty = append(nonEmpty, d)
+		}
+	}
+	days = nonEmpty
+
+	localDays := entriesByDay(local.Entries())
+	merged := make(map[string]mergedDay, len(days))
+	for _, d := range days {
+		merged[mergedDayKey(d)] = d
+	}
+	changed := func(key string) bool {
+		d, ok := merged[key]
+		if !ok {
+			return localDays[key] != nil
+		}
+		return d.conflict || hasConflictingTasks(d) || !sameEntry(d.entry, localDays[key])
+	}
+
+	var (
+		b       bytes.Buffer
+		written = make(map[string]bool)
+		pending = days
+	)
+	lines := local.Lines
+	for i := 0; i < len(lines); {
+		if lines[i].Kind != wlog.HeaderLine {
+			b.WriteString(lines[i].Text + lines[i].EOL)
+			i++
+			continue
+		}
+		// The day lasts until the next header. Trailing blank lines
+		// separate it from the next day.
+		end, next := i+1, i+1
+		for ; next < len(lines) && lines[next].Kind != wlog.HeaderLine; next++ {
+			if lines[next].Kind != wlog.BlankLine {
+				end = next + 1
+			}
+		}
+		key := lines[i].Day.Format("2006-01-02")
+
+		// Days that are not in the local worklog go before the first
+		// local day that follows them.
+		for len(pending) > 0 && mergedDayKey(pending[0]) < key {
+			if k := mergedDayKey(pending[0]); localDays[k] == nil && !written[k] {
+				writeMergedDay(&b, pending[0], text, style)
+				b.WriteString("\n")
+				written[k] = true
+			}
+			pending = pending[1:]
+		}
+
+		switch d, ok := merged[key]; {
+		case !changed(key):
+			for _, l := range lines[i:next] {
+				b.WriteString(l.Text + l.EOL)
+			}
+		case ok && !written[key]:
+			writeMergedDay(&b, d, text, style)
+			for _, l := range lines[end:next] {
+				b.WriteString(l.Text + l.EOL)
+			}
+			written[key] = true
+		default:
+			// The day was removed, or was already written at its
+			// first header.
+		}
+		i = next
+	}
+	for _, d := range pending {
+		if k := mergedDayKey(d); localDays[k] == nil && !written[k] {
+			if b.Len() > 0 {
+				if !bytes.HasSuffix(b.Bytes(), []byte("\n")) {
+					b.WriteString("\n")
+				}
+				if !bytes.HasSuffix(b.Bytes(), []byte("\n\n")) {
+					b.WriteString("\n")
+				}
+			}
+			writeMergedDay(&b, d, text, style)
+			written[k] = true
+		}
+	}
+	return b.Bytes()
+}
+
+// mergedDayKey returns the day of the merge result in the entriesByDay key
+// format.
+func mergedDayKey(d mergedDay) string {
+	for _, e := range []*wlog.Entry{d.entry, d.local, d.remote} {
+		if e != nil {
+			return e.Day.Format("2006-01-02")
+		}
+	}
+	return ""
+}
+
+// writeMergedDay writes a single day of the merge result. A day that
+// conflicts as a whole has one header, with tasks of both versions between
+// the conflict markers.
+func writeMergedDay(b *bytes.Buffer, d mergedDay, text *mergeText, style wlog.DurationStyle) {
+	if d.conflict {
+		var localLabel, remoteLabel string
+		switch {
+		case d.local == nil:
+			localLabel = " (day removed)"
+		case d.remote == nil:
+			remoteLabel = " (day removed)"
+		case d.local.Status != d.remote.Status:
+			localLabel = " [" + statusName(d.local.Status) + "]"
+			remoteLabel = " [" + statusName(d.remote.Status) + "]"
+		}
+		header := d.local
+		if header == nil {
+			header = d.remote
+		}
+		text.writeHeader(b, header)
+		b.WriteString(wlog.ConflictStart + " local" + localLabel + "\n")
+		if d.local != nil {
+			text.writeTasks(b, d.local.Tasks, style)
+		}
+		b.WriteString(wlog.ConflictSeparator + "\n")
+		if d.remote != nil {
+			text.writeTasks(b, d.remote.Tasks, style)
+		}
+		b.WriteString(wlog.ConflictEnd + " remote" + remoteLabel + "\n")
+		return
+	}
+	text.writeHeader(b, d.entry)
+	for _, s := range d.segments {
+		if !s.conflict {
+			text.writeTasks(b, s.tasks, style)
+			continue
+		}
+		b.WriteString(wlog.ConflictStart + " local\n")
+		text.writeTasks(b, s.local, style)
+		b.WriteString(wlog.ConflictSeparator + "\n")
+		text.writeTasks(b, s.remote, style)
+		b.WriteString(wlog.ConflictEnd + " remote\n")
+	}
+}
+
+// writeTasksText writes tasks the same way wlog.ToText does.
+func writeTasksText(b *bytes.Buffer, tasks []*wlog.Task, style wlog.DurationStyle) {
+	for _, t := range tasks {
+		duration := wlog.FormatDuration(t.Duration, style)
+		lines := strings.Split(t.Description, "\n")
+		fmt.Fprintf(b, "%s %s\n", duration, strings.TrimSpace(lines[0]))
+		indent := strings.Repeat(" ", len(duration)+1)
+		for _, line := range lines[1:] {
+			b.WriteString(indent + strings.TrimSpace(line) + "\n")
+		}
+	}
+}
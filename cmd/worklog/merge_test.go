@@ -0,0 +1,202 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/husio/worklog/wlog"
+)
+
+func TestMergeEntries(t *testing.T) {
+	const base = `# 1 Nov 2021 Monday
+4h coding
+2h review
+
+# 2 Nov 2021 Tuesday
+8h coding
+`
+	cases := map[string]struct {
+		base, local, remote string
+		want                string
+		conflicts           int
+	}{
+		"different days": {
+			base:   base,
+			local:  base + "\n# 3 Nov 2021 Wednesday\n1h local\n",
+			remote: base + "\n# 4 Nov 2021 Thursday\n1h remote\n",
+			want: `# 1 Nov 2021 Monday
+4h coding
+2h review
+
+# 2 Nov 2021 Tuesday
+8h coding
+
+# 3 Nov 2021 Wednesday
+1h local
+
+# 4 Nov 2021 Thursday
+1h remote
+`,
+		},
+		"different tasks of the same day": {
+			base:   base,
+			local:  strings.Replace(base, "4h coding", "5h coding", 1),
+			remote: strings.Replace(base, "2h review\n", "2h review\n1h meeting\n", 1),
+			want: `# 1 Nov 2021 Monday
+5h coding
+2h review
+1h meeting
+
+# 2 Nov 2021 Tuesday
+8h coding
+`,
+		},
+		"tasks added on both sides": {
+			base:   base,
+			local:  base + "30m local\n",
+			remote: base + "1h remote\n",
+			want: `# 1 Nov 2021 Monday
+4h coding
+2h review
+
+# 2 Nov 2021 Tuesday
+8h coding
+30m local
+1h remote
+`,
+		},
+		"status and task changes": {
+			base:   base,
+			local:  strings.Replace(base, "Tuesday", "Tuesday [half-day]", 1),
+			remote: strings.Replace(base, "8h coding", "4h coding", 1),
+			want: `# 1 Nov 2021 Monday
+4h coding
+2h review
+
+# 2 Nov 2021 Tuesday [half-day]
+4h coding
+`,
+		},
+		"same task changed on both sides": {
+			base:   base,
+			local:  strings.Replace(base, "2h review", "3h review", 1),
+			remote: strings.Replace(base, "2h review", "1h review", 1),
+			want: `# 1 Nov 2021 Monday
+4h coding
+<<<<<<< local
+3h review
+=======
+1h review
+>>>>>>> remote
+
+# 2 Nov 2021 Tuesday
+8h coding
+`,
+			conflicts: 1,
+		},
+		"day removed and changed": {
+			base:   base,
+			local:  "# 1 Nov 2021 Monday\n4h coding\n2h review\n",
+			remote: strings.Replace(base, "8h coding", "7h coding", 1),
+			want: `# 1 Nov 2021 Monday
+4h coding
+2h review
+
+# 2 Nov 2021 Tuesday
+<<<<<<< local (day removed)
+=======
+7h coding
+>>>>>>> remote
+`,
+			conflicts: 1,
+		},
+		"status changed on both sides": {
+			base:   base,
+			local:  strings.Replace(base, "Tuesday", "Tuesday [sick]", 1),
+			remote: strings.Replace(base, "Tuesday\n8h coding", "Tuesday [vacation]", 1),
+			want: `# 1 Nov 2021 Monday
+4h coding
+2h review
+
+# 2 Nov 2021 Tuesday [sick]
+<<<<<<< local [sick]
+8h coding
+=======
+>>>>>>> remote [vacation]
+`,
+			conflicts: 1,
+		},
+		"local text kept": {
+			base: base,
+			local: `Notes about the worklog.
+
+# 1 Nov 2021 Monday
+4h coding
+2h   review;  with Jane
+call the bank
+
+# 2 Nov 2021 Tuesday
+8h coding
+`,
+			remote: strings.Replace(base, "8h coding", "7h coding\n1h meeting", 1) + "\n# 3 Nov 2021 Wednesday\n1h remote\n",
+			want: `Notes about the worklog.
+
+# 1 Nov 2021 Monday
+4h coding
+2h   review;  with Jane
+call the bank
+
+# 2 Nov 2021 Tuesday
+7h coding
+1h meeting
+
+# 3 Nov 2021 Wednesday
+1h remote
+`,
+		},
+		"remote day inserted in order": {
+			base:   base,
+			local:  base + "\n# 4 Nov 2021 Thursday\n1h local\n",
+			remote: base + "\n# 3 Nov 2021 Wednesday\n1h remote\n",
+			want:   base + "\n# 3 Nov 2021 Wednesday\n1h remote\n\n# 4 Nov 2021 Thursday\n1h local\n",
+		},
+		"no base": {
+			local:  "# 1 Nov 2021 Monday\n4h coding\n\n# 2 Nov 2021 Tuesday\n1h local\n",
+			remote: "# 1 Nov 2021 Monday\n4h coding\n\n# 2 Nov 2021 Tuesday\n2h remote\n",
+			want: `# 1 Nov 2021 Monday
+4h coding
+
+# 2 Nov 2021 Tuesday
+<<<<<<< local
+1h local
+=======
+2h remote
+>>>>>>> remote
+`,
+			conflicts: 1,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			parse := func(s string) *wlog.File {
+				t.Helper()
+				f, err := wlog.ParseFile(strings.NewReader(s))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return f
+			}
+			local := parse(tc.local)
+			text := newMergeText()
+			localEntries := text.add(local)
+			remoteEntries := text.add(parse(tc.remote))
+			days, conflicts := mergeEntries(parse(tc.base).Entries(), localEntries, remoteEntries)
+			if got := string(formatMerged(local, days, text, wlog.DurationHM)); got != tc.want {
+				t.Fatalf("want\n%s\ngot\n%s", tc.want, got)
+			}
+			if conflicts != tc.conflicts {
+				t.Fatalf("want %d conflicts, got %d", tc.conflicts, conflicts)
+			}
+		})
+	}
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"image/png"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/husio/worklog/pdf"
+	"github.com/husio/worklog/qrcode"
+	"github.com/husio/worklog/wlog"
+)
+
+// renderInvoicePDF writes the invoice as a PDF document.
+func renderInvoicePDF(w io.Writer, c TemplateContext) error {
+	doc, err := invoicePDF(c)
+	if err != nil {
+		return err
+	}
+	if _, err := doc.WriteTo(w); err != nil {
+		return fmt.Errorf("write pdf: %w", err)
+	}
+	return nil
+}
+
+// invoicePDF returns the invoice document. The layout follows the html
+// invoice template.
+func invoicePDF(c TemplateContext) (*pdf.Document, error) {
+	doc := pdf.New()
+	doc.Title = "Invoice " + c.InvoiceNumber
+	doc.Author = c.FromName
+	doc.AddPage()
+	left := doc.Margin
+	width := pdf.PageWidth - 2*doc.Margin
+
+	doc.SetFont(pdf.Bold, 22)
+	doc.Text(left, doc.Y()+22, "Invoice")
+
+	// Summary is placed in the top right corner, next to the title.
+	summaryX := left + width - 220
+	doc.SetFont(pdf.Regular, 10)
+	y := doc.Y()
+	for _, kv := range [][2]string{
+		{"Debtor", c.Debtor},
+		{"Invoice Number", c.InvoiceNumber},
+		{"Invoice Date", c.InvoiceDate},
+	} {
+		doc.SetFont(pdf.Bold, 10)
+		doc.Text(summaryX, y+10, kv[0])
+		doc.SetFont(pdf.Regular, 10)
+		doc.Text(summaryX+90, y+10, kv[1])
+		y += doc.LineHeight()
+	}
+	doc.SetY(y)
+
+	pdfSection(doc, "To", [][2]string{
+		{"Company", c.ToCompany},
+		{"Address", c.ToAddress},
+		{"c/o", c.ToCo},
+		{"VAT-ID", c.ToVATID},
+	})
+	from := [][2]string{
+		{"Name", c.FromName},
+		{"Address", c.FromAddress},
+		{"Country", c.FromCountry},
+		{"Tax-ID", c.FromTaxID},
+	}
+	if c.FromVATID != "" {
+		from = append(from, [2]string{"VAT-ID", c.FromVATID})
+	}
+	if c.FromEmail != "" {
+		from = append(from, [2]string{"Email", c.FromEmail})
+	}
+	pdfSection(doc, "From", from)
+	payment := [][2]string{
+		{"Name", c.PaymentName},
+		{"IBAN", c.PaymentIBAN},
+		{"BIC", c.PaymentBIC},
+		{"Bank Name", c.PaymentBankName},
+	}
+	if c.PaymentTerms != "" {
+		payment = append(payment, [2]string{"Terms", c.PaymentTerms})
+	}
+	if c.GiroCode {
+		code, err := giroCode(&c)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create GiroCode: %w", err)
+		}
+		// The code is placed on the right side of the payment details.
+		const size = 85
+		pdfHeading(doc, "Payment Information")
+		doc.EnsureSpace(size + doc.LineHeight())
+		top := doc.Y()
+		pdfFields(doc, payment, width-size-10)
+		pdfQRCode(doc, code, left+width-size, top, size)
+		doc.SetFont(pdf.Regular, 8)
+		doc.TextAligned(left+width-size, top+size+8, size, "Scan to pay", pdf.AlignCenter)
+		doc.SetFont(pdf.Regular, 10)
+		doc.SetY(math.Max(doc.Y(), top+size+doc.LineHeight()))
+	} else {
+		pdfSection(doc, "Payment Information", payment)
+	}
+
+	pdfHeading(doc, "Details")
+	columns := []pdf.Column{
+		{Header: "Item", Width: 35},
+		{Header: "Description", Width: width - 35 - 60 - 35 - 80 - 85},
+		{Header: "Quantity", Width: 60, Align: pdf.AlignRight},
+		{Header: "Unit", Width: 35},
+		{Header: "Rate", Width: 80, Align: pdf.AlignRight},
+		{Header: "Total", Width: 85, Align: pdf.AlignRight},
+	}
+	var rows []pdf.Row
+	for i, it := range c.Items {
+		rows = append(rows, pdf.Row{Cells: []string{
+			fmt.Sprint(i + 1),
+			htmlToText(it.Description),
+			c.Quantity(it.Quantity),
+			it.Unit,
+			c.Money(it.Rate),
+			c.Money(it.Total),
+		}})
+	}
+	if c.GroupBy != "" {
+		rows = append(rows, pdf.Row{Cells: []string{"", "(" + c.Period + ")"}})
+	}
+	rows = append(rows, pdf.Row{Cells: []string{
+		"", "Subtotal", c.Quantity(c.ItemHours), "h", "", c.Money(c.ItemTotal),
+	}})
+	if c.VATPaymentPerc != 0 {
+		rows = append(rows, pdf.Row{Cells: []string{
+			"", "VAT", "", "", fmt.Sprintf("%d%%", c.VATPaymentPerc), c.Money(c.VATTotal),
+		}})
+	}
+	rows = append(rows, pdf.Row{Bold: true, Cells: []string{
+		"", "TOTAL", "", "", "Due", c.Money(c.Total),
+	}})
+	doc.Table(left, columns, rows)
+
+	doc.SetY(doc.Y() + 20)
+	if c.Conversion != "" {
+		doc.Paragraph(left, width, c.Conversion, pdf.AlignLeft)
+		doc.SetY(doc.Y() + 10)
+	}
+	if c.BottomNote != "" {
+		doc.Paragraph(left, width, htmlToText(c.BottomNote), pdf.AlignLeft)
+		doc.SetY(doc.Y() + 10)
+	}
+	doc.Paragraph(left, width, "Thank you for your business!", pdf.AlignLeft)
+
+	if c.SignatureBase64 != "" {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(c.SignatureBase64))
+		if err != nil {
+			return nil, fmt.Errorf("decode signature: %w", err)
+		}
+		img, err := png.Decode(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("decode signature: %w", err)
+		}
+		// Images are assumed to be 96 DPI and scaled down to fit.
+		b := img.Bounds()
+		w, h := float64(b.Dx())*0.75, float64(b.Dy())*0.75
+		if w > 200 {
+			w, h = 200, h*200/w
+		}
+		doc.SetY(doc.Y() + 10)
+		doc.EnsureSpace(h)
+		doc.Image(img, left, doc.Y(), w, h)
+		doc.SetY(doc.Y() + h)
+	}
+
+	// QR-bill payment part takes the bottom of the last page, over the
+	// page number.
+	if c.QRBillPart != nil && doc.Y() > pdf.PageHeight-qrBillHeight {
+		doc.AddPage()
+	}
+	pdfPageNumbers(doc)
+	if c.QRBillPart != nil {
+		doc.SetPage(doc.PageCount())
+		pdfQRBill(doc, c.QRBillPart)
+	}
+	return doc, nil
+}
+
+// mm is the size of one millimeter in points.
+const mm = 72 / 25.4
+
+// qrBillHeight is the height of the QR-bill receipt and payment part.
+const qrBillHeight = 105 * mm
+
+// pdfQRBill draws the Swiss QR-bill receipt and payment part at the bottom
+// of the current page, using the layout defined by the Swiss Implementation
+// Guidelines for the QR-bill.
+func pdfQRBill(doc *pdf.Document, part *QRBillPart) {
+	top := pdf.PageHeight - qrBillHeight
+	doc.FillRect(0, top, pdf.PageWidth, qrBillHeight, pdf.Gray(1))
+	pdfDashedLine(doc, 0, top, pdf.PageWidth, top)
+	pdfDashedLine(doc, 62*mm, top, 62*mm, pdf.PageHeight)
+
+	// Receipt.
+	left := 5 * mm
+	doc.SetFont(pdf.Bold, 11)
+	doc.Text(left, top+5*mm+11, "Receipt")
+	y := top + 12*mm
+	y = pdfQRBillField(doc, left, y, 52*mm, 6, 8, "Account / Payable to", append([]string{part.Account}, part.Creditor...))
+	if part.Reference != "" {
+		y = pdfQRBillField(doc, left, y, 52*mm, 6, 8, "Reference", []string{part.Reference})
+	}
+	pdfQRBillField(doc, left, y, 52*mm, 6, 8, "Payable by", part.Debtor)
+	pdfQRBillField(doc, left, top+68*mm, 12*mm, 6, 8, "Currency", []string{part.Currency})
+	pdfQRBillField(doc, left+12*mm, top+68*mm, 40*mm, 6, 8, "Amount", []string{part.Amount})
+	doc.SetFont(pdf.Bold, 6)
+	doc.TextAligned(left, top+82*mm+6, 52*mm, "Acceptance point", pdf.AlignRight)
+
+	// Payment part.
+	left = 67 * mm
+	doc.SetFont(pdf.Bold, 11)
+	doc.Text(left, top+5*mm+11, "Payment part")
+	pdfSwissQRCode(doc, part.code, left, top+17*mm, 46*mm)
+	pdfQRBillField(doc, left, top+68*mm, 15*mm, 8, 10, "Currency", []string{part.Currency})
+	pdfQRBillField(doc, left+15*mm, top+68*mm, 36*mm, 8, 10, "Amount", []string{part.Amount})
+	left = 118 * mm
+	y = top + 5*mm
+	y = pdfQRBillField(doc, left, y, 87*mm, 8, 10, "Account / Payable to", append([]string{part.Account}, part.Creditor...))
+	if part.Reference != "" {
+		y = pdfQRBillField(doc, left, y, 87*mm, 8, 10, "Reference", []string{part.Reference})
+	}
+	y = pdfQRBillField(doc, left, y, 87*mm, 8, 10, "Additional information", []string{part.Message})
+	pdfQRBillField(doc, left, y, 87*mm, 8, 10, "Payable by", part.Debtor)
+	doc.SetFont(pdf.Regular, 10)
+}
+
+// pdfQRBillField writes the QR-bill heading followed by the value lines and
+// returns the position of the next field.
+func pdfQRBillField(doc *pdf.Document, x, y, width, headingSize, size float64, heading string, lines []string) float64 {
+	doc.SetFont(pdf.Bold, headingSize)
+	y += headingSize
+	doc.Text(x, y, heading)
+	doc.SetFont(pdf.Regular, size)
+	for _, line := range lines {
+		for _, l := range doc.WrapText(line, width) {
+			y += size + 1
+			doc.Text(x, y, l)
+		}
+	}
+	return y + size
+}
+
+// pdfDashedLine draws a dashed line, that marks where the QR-bill payment
+// part is separated.
+func pdfDashedLine(doc *pdf.Document, x1, y1, x2, y2 float64) {
+	const dash = 3
+	length := math.Hypot(x2-x1, y2-y1)
+	dx, dy := (x2-x1)/length, (y2-y1)/length
+	for d := 0.0; d < length; d += 2 * dash {
+		end := math.Min(d+dash, length)
+		doc.Line(x1+dx*d, y1+dy*d, x1+dx*end, y1+dy*end, 0.5)
+	}
+}
+
+// pdfSwissQRCode draws the code without the quiet zone and with the Swiss
+// cross in the middle.
+func pdfSwissQRCode(doc *pdf.Document, code *qrcode.Code, x, y, size float64) {
+	module := size / float64(code.Size)
+	pdfModules(doc, code, x, y, module)
+	pos, cross := swissCross(code)
+	pos, cross = pos*module, cross*module
+	unit := cross / 32
+	doc.FillRect(x+pos, y+pos, cross, cross, pdf.Gray(1))
+	doc.FillRect(x+pos+2*unit, y+pos+2*unit, cross-4*unit, cross-4*unit, pdf.Black)
+	doc.FillRect(x+pos+13*unit, y+pos+8*unit, 6*unit, 16*unit, pdf.Gray(1))
+	doc.FillRect(x+pos+8*unit, y+pos+13*unit, 16*unit, 6*unit, pdf.Gray(1))
+}
+
+// pdfSection writes a heading followed by label and value pairs.
+func pdfSection(doc *pdf.Document, title string, rows [][2]string) {
+	pdfHeading(doc, title)
+	pdfFields(doc, rows, pdf.PageWidth-2*doc.Margin)
+}
+
+// pdfFields writes label and value pairs, wrapped to given width.
+func pdfFields(doc *pdf.Document, rows [][2]string, width float64) {
+	left := doc.Margin
+	const labelWidth = 90
+	for _, kv := range rows {
+		lines := doc.WrapText(kv[1], width-labelWidth)
+		doc.EnsureSpace(float64(len(lines)) * doc.LineHeight())
+		doc.SetFont(pdf.Bold, 10)
+		doc.Text(left+pdf.CellPadding, doc.Y()+10, kv[0])
+		doc.SetFont(pdf.Regular, 10)
+		doc.Paragraph(left+labelWidth, width-labelWidth, kv[1], pdf.AlignLeft)
+	}
+}
+
+// pdfQRCode draws the code with its quiet zone as a square of given size.
+// X and Y point at the top left corner.
+func pdfQRCode(doc *pdf.Document, code *qrcode.Code, x, y, size float64) {
+	module := size / float64(code.Size+2*qrcode.QuietZone)
+	pdfModules(doc, code, x+qrcode.QuietZone*module, y+qrcode.QuietZone*module, module)
+}
+
+// pdfModules draws dark modules of the code, starting at the top left
+// corner.
+func pdfModules(doc *pdf.Document, code *qrcode.Code, x, y, module float64) {
+	for my := 0; my < code.Size; my++ {
+		for mx := 0; mx < code.Size; mx++ {
+			if code.Black(mx, my) {
+				doc.FillRect(x+float64(mx)*module, y+float64(my)*module, module, module, pdf.Black)
+			}
+		}
+	}
+}
+
+// pdfHeading writes the section heading, styled as the h2 element of the
+// html invoice.
+func pdfHeading(doc *pdf.Document, title string) {
+	const height = 20
+	left := doc.Margin
+	width := pdf.PageWidth - 2*doc.Margin
+	// Heading must not be the last line of the page.
+	doc.EnsureSpace(height + 30 + 3*doc.LineHeight())
+	doc.SetY(doc.Y() + 24)
+	doc.FillRect(left, doc.Y(), width, height, pdf.Gray(0.96))
+	doc.Line(left, doc.Y()+height, left+width, doc.Y()+height, 0.75)
+	doc.SetFont(pdf.Bold, 12)
+	doc.Text(left+pdf.CellPadding, doc.Y()+14.5, title)
+	doc.SetFont(pdf.Regular, 10)
+	doc.SetY(doc.Y() + height + 8)
+}
+
+// pdfPageNumbers writes the page number at the bottom of each page.
+func pdfPageNumbers(doc *pdf.Document) {
+	doc.SetFont(pdf.Regular, 8)
+	doc.SetColor(pdf.Gray(0.4))
+	for i := 1; i <= doc.PageCount(); i++ {
+		doc.SetPage(i)
+		doc.TextAligned(doc.Margin, pdf.PageHeight-doc.Margin/2, pdf.PageWidth-2*doc.Margin,
+			fmt.Sprintf("Page %d of %d", i, doc.PageCount()), pdf.AlignRight)
+	}
+	doc.SetColor(pdf.Black)
+}
+
+var (
+	htmlBreakRx = regexp.MustCompile(`(?i)<br\s*/?>`)
+	htmlTagRx   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// htmlToText converts the html markup allowed in the invoice configuration
+// to plain text.
+func htmlToText(s string) string {
+	s = htmlBreakRx.ReplaceAllString(s, "\n")
+	s = htmlTagRx.ReplaceAllString(s, "")
+	return html.UnescapeString(s)
+}
+
+// writeEntriesPDF writes the worklog as a PDF document, a table for each
+// month. The layout follows the html format.
+func writeEntriesPDF(w io.Writer, entries []*wlog.Entry, style wlog.DurationStyle, holidays *wlog.HolidayCalendar) error {
+	doc := pdf.New()
+	doc.Title = "Worklog"
+	doc.AddPage()
+	left := doc.Margin
+	width := pdf.PageWidth - 2*doc.Margin
+
+	var (
+		workday = pdf.Gray(0.95)
+		fills   = map[wlog.DayStatus]pdf.Color{
+			wlog.StatusVacation: {R: 0.89, G: 0.94, B: 0.86},
+			wlog.StatusHalfDay:  {R: 0.94, G: 0.97, B: 0.93},
+			wlog.StatusSick:     {R: 0.97, G: 0.88, B: 0.88},
+			wlog.StatusHoliday:  {R: 0.88, G: 0.91, B: 0.97},
+		}
+	)
+
+	for i, month := range entriesByMonth(entries) {
+		doc.SetFont(pdf.Bold, 16)
+		// Keep the month heading together with the table header and
+		// the first row.
+		doc.EnsureSpace(30 + 16 + 4*doc.LineHeight())
+		if i > 0 {
+			doc.SetY(doc.Y() + 30)
+		}
+		first := month[0].Day
+		doc.TextAligned(left, doc.Y()+16, width, fmt.Sprintf("%s %d", first.Month(), first.Year()), pdf.AlignCenter)
+		doc.SetY(doc.Y() + 30)
+
+		var total time.Duration
+		rows := make([]pdf.Row, 0, len(month))
+		for _, e := range month {
+			total += e.TotalDuration()
+
+			day := e.Day.Format("2 Monday")
+			if e.Status != "" {
+				day += "\n[" + string(e.Status) + "]"
+			}
+			holiday, isHoliday := holidays.Holiday(e.Day)
+			if isHoliday {
+				day += "\n" + holiday
+			}
+
+			var tasks []string
+			for _, t := range e.Tasks {
+				tasks = append(tasks, wlog.FormatDuration(t.Duration, style)+" "+t.Description)
+			}
+			if len(tasks) == 0 && e.Status == "" && !isHoliday {
+				tasks = append(tasks, "-")
+			}
+
+			var duration string
+			if d := e.TotalDuration(); d != 0 {
+				duration = wlog.FormatDuration(d, style)
+			}
+
+			row := pdf.Row{Cells: []string{day, strings.Join(tasks, "\n"), duration}}
+			switch fill, ok := fills[e.Status]; {
+			case ok:
+				row.Fill = &fill
+			case isHoliday:
+				fill := fills[wlog.StatusHoliday]
+				row.Fill = &fill
+			case e.Day.Weekday() != time.Saturday && e.Day.Weekday() != time.Sunday:
+				row.Fill = &workday
+			}
+			rows = append(rows, row)
+		}
+
+		doc.SetFont(pdf.Regular, 9)
+		doc.Table(left, []pdf.Column{
+			{Header: "Day", Width: 110},
+			{Header: "Tasks", Width: width - 110 - 60},
+			{Header: wlog.FormatDuration(total, style), Width: 60, Align: pdf.AlignRight},
+		}, rows)
+	}
+
+	pdfPageNumbers(doc)
+	if _, err := doc.WriteTo(w); err != nil {
+		return fmt.Errorf("write pdf: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/husio/worklog/qrcode"
+)
+
+// QRBillPart is the payment part of the Swiss QR-bill, with all values
+// formatted for printing.
+type QRBillPart struct {
+	// Account is the IBAN in groups of four characters.
+	Account   string
+	Creditor  []string
+	Reference string
+	Message   string
+	Debtor    []string
+	Currency  string
+	// Amount uses a space as the thousands separator, as required on the
+	// payment part.
+	Amount string
+	// Payload is the content of the Swiss QR code.
+	Payload string
+	// SVG is the QR code with the Swiss cross, for embedding in html.
+	SVG template.HTML
+
+	code *qrcode.Code
+}
+
+// qrBillAddress is a structured address, as required by the Swiss Payments
+// Code.
+type qrBillAddress struct {
+	name     string
+	street   string
+	building string
+	postcode string
+	town     string
+	country  string
+}
+
+func (a qrBillAddress) lines() []string {
+	return []string{
+		"S",
+		a.name,
+		a.street,
+		a.building,
+		a.postcode,
+		a.town,
+		a.country,
+	}
+}
+
+// printed returns the address as it is printed on the payment part.
+func (a qrBillAddress) printed() []string {
+	lines := []string{a.name}
+	if street := strings.TrimSpace(a.street + " " + a.building); street != "" {
+		lines = append(lines, street)
+	}
+	return append(lines, a.country+"-"+a.postcode+" "+a.town)
+}
+
+// newQRBillAddress converts the multi line address into the structured
+// address. The first line is the street followed by the building number and
+// the last line is the postal code followed by the town.
+func newQRBillAddress(field, name, address, country string) (qrBillAddress, error) {
+	a := parseEInvoiceAddress(address, country)
+	addr := qrBillAddress{
+		name:     strings.TrimSpace(name),
+		street:   a.street,
+		postcode: a.postcode,
+		town:     a.city,
+		country:  a.country,
+	}
+	if words := strings.Fields(a.street); len(words) > 1 && strings.ContainsAny(words[len(words)-1], "0123456789") {
+		addr.street = strings.Join(words[:len(words)-1], " ")
+		addr.building = words[len(words)-1]
+	}
+
+	switch {
+	case addr.name == "":
+		return addr, fmt.Errorf("%s: name is required", field)
+	case addr.postcode == "" || addr.town == "":
+		return addr, fmt.Errorf("%s: address must end with a line of <postal code> <town>", field)
+	case !isCountryCode(addr.country):
+		return addr, fmt.Errorf("%s: two letter country code is required", field)
+	}
+	for _, f := range []struct {
+		name  string
+		value string
+		max   int
+	}{
+		{"name", addr.name, 70},
+		{"street", addr.street, 70},
+		{"building number", addr.building, 16},
+		{"postal code", addr.postcode, 16},
+		{"town", addr.town, 35},
+	} {
+		if utf8.RuneCountInString(f.value) > f.max {
+			return addr, fmt.Errorf("%s: %s must not be longer than %d characters", field, f.name, f.max)
+		}
+	}
+	return addr, nil
+}
+
+// newQRBillPart creates the payment part of the invoice. The reference is
+// created from the invoice number, either as the QR reference (QRR) or as
+// the ISO 11649 creditor reference (SCOR). The QR reference requires a
+// QR-IBAN, the creditor reference requires a regular IBAN.
+func newQRBillPart(c *TemplateContext) (*QRBillPart, error) {
+	iban, err := normalizeIBAN(c.PaymentIBAN)
+	if err != nil {
+		return nil, fmt.Errorf("PaymentIBAN: %w", err)
+	}
+	if country := iban[:2]; country != "CH" && country != "LI" {
+		return nil, fmt.Errorf("PaymentIBAN: QR-bill requires a Swiss or Liechtenstein IBAN, got %s", country)
+	}
+
+	currency := strings.ToUpper(c.Currency)
+	if currency == "" {
+		currency = "EUR"
+	}
+	if currency != "CHF" && currency != "EUR" {
+		return nil, fmt.Errorf("Currency: QR-bill requires CHF or EUR, got %s", currency)
+	}
+	amount := roundCents(c.Total)
+	if amount < 0.01 || amount > 999999999.99 {
+		return nil, fmt.Errorf("total amount %s is out of the 0.01 to 999999999.99 range", formatMoney(amount))
+	}
+
+	creditor, err := newQRBillAddress("FromAddress", c.FromName, c.FromAddress, c.FromCountryCode)
+	if err != nil {
+		return nil, err
+	}
+	debtor, err := newQRBillAddress("ToAddress", c.ToCompany, c.ToAddress, c.ToCountryCode)
+	if err != nil {
+		return nil, err
+	}
+
+	refType := strings.ToUpper(c.QRBillReference)
+	if refType == "" {
+		refType = "SCOR"
+		if isQRIBAN(iban) {
+			refType = "QRR"
+		}
+	}
+	var reference string
+	switch refType {
+	case "QRR":
+		if !isQRIBAN(iban) {
+			return nil, errors.New("QRBillReference: QR reference requires a QR-IBAN")
+		}
+		if reference, err = qrReference(c.InvoiceNumber); err != nil {
+			return nil, err
+		}
+	case "SCOR":
+		if isQRIBAN(iban) {
+			return nil, errors.New("QRBillReference: QR-IBAN requires the QR reference")
+		}
+		if reference, err = creditorReference(c.InvoiceNumber); err != nil {
+			return nil, err
+		}
+	case "NON":
+		if isQRIBAN(iban) {
+			return nil, errors.New("QRBillReference: QR-IBAN requires the QR reference")
+		}
+	default:
+		return nil, fmt.Errorf("QRBillReference: %q is not valid, use QRR, SCOR or NON", c.QRBillReference)
+	}
+
+	message := "Invoice " + c.InvoiceNumber
+	if utf8.RuneCountInString(message) > 140 {
+		return nil, errors.New("invoice number must not be longer than 132 characters")
+	}
+
+	lines := []string{"SPC", "0200", "1", iban}
+	lines = append(lines, creditor.lines()...)
+	// Ultimate creditor is reserved for the future use.
+	lines = append(lines, "", "", "", "", "", "", "")
+	lines = append(lines, fmt.Sprintf("%.2f", amount), currency)
+	lines = append(lines, debtor.lines()...)
+	lines = append(lines, refType, reference, message, "EPD")
+	payload := strings.Join(lines, "\n")
+
+	// Swiss QR code is limited to version 25 at the medium level.
+	code, err := qrcode.Encode([]byte(payload), qrcode.M)
+	if err != nil || code.Size > 25*4+17 {
+		return nil, errors.New("payment information is too long for the Swiss QR code")
+	}
+
+	return &QRBillPart{
+		Account:   groupChars(iban, 4),
+		Creditor:  creditor.printed(),
+		Reference: formatReference(refType, reference),
+		Message:   message,
+		Debtor:    debtor.printed(),
+		Currency:  currency,
+		Amount:    formatQRBillAmount(amount),
+		Payload:   payload,
+		SVG:       template.HTML(swissQRCodeSVG(code)),
+		code:      code,
+	}, nil
+}
+
+// isQRIBAN returns true if the IBAN is a QR-IBAN, which is identified by
+// the institution ID in the 30000 to 31999 range.
+func isQRIBAN(iban string) bool {
+	if len(iban) < 9 {
+		return false
+	}
+	iid, err := strconv.Atoi(iban[4:9])
+	return err == nil && iid >= 30000 && iid <= 31999
+}
+
+// qrReference returns the 27 digit QR reference, created from digits of the
+// invoice number and the recursive modulo 10 check digit.
+func qrReference(invoiceNumber string) (string, error) {
+	var digits strings.Builder
+	for _, c := range invoiceNumber {
+		if c >= '0' && c <= '9' {
+			digits.WriteRune(c)
+		}
+	}
+	ref := strings.TrimLeft(digits.String(), "0")
+	if ref == "" {
+		return "", fmt.Errorf("invoice number %q does not contain digits required by the QR reference", invoiceNumber)
+	}
+	if len(ref) > 26 {
+		return "", fmt.Errorf("invoice number %q has too many digits for the QR reference", invoiceNumber)
+	}
+	ref = strings.Repeat("0", 26-len(ref)) + ref
+	return ref + strconv.Itoa(mod10Recursive(ref)), nil
+}
+
+// mod10Recursive returns the check digit calculated using the recursive
+// modulo 10 algorithm.
+func mod10Recursive(digits string) int {
+	table := [10]int{0, 9, 4, 6, 8, 2, 7, 1, 3, 5}
+	var carry int
+	for _, c := range digits {
+		carry = table[(carry+int(c-'0'))%10]
+	}
+	return (10 - carry) % 10
+}
+
+// creditorReference returns the ISO 11649 creditor reference, created from
+// letters and digits of the invoice number.
+func creditorReference(invoiceNumber string) (string, error) {
+	var body strings.Builder
+	for _, c := range strings.ToUpper(invoiceNumber) {
+		if c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' {
+			body.WriteRune(c)
+		}
+	}
+	ref := body.String()
+	if ref == "" {
+		return "", fmt.Errorf("invoice number %q does not contain letters or digits required by the creditor reference", invoiceNumber)
+	}
+	if len(ref) > 21 {
+		return "", fmt.Errorf("invoice number %q is too long for the creditor reference", invoiceNumber)
+	}
+	// Check digits are calculated the same way as for IBAN.
+	var digits strings.Builder
+	for _, c := range ref + "RF00" {
+		if c >= 'A' && c <= 'Z' {
+			digits.WriteString(strconv.Itoa(int(c-'A') + 10))
+		} else {
+			digits.WriteRune(c)
+		}
+	}
+	n, _ := new(big.Int).SetString(digits.String(), 10)
+	check := 98 - new(big.Int).Mod(n, big.NewInt(97)).Int64()
+	return fmt.Sprintf("RF%02d%s", check, ref), nil
+}
+
+// formatReference groups the reference for printing. QR reference is
+// grouped by five digits from the right, creditor reference by four
+// characters from the left.
+func formatReference(refType, ref string) string {
+	switch refType {
+	case "QRR":
+		return ref[:2] + " " + groupChars(ref[2:], 5)
+	case "SCOR":
+		return groupChars(ref, 4)
+	default:
+		return ref
+	}
+}
+
+func groupChars(s string, n int) string {
+	var groups []string
+	for len(s) > n {
+		groups = append(groups, s[:n])
+		s = s[n:]
+	}
+	return strings.Join(append(groups, s), " ")
+}
+
+// formatQRBillAmount formats the amount with two decimal places and a space
+// as the thousands separator.
+func formatQRBillAmount(amount float64) string {
+	s := fmt.Sprintf("%.2f", amount)
+	integer, fraction := s[:len(s)-3], s[len(s)-3:]
+	for i := len(integer) - 3; i > 0; i -= 3 {
+		integer = integer[:i] + " " + integer[i:]
+	}
+	return integer + fraction
+}
+
+// swissCross returns the position and the size of the Swiss cross in the
+// middle of the code, in modules. The cross is 7 mm wide on the 46 mm code.
+func swissCross(code *qrcode.Code) (pos, size float64) {
+	size = float64(code.Size) * 7 / 46
+	return (float64(code.Size) - size) / 2, size
+}
+
+// swissQRCodeSVG returns the SVG image of the code, without the quiet zone
+// and with the Swiss cross in the middle.
+func swissQRCodeSVG(code *qrcode.Code) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges"><rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`,
+		code.Size, code.Size, code.Size, code.Size)
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	b.WriteString(`"/>`)
+
+	pos, size := swissCross(code)
+	unit := size / 32
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	// White border, black square and the white cross.
+	fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" fill="#fff"/>`, f(pos), f(pos), f(size), f(size))
+	fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" fill="#000"/>`, f(pos+2*unit), f(pos+2*unit), f(size-4*unit), f(size-4*unit))
+	fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" fill="#fff"/>`, f(pos+13*unit), f(pos+8*unit), f(6*unit), f(16*unit))
+	fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" fill="#fff"/>`, f(pos+8*unit), f(pos+13*unit), f(16*unit), f(6*unit))
+	b.WriteString(`</svg>`)
+	return b.String()
+}
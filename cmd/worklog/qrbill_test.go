@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func validQRBillContext() TemplateContext {
+	return TemplateContext{
+		InvoiceNumber:   "2021-0001",
+		FromName:        "Jane Doe",
+		FromAddress:     "Bahnhofstrasse 1\n8001 Zürich",
+		FromCountryCode: "CH",
+		ToCompany:       "ACME AG",
+		ToAddress:       "Rue du Lac 1268\n2501 Biel",
+		ToCountryCode:   "ch",
+		PaymentIBAN:     "CH44 3199 9123 0008 8901 2",
+		Currency:        "CHF",
+		Total:           1497.905,
+	}
+}
+
+func TestQRBillPart(t *testing.T) {
+	c := validQRBillContext()
+	part, err := newQRBillPart(&c)
+	if err != nil {
+		t.Fatalf("QR-bill: %s", err)
+	}
+	want := strings.Join([]string{
+		"SPC", "0200", "1", "CH4431999123000889012",
+		"S", "Jane Doe", "Bahnhofstrasse", "1", "8001", "Zürich", "CH",
+		"", "", "", "", "", "", "",
+		"1497.91", "CHF",
+		"S", "ACME AG", "Rue du Lac", "1268", "2501", "Biel", "CH",
+		"QRR", "000000000000000000202100018", "Invoice 2021-0001", "EPD",
+	}, "\n")
+	if part.Payload != want {
+		t.Fatalf("want payload\n%s\ngot\n%s", want, part.Payload)
+	}
+	if want := "00 00000 00000 00000 02021 00018"; part.Reference != want {
+		t.Errorf("want reference %q, got %q", want, part.Reference)
+	}
+	if want := "CH44 3199 9123 0008 8901 2"; part.Account != want {
+		t.Errorf("want account %q, got %q", want, part.Account)
+	}
+	if want := "1 497.91"; part.Amount != want {
+		t.Errorf("want amount %q, got %q", want, part.Amount)
+	}
+	if want := "CH-8001 Zürich"; part.Creditor[2] != want {
+		t.Errorf("want creditor town %q, got %q", want, part.Creditor[2])
+	}
+
+	c.PaymentIBAN = "CH93 0076 2011 6238 5295 7"
+	c.Currency = "eur"
+	part, err = newQRBillPart(&c)
+	if err != nil {
+		t.Fatalf("QR-bill with creditor reference: %s", err)
+	}
+	if !strings.Contains(part.Payload, "\nEUR\n") || !strings.Contains(part.Payload, "\nSCOR\nRF8220210001\n") {
+		t.Fatalf("unexpected payload\n%s", part.Payload)
+	}
+}
+
+func TestQRBillPartErrors(t *testing.T) {
+	cases := map[string]func(c *TemplateContext){
+		"german iban":       func(c *TemplateContext) { c.PaymentIBAN = "DE89370400440532013000" },
+		"usd currency":      func(c *TemplateContext) { c.Currency = "USD" },
+		"scor with qr-iban": func(c *TemplateContext) { c.QRBillReference = "SCOR" },
+		"qrr without qr-iban": func(c *TemplateContext) {
+			c.PaymentIBAN = "CH9300762011623852957"
+			c.QRBillReference = "QRR"
+		},
+		"invalid reference":   func(c *TemplateContext) { c.QRBillReference = "ISR" },
+		"missing postal code": func(c *TemplateContext) { c.FromAddress = "Bahnhofstrasse 1" },
+		"missing country":     func(c *TemplateContext) { c.ToCountryCode = "" },
+		"no digits":           func(c *TemplateContext) { c.InvoiceNumber = "ACME" },
+		"zero total":          func(c *TemplateContext) { c.Total = 0 },
+	}
+	for name, modify := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := validQRBillContext()
+			modify(&c)
+			if _, err := newQRBillPart(&c); err == nil {
+				t.Fatal("want error")
+			}
+		})
+	}
+}
+
+func TestQRBillReferences(t *testing.T) {
+	// Examples from the Swiss implementation guidelines and ISO 11649.
+	if got := mod10Recursive("21000000000313947143000901"); got != 7 {
+		t.Errorf("want QR reference check digit 7, got %d", got)
+	}
+	ref, err := creditorReference("5390 0754 7034")
+	if err != nil {
+		t.Fatalf("creditor reference: %s", err)
+	}
+	if want := "RF18539007547034"; ref != want {
+		t.Errorf("want %q, got %q", want, ref)
+	}
+	if want := "RF18 5390 0754 7034"; formatReference("SCOR", ref) != want {
+		t.Errorf("want %q, got %q", want, formatReference("SCOR", ref))
+	}
+}
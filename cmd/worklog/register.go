@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// invoiceRecord is a single line of the invoice register. The register is
+// append-only, changes of an invoice state are recorded as new lines.
+type invoiceRecord struct {
+	// Event is one of issued, paid or void.
+	Event  string    `json:"event"`
+	Number string    `json:"number"`
+	Time   time.Time `json:"time"`
+
+	// Set for the issued event.
+	Seq    int     `json:"seq,omitempty"`
+	Scope  string  `json:"scope,omitempty"`
+	Date   string  `json:"date,omitempty"`
+	Client string  `json:"client,omitempty"`
+	From   string  `json:"from,omitempty"`
+	To     string  `json:"to,omitempty"`
+	Hours  float64 `json:"hours,omitempty"`
+	Net    float64 `json:"net,omitempty"`
+	VAT    float64 `json:"vat,omitempty"`
+	Total  float64 `json:"total,omitempty"`
+	Hash   string  `json:"hash,omitempty"`
+
+	// Set for the paid event.
+	PaidDate string `json:"paid_date,omitempty"`
+	// Set for the void event.
+	Reason string `json:"reason,omitempty"`
+}
+
+// registeredInvoice is the current state of an issued invoice.
+type registeredInvoice struct {
+	invoiceRecord
+	Voided bool
+}
+
+func (inv *registeredInvoice) Status() string {
+	switch {
+	case inv.Voided:
+		return "void"
+	case inv.PaidDate != "":
+		return "paid"
+	default:
+		return "open"
+	}
+}
+
+// invoiceRegister is the log of all issued invoices, stored in a file.
+type invoiceRegister struct {
+	path     string
+	invoices []*registeredInvoice
+}
+
+// openRegister reads the invoice register. Missing file is an empty register.
+func openRegister(path string) (*invoiceRegister, error) {
+	reg := &invoiceRegister{path: path}
+	fd, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return reg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot open register: %w", err)
+	}
+	defer fd.Close()
+
+	sc := bufio.NewScanner(fd)
+	var lineNo int
+	for sc.Scan() {
+		lineNo++
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		var rec invoiceRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid record: %w", path, lineNo, err)
+		}
+		if err := reg.apply(rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read register: %w", err)
+	}
+	return reg, nil
+}
+
+func (reg *invoiceRegister) apply(rec invoiceRecord) error {
+	if rec.Event == "issued" {
+		if reg.Find(rec.Number) != nil {
+			return fmt.Errorf("invoice %s already issued", rec.Number)
+		}
+		reg.invoices = append(reg.invoices, &registeredInvoice{invoiceRecord: rec})
+		return nil
+	}
+	inv := reg.Find(rec.Number)
+	if inv == nil {
+		return fmt.Errorf("invoice %s not found", rec.Number)
+	}
+	if inv.Voided {
+		return fmt.Errorf("invoice %s is void", rec.Number)
+	}
+	switch rec.Event {
+	case "paid":
+		if inv.PaidDate != "" {
+			return fmt.Errorf("invoice %s already paid on %s", rec.Number, inv.PaidDate)
+		}
+		inv.PaidDate = rec.PaidDate
+	case "void":
+		inv.Voided = true
+		inv.Reason = rec.Reason
+	default:
+		return fmt.Errorf("unknown event %q", rec.Event)
+	}
+	return nil
+}
+
+// Find returns the invoice with given number or nil.
+func (reg *invoiceRegister) Find(number string) *registeredInvoice {
+	for _, inv := range reg.invoices {
+		if inv.Number == number {
+			return inv
+		}
+	}
+	return nil
+}
+
+// Append validates the record and writes it to the end of the register.
+func (reg *invoiceRegister) Append(rec invoiceRecord) error {
+	if rec.Time.IsZero() {
+		rec.Time = time.Now().UTC().Truncate(time.Second)
+	}
+	if err := reg.apply(rec); err != nil {
+		return err
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("serialize: %w", err)
+	}
+	fd, err := os.OpenFile(reg.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open register: %w", err)
+	}
+	if _, err := fd.Write(append(b, '\n')); err != nil {
+		fd.Close()
+		return fmt.Errorf("write register: %w", err)
+	}
+	if err := fd.Sync(); err != nil {
+		fd.Close()
+		return fmt.Errorf("sync register: %w", err)
+	}
+	return fd.Close()
+}
+
+// NextNumber returns the next invoice number generated from the pattern for
+// an invoice issued on given day. Sequence is continued for all invoices
+// that share the same pattern and the same values of other placeholders,
+// for example a {year} pattern starts a new sequence every year.
+func (reg *invoiceRegister) NextNumber(pattern string, day time.Time) (number string, seq int, scope string, err error) {
+	if !seqPlaceholderRx.MatchString(pattern) {
+		return "", 0, "", fmt.Errorf("invoice number pattern %q does not contain {seq}", pattern)
+	}
+	scope = expandNumberPattern(pattern, day, -1)
+	for _, inv := range reg.invoices {
+		if inv.Scope == scope && inv.Seq > seq {
+			seq = inv.Seq
+		}
+	}
+	seq++
+	number = expandNumberPattern(pattern, day, seq)
+	if reg.Find(number) != nil {
+		return "", 0, "", fmt.Errorf("invoice %s already issued", number)
+	}
+	return number, seq, scope, nil
+}
+
+var seqPlaceholderRx = regexp.MustCompile(`\{seq(?::(\d+))?\}`)
+
+// expandNumberPattern replaces {year}, {month} and {seq} placeholders. Seq
+// can define the minimal width, for example {seq:04}. Negative seq is
+// rendered as the placeholder itself.
+func expandNumberPattern(pattern string, day time.Time, seq int) string {
+	s := strings.NewReplacer(
+		"{year}", day.Format("2006"),
+		"{month}", day.Format("01"),
+	).Replace(pattern)
+	if seq < 0 {
+		return s
+	}
+	return seqPlaceholderRx.ReplaceAllStringFunc(s, func(m string) string {
+		width := seqPlaceholderRx.FindStringSubmatch(m)[1]
+		if width == "" {
+			return strconv.Itoa(seq)
+		}
+		n, _ := strconv.Atoi(width)
+		return fmt.Sprintf("%0*d", n, seq)
+	})
+}
+
+// writeInvoiceDetails writes all known information about the invoice.
+func writeInvoiceDetails(w io.Writer, inv *registeredInvoice) {
+	fmt.Fprintf(w, "number\t%s\n", inv.Number)
+	fmt.Fprintf(w, "status\t%s\n", inv.Status())
+	fmt.Fprintf(w, "date\t%s\n", inv.Date)
+	fmt.Fprintf(w, "client\t%s\n", inv.Client)
+	fmt.Fprintf(w, "period\t%s..%s\n", inv.From, inv.To)
+	fmt.Fprintf(w, "hours\t%s\n", formatFloat(inv.Hours))
+	fmt.Fprintf(w, "net\t%s\n", formatMoney(inv.Net))
+	fmt.Fprintf(w, "vat\t%s\n", formatMoney(inv.VAT))
+	fmt.Fprintf(w, "total\t%s\n", formatMoney(inv.Total))
+	fmt.Fprintf(w, "hash\t%s\n", inv.Hash)
+	fmt.Fprintf(w, "issued\t%s\n", inv.Time.Format(time.RFC3339))
+	if inv.PaidDate != "" {
+		fmt.Fprintf(w, "paid\t%s\n", inv.PaidDate)
+	}
+	if inv.Voided {
+		fmt.Fprintf(w, "reason\t%s\n", inv.Reason)
+	}
+}
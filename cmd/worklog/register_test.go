@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInvoiceRegister(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invoices.jsonl")
+	reg, err := openRegister(path)
+	if err != nil {
+		t.Fatalf("open: %s", err)
+	}
+
+	issue := func(day time.Time, want string) {
+		t.Helper()
+		number, seq, scope, err := reg.NextNumber("{year}-{seq:04}", day)
+		if err != nil {
+			t.Fatalf("next number: %s", err)
+		}
+		if number != want {
+			t.Fatalf("want %s number, got %s", want, number)
+		}
+		if err := reg.Append(invoiceRecord{Event: "issued", Number: number, Seq: seq, Scope: scope, Total: 100}); err != nil {
+			t.Fatalf("append: %s", err)
+		}
+	}
+	issue(time.Date(2021, 11, 30, 0, 0, 0, 0, time.UTC), "2021-0001")
+	issue(time.Date(2021, 12, 31, 0, 0, 0, 0, time.UTC), "2021-0002")
+	issue(time.Date(2022, 1, 31, 0, 0, 0, 0, time.UTC), "2022-0001")
+
+	if err := reg.Append(invoiceRecord{Event: "void", Number: "2021-0002"}); err != nil {
+		t.Fatalf("void: %s", err)
+	}
+	if err := reg.Append(invoiceRecord{Event: "paid", Number: "2021-0002", PaidDate: "2022-01-01"}); err == nil {
+		t.Fatal("void invoice must not be paid")
+	}
+	if err := reg.Append(invoiceRecord{Event: "paid", Number: "2021-0001", PaidDate: "2022-01-01"}); err != nil {
+		t.Fatalf("mark paid: %s", err)
+	}
+
+	// State must be the same after reading the register again.
+	reg, err = openRegister(path)
+	if err != nil {
+		t.Fatalf("reopen: %s", err)
+	}
+	want := map[string]string{"2021-0001": "paid", "2021-0002": "void", "2022-0001": "open"}
+	if len(reg.invoices) != len(want) {
+		t.Fatalf("want %d invoices, got %d", len(want), len(reg.invoices))
+	}
+	for number, status := range want {
+		if got := reg.Find(number).Status(); got != status {
+			t.Errorf("%s: want %s status, got %s", number, status, got)
+		}
+	}
+	// Void invoice number is not reused.
+	issue(time.Date(2021, 12, 31, 0, 0, 0, 0, time.UTC), "2021-0003")
+}
+
+func TestInvoiceRegisterPath(t *testing.T) {
+	dir := t.TempDir()
+	register := filepath.Join(dir, "invoices.jsonl")
+	conf := filepath.Join(dir, "config.txt")
+	content := "ToCompany = ACME\nItemRate = 100\nVATPaymentPerc = 19\nRegister = " + register + "\n"
+	if err := ioutil.WriteFile(conf, []byte(content), 0644); err != nil {
+		t.Fatalf("write config: %s", err)
+	}
+
+	defer os.Setenv("WORKLOG_INVOICES", os.Getenv("WORKLOG_INVOICES"))
+	os.Setenv("WORKLOG_INVOICES", filepath.Join(dir, "env.jsonl"))
+	if path, err := configuredRegister(conf, ""); err != nil || path != register {
+		t.Fatalf("want configured register %s, got %q, %v", register, path, err)
+	}
+	if path, err := configuredRegister(filepath.Join(dir, "missing.txt"), ""); err != nil || path != filepath.Join(dir, "env.jsonl") {
+		t.Fatalf("want register from the environment, got %q, %v", path, err)
+	}
+
+	// Both commands use the register of the configuration. Amounts are
+	// rounded the same way as on the invoice.
+	input := strings.NewReader("# 1 Nov 2021 Monday\n20m a +one\n20m b +two\n20m c +three\n")
+	args := []string{"-c", conf, "-group", "project", "-o", filepath.Join(dir, "invoice.html")}
+	if err := cmdInvoice(input, ioutil.Discard, args); err != nil {
+		t.Fatalf("invoice: %s", err)
+	}
+	var out bytes.Buffer
+	if err := cmdInvoices(nil, &out, []string{"-c", conf, "list"}); err != nil {
+		t.Fatalf("invoices: %s", err)
+	}
+	if !strings.Contains(out.String(), "118.99") {
+		t.Fatalf("want the rounded total registered\n%s", out.String())
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// remoteWorklog is a worklog stored on the worklog storage server.
+type remoteWorklog struct {
+	url *url.URL
+	// token is sent as the read token. The write token grants read access
+	// too.
+	token string
+}
+
+// newRemoteWorklog returns the remote worklog at given URL. If no URL is
+// given, the WORKLOG location is used if it is a storage server URL. If no
+// token is given, WORKLOG_TOKEN is used.
+func newRemoteWorklog(rawURL, token string) (*remoteWorklog, error) {
+	if rawURL == "" {
+		rawURL = worklogPath()
+		if !isURL(rawURL) {
+			return nil, errors.New("\"url\" not provided and WORKLOG is not a storage server URL")
+		}
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if token == "" {
+		token = os.Getenv("WORKLOG_TOKEN")
+	}
+	return &remoteWorklog{url: u, token: token}, nil
+}
+
+// resourceURL returns the URL of the worklog resource, keeping the query,
+// so that the token parameter is preserved.
+func (rw *remoteWorklog) resourceURL(path ...string) string {
+	u := *rw.url
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	for _, p := range path {
+		u.Path += "/" + url.PathEscape(p)
+	}
+	u.RawPath = ""
+	return u.String()
+}
+
+func (rw *remoteWorklog) get(ctx context.Context, path ...string) ([]byte, error) {
+	content, _, err := rw.fetch(ctx, path...)
+	return content, err
+}
+
+// current returns the content of the remote worklog and its revision hash.
+// The hash is empty if the worklog was not pushed yet.
+func (rw *remoteWorklog) current(ctx context.Context) ([]byte, string, error) {
+	return rw.fetch(ctx)
+}
+
+func (rw *remoteWorklog) fetch(ctx context.Context, path ...string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rw.resourceURL(path...), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("new request: %w", err)
+	}
+	if rw.token != "" {
+		req.Header.Set("read-token", rw.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("http GET: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, "", fmt.Errorf("forbidden: invalid read token")
+	}
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1e5))
+		return nil, "", fmt.Errorf("http response: %d %s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("read response: %w", err)
+	}
+	return b, etagRevision(resp.Header.Get("etag")), nil
+}
+
+// etagRevision returns the revision hash of the ETag header value.
+func etagRevision(etag string) string {
+	return strings.Trim(strings.TrimPrefix(etag, "W/"), `"`)
+}
+
+// conflictError is returned when the remote worklog was changed since the
+// base revision.
+type conflictError struct {
+	// Remote is the current revision of the remote worklog.
+	Remote string
+}
+
+func (e *conflictError) Error() string {
+	return fmt.Sprintf("remote worklog was changed, current revision is %s", shortRevision(e.Remote))
+}
+
+// put replaces the remote worklog with given content and returns the new
+// revision. Unless forced, the remote worklog is only replaced if it is
+// still at the base revision, or does not exist if base is empty.
+// Otherwise conflictError is returned.
+func (rw *remoteWorklog) put(ctx context.Context, body io.Reader, writeToken, base string, force bool) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "PUT", rw.resourceURL(), body)
+	if err != nil {
+		return "", fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("write-token", writeToken)
+	req.Header.Set("content-type", "text/plain")
+	switch {
+	case force:
+	case base == "":
+		req.Header.Set("if-none-match", "*")
+	default:
+		req.Header.Set("if-match", revisionETag(base))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("http PUT: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return "", fmt.Errorf("forbidden: invalid push token")
+	}
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return "", &conflictError{Remote: etagRevision(resp.Header.Get("etag"))}
+	}
+	if resp.StatusCode > 204 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1e5))
+		return "", fmt.Errorf("http response: %d %s", resp.StatusCode, string(b))
+	}
+	// Worklog was stored, but the server found problems that should be
+	// fixed.
+	if resp.StatusCode == http.StatusOK {
+		io.Copy(os.Stderr, io.LimitReader(resp.Body, 1e5))
+	}
+	return etagRevision(resp.Header.Get("etag")), nil
+}
+
+// stateKey returns the remote worklog URL without the query, so that the
+// token is not stored in the sync state.
+func (rw *remoteWorklog) stateKey() string {
+	u := *rw.url
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// history returns all revisions of the remote worklog, newest first.
+func (rw *remoteWorklog) history(ctx context.Context) ([]worklogRevision, error) {
+	b, err := rw.get(ctx, "history")
+	if err != nil {
+		return nil, err
+	}
+	var revs []worklogRevision
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid history line %q", sc.Text())
+		}
+		t, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid history line %q: %w", sc.Text(), err)
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid history line %q: %w", sc.Text(), err)
+		}
+		revs = append(revs, worklogRevision{Time: t, Hash: fields[0], Size: size})
+	}
+	return revs, sc.Err()
+}
+
+// revision returns the content of the remote worklog revision. The
+// revision can be given as any unambiguous prefix of its hash.
+func (rw *remoteWorklog) revision(ctx context.Context, rev string) ([]byte, error) {
+	return rw.get(ctx, "history", rev)
+}
+
+// shortRevision returns the revision identifier as displayed to the user.
+func shortRevision(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/husio/worklog/wlog"
+)
+
+// maxWorklogSize is the largest worklog accepted by the server.
+const maxWorklogSize = 10 << 20
+
+// worklogAccess holds tokens of a single worklog served by the storage
+// server.
+type worklogAccess struct {
+	// read is empty if the worklog can be read without a token.
+	read  string
+	write string
+}
+
+// worklogNameRx matches valid worklog names, that are also used as file
+// names.
+var worklogNameRx = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+
+// parseServeTokens reads the access tokens file. Each line is the worklog
+// name, the read token and the write token. The read token "-" makes the
+// worklog public. A token can be given as its SHA-256 hash with the sha256:
+// prefix, so that the file does not have to store secrets. Lines starting
+// with # followed by a space are comments. For example:
+//
+//	# name  read-token  write-token
+//	jane    r3ad-s3cret  wr1te-s3cret
+//	team    -            sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+func parseServeTokens(r io.Reader) (map[string]worklogAccess, error) {
+	access := make(map[string]worklogAccess)
+	sc := bufio.NewScanner(r)
+	var lineNo int
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line == "#" || strings.HasPrefix(line, "# ") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("tokens:%d: expected <name> <read-token> <write-token>", lineNo)
+		}
+		name := fields[0]
+		if !worklogNameRx.MatchString(name) {
+			return nil, fmt.Errorf("tokens:%d: invalid worklog name %q, use letters, digits, - and _", lineNo, name)
+		}
+		if _, ok := access[name]; ok {
+			return nil, fmt.Errorf("tokens:%d: worklog %q is already defined", lineNo, name)
+		}
+		a := worklogAccess{read: fields[1], write: fields[2]}
+		if a.read == "-" {
+			a.read = ""
+		}
+		if a.write == "-" {
+			return nil, fmt.Errorf("tokens:%d: write token is required", lineNo)
+		}
+		access[name] = a
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	return access, nil
+}
+
+// validToken returns true if the token given by the client matches the
+// configured one, either in plain text or as the SHA-256 hash.
+func validToken(configured, given string) bool {
+	if given == "" {
+		return false
+	}
+	if strings.HasPrefix(configured, "sha256:") {
+		sum := sha256.Sum256([]byte(given))
+		given = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(configured), []byte(given)) == 1
+}
+
+// worklogServer is the file backed worklog storage. Each worklog is stored
+// in the directory as <name>.txt and served at /<name>.
+//
+// GET requires the read token, sent in the read-token header or the token
+// query parameter, so that the URL can be used as the WORKLOG location. PUT
+// replaces the worklog and requires the write token in the write-token
+// header, as sent by the push command. The write token grants read access
+// too. The ETag of the worklog is the hash of its content. PUT with the
+// If-Match header fails with 412 Precondition Failed if the worklog was
+// changed in the meantime.
+//
+// Every pushed content is kept as a revision in the <name>.history
+// directory. GET /<name>/history lists revisions, newest first, and
+// GET /<name>/history/<rev> returns the content of a revision.
+type worklogServer struct {
+	dir    string
+	access map[string]worklogAccess
+	// mu serializes writes, so that concurrent pushes do not interleave.
+	mu sync.Mutex
+}
+
+func (s *worklogServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, resource := splitResource(strings.Trim(r.URL.Path, "/"))
+	access, ok := s.access[name]
+	if !ok {
+		http.Error(w, "worklog not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		token := r.Header.Get("read-token")
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		if access.read != "" && !validToken(access.read, token) && !validToken(access.write, token) {
+			http.Error(w, "invalid read token", http.StatusUnauthorized)
+			return
+		}
+		switch rev, sub := splitResource(resource); {
+		case resource == "":
+			s.serveWorklog(w, r, name)
+		case rev == "history" && sub == "":
+			s.serveHistory(w, r, name)
+		case rev == "history":
+			s.serveRevision(w, r, name, sub)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	case http.MethodPut:
+		if resource != "" {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !validToken(access.write, r.Header.Get("write-token")) {
+			http.Error(w, "invalid write token", http.StatusUnauthorized)
+			return
+		}
+		s.storeWorklog(w, r, name)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// splitResource splits the path at the first slash.
+func splitResource(path string) (string, string) {
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		return path[:i], path[i+1:]
+	}
+	return path, ""
+}
+
+func (s *worklogServer) path(name string) string {
+	return filepath.Join(s.dir, name+".txt")
+}
+
+func (s *worklogServer) historyDir(name string) string {
+	return filepath.Join(s.dir, name+".history")
+}
+
+func (s *worklogServer) serveWorklog(w http.ResponseWriter, r *http.Request, name string) {
+	s.serveFile(w, r, s.path(name))
+}
+
+// serveFile writes the worklog content. The ETag is the revision hash of
+// the content, so that clients can use it in the If-Match header when
+// pushing.
+func (s *worklogServer) serveFile(w http.ResponseWriter, r *http.Request, path string) {
+	content, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// Worklog that was not pushed yet is empty.
+		w.Header().Set("content-type", "text/plain; charset=utf-8")
+		return
+	}
+	if err != nil {
+		http.Error(w, "cannot read worklog", http.StatusInternalServerError)
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		http.Error(w, "cannot read worklog", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("content-type", "text/plain; charset=utf-8")
+	w.Header().Set("etag", revisionETag(revisionHash(content)))
+	http.ServeContent(w, r, "", info.ModTime(), bytes.NewReader(content))
+}
+
+func (s *worklogServer) serveHistory(w http.ResponseWriter, r *http.Request, name string) {
+	s.mu.Lock()
+	revs, err := s.revisions(name)
+	s.mu.Unlock()
+	if err != nil {
+		http.Error(w, "cannot read history", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("content-type", "text/plain; charset=utf-8")
+	for i := len(revs) - 1; i >= 0; i-- {
+		fmt.Fprintf(w, "%s %s %d\n", revs[i].Hash, revs[i].Time.Format(time.RFC3339), revs[i].Size)
+	}
+}
+
+func (s *worklogServer) serveRevision(w http.ResponseWriter, r *http.Request, name, rev string) {
+	s.mu.Lock()
+	revs, err := s.revisions(name)
+	s.mu.Unlock()
+	if err != nil {
+		http.Error(w, "cannot read history", http.StatusInternalServerError)
+		return
+	}
+	found, err := findRevision(revs, rev)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	s.mu.Lock()
+	content, err := s.revisionContent(name, found.Hash)
+	s.mu.Unlock()
+	if errors.Is(err, os.ErrNotExist) {
+		http.Error(w, fmt.Sprintf("content of revision %s is missing", shortRevision(found.Hash)), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "cannot read revision", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("content-type", "text/plain; charset=utf-8")
+	w.Header().Set("etag", revisionETag(found.Hash))
+	http.ServeContent(w, r, "", found.Time, bytes.NewReader(content))
+}
+
+// revisionContent returns the worklog content of the revision with given
+// hash. Worklog stored before the history was kept has only the current
+// content, which is returned if it is the requested revision.
+func (s *worklogServer) revisionContent(name, hash string) ([]byte, error) {
+	content, err := ioutil.ReadFile(filepath.Join(s.historyDir(name), hash+".txt"))
+	if !errors.Is(err, os.ErrNotExist) {
+		return content, err
+	}
+	content, err = ioutil.ReadFile(s.path(name))
+	if err != nil {
+		return nil, err
+	}
+	if revisionHash(content) != hash {
+		return nil, os.ErrNotExist
+	}
+	return content, nil
+}
+
+func (s *worklogServer) storeWorklog(w http.ResponseWriter, r *http.Request, name string) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWorklogSize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("worklog is larger than %d bytes", maxWorklogSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	// Worklogs with structural problems are rejected, so that readers can
+	// always parse the stored content. Other problems, for example a wrong
+	// weekday, are reported back as warnings.
+	file, err := wlog.ParseFile(bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, "cannot read worklog", http.StatusBadRequest)
+		return
+	}
+	if diags := file.Diagnostics.Structural(); len(diags) != 0 {
+		http.Error(w, fmt.Sprintf("invalid worklog: %s", diags), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The If-Match and If-None-Match preconditions protect from
+	// overwriting changes pushed from another machine.
+	var current string
+	if b, err := ioutil.ReadFile(s.path(name)); err == nil {
+		current = revisionHash(b)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		http.Error(w, "cannot read worklog", http.StatusInternalServerError)
+		return
+	}
+	if current != "" {
+		w.Header().Set("etag", revisionETag(current))
+	}
+	if m := r.Header.Get("if-match"); m != "" && !etagMatches(m, current) {
+		http.Error(w, "worklog was changed", http.StatusPreconditionFailed)
+		return
+	}
+	if m := r.Header.Get("if-none-match"); m != "" && etagMatches(m, current) {
+		http.Error(w, "worklog was changed", http.StatusPreconditionFailed)
+		return
+	}
+
+	if err := s.addRevision(name, body, time.Now()); err != nil {
+		http.Error(w, "cannot store revision", http.StatusInternalServerError)
+		return
+	}
+	if err := writeFileAtomic(s.path(name), body); err != nil {
+		http.Error(w, "cannot store worklog", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("etag", revisionETag(revisionHash(body)))
+	if len(file.Diagnostics) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("content-type", "text/plain; charset=utf-8")
+	for _, d := range file.Diagnostics {
+		fmt.Fprintf(w, "warning: %s\n", d)
+	}
+}
+
+// revisionETag returns the ETag header value of the revision.
+func revisionETag(hash string) string {
+	return `"` + hash + `"`
+}
+
+// etagMatches returns true if the If-Match or If-None-Match header value
+// matches the revision hash. Empty hash means that the worklog does not
+// exist and only matches nothing.
+func etagMatches(header, hash string) bool {
+	if hash == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == revisionETag(hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// worklogRevision is a single pushed version of a worklog.
+type worklogRevision struct {
+	Time time.Time
+	// Hash is the hex encoded SHA-256 of the content. It identifies the
+	// revision.
+	Hash string
+	Size int64
+}
+
+// revisionHash returns the revision identifier of the content.
+func revisionHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// revisions returns all revisions of the worklog, oldest first. A worklog
+// stored before the history was kept has its current content returned as
+// the only revision.
+func (s *worklogServer) revisions(name string) ([]worklogRevision, error) {
+	b, err := ioutil.ReadFile(filepath.Join(s.historyDir(name), "log"))
+	if errors.Is(err, os.ErrNotExist) {
+		content, err := ioutil.ReadFile(s.path(name))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(s.path(name))
+		if err != nil {
+			return nil, err
+		}
+		return []worklogRevision{{Time: info.ModTime().UTC(), Hash: revisionHash(content), Size: int64(len(content))}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseRevisions(bytes.NewReader(b))
+}
+
+// parseRevisions reads the revisions log. Each line is the push time, the
+// content hash and the content size.
+func parseRevisions(r io.Reader) ([]worklogRevision, error) {
+	var revs []worklogRevision
+	sc := bufio.NewScanner(r)
+	var lineNo int
+	for sc.Scan() {
+		lineNo++
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("history:%d: expected <time> <hash> <size>", lineNo)
+		}
+		t, err := time.Parse(time.RFC3339, fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("history:%d: invalid time: %w", lineNo, err)
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("history:%d: invalid size: %w", lineNo, err)
+		}
+		revs = append(revs, worklogRevision{Time: t, Hash: fields[1], Size: size})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	return revs, nil
+}
+
+// addRevision stores the content as the newest revision of the worklog.
+// Pushing the content of the newest revision again does not create a new
+// one.
+func (s *worklogServer) addRevision(name string, content []byte, now time.Time) error {
+	revs, err := s.revisions(name)
+	if err != nil {
+		return err
+	}
+	hash := revisionHash(content)
+	if len(revs) > 0 && revs[len(revs)-1].Hash == hash {
+		return nil
+	}
+
+	dir := s.historyDir(name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	logPath := filepath.Join(dir, "log")
+	if _, err := os.Stat(logPath); errors.Is(err, os.ErrNotExist) && len(revs) == 1 {
+		// Keep the content stored before the history was kept.
+		content, err := ioutil.ReadFile(s.path(name))
+		if err != nil {
+			return err
+		}
+		if err := s.appendRevision(name, revs[0], content); err != nil {
+			return err
+		}
+	}
+	rev := worklogRevision{
+		Time: now.UTC().Truncate(time.Second),
+		Hash: hash,
+		Size: int64(len(content)),
+	}
+	return s.appendRevision(name, rev, content)
+}
+
+func (s *worklogServer) appendRevision(name string, rev worklogRevision, content []byte) error {
+	dir := s.historyDir(name)
+	if err := writeFileAtomic(filepath.Join(dir, rev.Hash+".txt"), content); err != nil {
+		return err
+	}
+	fd, err := os.OpenFile(filepath.Join(dir, "log"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(fd, "%s %s %d\n", rev.Time.Format(time.RFC3339), rev.Hash, rev.Size); err != nil {
+		fd.Close()
+		return err
+	}
+	return fd.Close()
+}
+
+// findRevision returns the revision with the hash starting with given
+// prefix. The prefix must be at least 4 characters long and must not be
+// ambiguous.
+func findRevision(revs []worklogRevision, prefix string) (worklogRevision, error) {
+	if len(prefix) < 4 {
+		return worklogRevision{}, fmt.Errorf("revision %q is too short, use at least 4 characters", prefix)
+	}
+	// The same content can be pushed more than once, for example when a
+	// revision is restored.
+	var found []worklogRevision
+	seen := make(map[string]bool)
+	for _, rev := range revs {
+		if strings.HasPrefix(rev.Hash, prefix) && !seen[rev.Hash] {
+			seen[rev.Hash] = true
+			found = append(found, rev)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return worklogRevision{}, fmt.Errorf("revision %q not found", prefix)
+	case 1:
+		return found[0], nil
+	default:
+		return worklogRevision{}, fmt.Errorf("revision %q is ambiguous", prefix)
+	}
+}
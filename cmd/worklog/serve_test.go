@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWorklogServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "worklog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	access, err := parseServeTokens(strings.NewReader(`
+# name  read  write
+jane    r     w
+team    -     sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+`))
+	if err != nil {
+		t.Fatalf("parse tokens: %s", err)
+	}
+	srv := httptest.NewServer(&worklogServer{dir: dir, access: access})
+	defer srv.Close()
+	state := filepath.Join(dir, "state.txt")
+
+	get := func(path, token string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", srv.URL+path, nil)
+		if token != "" {
+			req.Header.Set("read-token", token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %s", path, err)
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+
+	if code, body := get("/jane", "r"); code != http.StatusOK || body != "" {
+		t.Fatalf("want empty worklog before the first push, got %d %q", code, body)
+	}
+
+	log := "# 1 Nov 2021 Monday\n8h coding\n"
+	if err := cmdPush(strings.NewReader(log), ioutil.Discard, []string{"-url", srv.URL + "/jane", "-token", "w", "-state", state}); err != nil {
+		t.Fatalf("push: %s", err)
+	}
+	err = cmdPush(strings.NewReader(log), ioutil.Discard, []string{"-url", srv.URL + "/jane", "-token", "r", "-state", state})
+	if err == nil || !strings.Contains(err.Error(), "invalid push token") {
+		t.Fatalf("want invalid token error, got %v", err)
+	}
+
+	for _, token := range []string{"r", "w"} {
+		if code, body := get("/jane", token); code != http.StatusOK || !strings.Contains(body, "8h coding") {
+			t.Fatalf("token %s: want worklog, got %d %q", token, code, body)
+		}
+	}
+	if code, _ := get("/jane?token=r", ""); code != http.StatusOK {
+		t.Fatalf("want token query parameter accepted, got %d", code)
+	}
+	if code, _ := get("/jane", "x"); code != http.StatusUnauthorized {
+		t.Fatalf("want unauthorized, got %d", code)
+	}
+	if code, _ := get("/team", ""); code != http.StatusOK {
+		t.Fatalf("want public worklog, got %d", code)
+	}
+	if code, _ := get("/nobody", "r"); code != http.StatusNotFound {
+		t.Fatalf("want not found, got %d", code)
+	}
+
+	// Write token of the team worklog is stored as the hash of "test".
+	if err := cmdPush(strings.NewReader(log), ioutil.Discard, []string{"-url", srv.URL + "/team", "-token", "test", "-state", state}); err != nil {
+		t.Fatalf("push with hashed token: %s", err)
+	}
+
+	put := func(content string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest("PUT", srv.URL+"/jane", strings.NewReader(content))
+		req.Header.Set("write-token", "w")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("PUT: %s", err)
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+
+	conflict := "# 1 Nov 2021 Monday\n<<<<<<< local\n2h review\n=======\n3h review\n>>>>>>> remote\n"
+	if code, _ := put(conflict); code != http.StatusBadRequest {
+		t.Fatalf("want worklog with a conflict rejected, got %d", code)
+	}
+	if _, body := get("/jane", "r"); !strings.Contains(body, "8h coding") {
+		t.Fatalf("rejected push must not change the worklog, got %q", body)
+	}
+
+	// Problems that do not change how the worklog is read are warnings.
+	code, warnings := put("# 1 Nov 2021 Tuesday\n6h coding\n")
+	if code != http.StatusOK || !strings.Contains(warnings, "warning: 1:14: 1 Nov 2021 is Monday, not Tuesday") {
+		t.Fatalf("want worklog stored with a warning, got %d %q", code, warnings)
+	}
+	if _, body := get("/jane", "r"); !strings.Contains(body, "6h coding") {
+		t.Fatalf("want worklog stored, got %q", body)
+	}
+}
+
+func TestParseServeTokensErrors(t *testing.T) {
+	cases := map[string]string{
+		"missing token":   "jane r",
+		"invalid name":    "../jane r w",
+		"duplicated":      "jane r w\njane r w",
+		"no write token":  "jane r -",
+		"too many fields": "jane r w x",
+	}
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseServeTokens(strings.NewReader(content)); err == nil {
+				t.Fatal("want error")
+			}
+		})
+	}
+}
+
+func TestWorklogServerHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "worklog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Worklog stored before the history was kept.
+	first := "# 1 Nov 2021 Monday\n8h coding\n\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "jane.txt"), []byte(first), 0644); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(&worklogServer{
+		dir:    dir,
+		access: map[string]worklogAccess{"jane": {read: "r", write: "w"}},
+	})
+	defer srv.Close()
+
+	state := filepath.Join(dir, "state.txt")
+	push := func(content string, args ...string) {
+		t.Helper()
+		args = append([]string{"-url", srv.URL + "/jane", "-token", "w", "-state", state}, args...)
+		if err := cmdPush(strings.NewReader(content), ioutil.Discard, args); err != nil {
+			t.Fatalf("push: %s", err)
+		}
+	}
+	second := "# 1 Nov 2021 Monday\n6h coding\n2h review\n\n"
+	push(second, "-force")
+	push(second)
+
+	var out bytes.Buffer
+	if err := cmdHistory(nil, &out, []string{"-url", srv.URL + "/jane", "-token", "r"}); err != nil {
+		t.Fatalf("history: %s", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("want two revisions, got %q", out.String())
+	}
+	if !strings.HasPrefix(lines[0], shortRevision(revisionHash([]byte(second)))) || !strings.HasSuffix(lines[0], "(current)") {
+		t.Fatalf("want the second push as the current revision, got %q", lines[0])
+	}
+	firstRev := shortRevision(revisionHash([]byte(first)))
+	if !strings.HasPrefix(lines[1], firstRev) {
+		t.Fatalf("want the stored worklog as the first revision, got %q", lines[1])
+	}
+
+	out.Reset()
+	if err := cmdDiff(nil, &out, []string{"-url", srv.URL + "/jane?token=r", "-duration", "hm", firstRev}); err != nil {
+		t.Fatalf("diff: %s", err)
+	}
+	want := "~ # 1 Nov 2021 Monday (8h)\n  ~ 8h -> 6h coding\n  + 2h review\n"
+	if out.String() != want {
+		t.Fatalf("want diff\n%s\ngot\n%s", want, out.String())
+	}
+
+	if err := cmdRestore(nil, ioutil.Discard, []string{"-url", srv.URL + "/jane", "-token", "w", firstRev[:6]}); err != nil {
+		t.Fatalf("restore: %s", err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "jane.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != first {
+		t.Fatalf("want first revision restored, got %q", b)
+	}
+	revs, err := (&worklogServer{dir: dir}).revisions("jane")
+	if err != nil {
+		t.Fatalf("revisions: %s", err)
+	}
+	if len(revs) != 3 {
+		t.Fatalf("want restore recorded as a new revision, got %d revisions", len(revs))
+	}
+
+	if err := cmdRestore(nil, ioutil.Discard, []string{"-url", srv.URL + "/jane", "-token", "w", "0000"}); err == nil {
+		t.Fatal("want unknown revision error")
+	}
+
+	// A revision listed in the history without its content must not be
+	// served as the current worklog.
+	secondRev := revisionHash([]byte(second))
+	if err := os.Remove(filepath.Join(dir, "jane.history", secondRev+".txt")); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(srv.URL + "/jane/history/" + secondRev + "?token=r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("want 404 for missing revision content, got %d", resp.StatusCode)
+	}
+}
+
+func TestPushConflict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "worklog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv := httptest.NewServer(&worklogServer{
+		dir:    dir,
+		access: map[string]worklogAccess{"jane": {write: "w"}},
+	})
+	defer srv.Close()
+
+	push := func(machine, content string, args ...string) (string, error) {
+		t.Helper()
+		args = append([]string{"-url", srv.URL + "/jane", "-token", "w", "-state", filepath.Join(dir, machine)}, args...)
+		var out bytes.Buffer
+		err := cmdPush(strings.NewReader(content), &out, args)
+		return out.String(), err
+	}
+
+	monday := "# 1 Nov 2021 Monday\n8h coding\n\n"
+	if _, err := push("laptop", monday); err != nil {
+		t.Fatalf("laptop push: %s", err)
+	}
+	// Desktop has never pushed, but the content is the same.
+	if _, err := push("desktop", monday); err != nil {
+		t.Fatalf("desktop push of the same content: %s", err)
+	}
+
+	if _, err := push("laptop", monday+"# 2 Nov 2021 Tuesday\n7h review\n\n"); err != nil {
+		t.Fatalf("laptop push: %s", err)
+	}
+	report, err := push("desktop", monday+"# 3 Nov 2021 Wednesday\n6h coding\n\n")
+	if err == nil || !strings.Contains(err.Error(), "push rejected") {
+		t.Fatalf("want conflict, got %v", err)
+	}
+	if !strings.Contains(report, "+ # 2 Nov 2021 Tuesday (7h)\n  + 7h review\n") {
+		t.Fatalf("want remote changes reported, got\n%s", report)
+	}
+	if _, err := push("desktop", monday+"# 3 Nov 2021 Wednesday\n6h coding\n\n", "-force"); err != nil {
+		t.Fatalf("forced push: %s", err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "jane.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "Wednesday") || strings.Contains(string(b), "Tuesday") {
+		t.Fatalf("want desktop worklog stored, got %q", b)
+	}
+}
+
+func TestPullAndSync(t *testing.T) {
+	dir, err := ioutil.TempDir("", "worklog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv := httptest.NewServer(&worklogServer{
+		dir:    dir,
+		access: map[string]worklogAccess{"jane": {read: "r", write: "w"}},
+	})
+	defer srv.Close()
+
+	// Each machine has its own worklog file and sync state.
+	path := func(machine string) string { return filepath.Join(dir, machine+".txt") }
+	write := func(machine, content string) {
+		t.Helper()
+		if err := ioutil.WriteFile(path(machine), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(machine string) string {
+		t.Helper()
+		b, err := ioutil.ReadFile(path(machine))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	run := func(cmd func(io.Reader, io.Writer, []string) error, machine, token string) error {
+		t.Helper()
+		fd, err := os.Open(path(machine))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer fd.Close()
+		args := []string{"-url", srv.URL + "/jane", "-token", token, "-state", path(machine + "-state")}
+		return cmd(fd, ioutil.Discard, args)
+	}
+
+	monday := "# 1 Nov 2021 Monday\n8h coding\n\n"
+	write("laptop", monday)
+	write("desktop", "")
+	if err := run(cmdSync, "laptop", "w"); err != nil {
+		t.Fatalf("laptop sync: %s", err)
+	}
+	if err := run(cmdPull, "desktop", "r"); err != nil {
+		t.Fatalf("desktop pull: %s", err)
+	}
+	if got := read("desktop"); got != strings.TrimSuffix(monday, "\n") {
+		t.Fatalf("want remote worklog pulled, got %q", got)
+	}
+
+	// Changes of different days are merged.
+	write("laptop", monday+"# 2 Nov 2021 Tuesday\n7h laptop\n\n")
+	write("desktop", monday+"# 3 Nov 2021 Wednesday\n6h desktop\n")
+	if err := run(cmdSync, "laptop", "w"); err != nil {
+		t.Fatalf("laptop sync: %s", err)
+	}
+	if err := run(cmdSync, "desktop", "w"); err != nil {
+		t.Fatalf("desktop sync: %s", err)
+	}
+	if err := run(cmdPull, "laptop", "r"); err != nil {
+		t.Fatalf("laptop pull: %s", err)
+	}
+	want := monday + "# 2 Nov 2021 Tuesday\n7h laptop\n\n# 3 Nov 2021 Wednesday\n6h desktop\n"
+	if read("laptop") != want || read("desktop") != want {
+		t.Fatalf("want both worklogs merged, got\n%s\nand\n%s", read("laptop"), read("desktop"))
+	}
+
+	// Changes of the same task conflict.
+	write("laptop", strings.Replace(want, "8h coding", "7h coding", 1))
+	write("desktop", strings.Replace(want, "8h coding", "6h coding", 1))
+	if err := run(cmdSync, "laptop", "w"); err != nil {
+		t.Fatalf("laptop sync: %s", err)
+	}
+	if err := run(cmdSync, "desktop", "w"); err == nil || !strings.Contains(err.Error(), "1 conflict") {
+		t.Fatalf("want conflict, got %v", err)
+	}
+	if got := read("desktop"); !strings.Contains(got, "<<<<<<< local\n6h coding\n=======\n7h coding\n>>>>>>> remote\n") {
+		t.Fatalf("want conflict markers, got\n%s", got)
+	}
+	if err := run(cmdPush, "desktop", "w"); err == nil || !strings.Contains(err.Error(), "unresolved merge conflicts") {
+		t.Fatalf("want push of unresolved conflicts rejected, got %v", err)
+	}
+	fd, err := os.Open(path("desktop"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	if n, err := lintWorklog(ioutil.Discard, path("desktop"), fd); err != nil || n != 3 {
+		t.Fatalf("want three conflict markers reported by lint, got %d, %v", n, err)
+	}
+
+	// Resolved conflict can be pushed, because the remote revision was
+	// remembered by the sync.
+	write("desktop", strings.Replace(want, "8h coding", "6h coding", 1))
+	if err := run(cmdPush, "desktop", "w"); err != nil {
+		t.Fatalf("push resolved: %s", err)
+	}
+}
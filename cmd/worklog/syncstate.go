@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// syncState holds, for each remote worklog URL, the revision that the
+// local worklog was last synchronized at.
+type syncState map[string]string
+
+// defaultSyncStatePath returns the path of the sync state file of the
+// worklog with given name. It is stored next to the worklog file, as a
+// hidden file. State of a worklog that is not a local file is stored in the
+// user configuration directory.
+func defaultSyncStatePath(path string) (string, error) {
+	if isURL(path) || path == "<stdin>" || path == "<input>" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", fmt.Errorf("sync state directory: %w", err)
+		}
+		return filepath.Join(dir, "worklog", "sync.txt"), nil
+	}
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".sync"), nil
+}
+
+// readSyncState reads the sync state file. Missing file is an empty state.
+// Each line is the remote worklog URL followed by the revision.
+func readSyncState(path string) (syncState, error) {
+	state := make(syncState)
+	b, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	var lineNo int
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected <url> <revision>", path, lineNo)
+		}
+		state[fields[0]] = fields[1]
+	}
+	return state, sc.Err()
+}
+
+// writeSyncState replaces the sync state file.
+func writeSyncState(path string, state syncState) error {
+	urls := make([]string, 0, len(state))
+	for u := range state {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+
+	var b bytes.Buffer
+	b.WriteString("# Worklog sync state. Each line is the remote worklog URL and the\n")
+	b.WriteString("# revision that the worklog was last synchronized at.\n")
+	for _, u := range urls {
+		fmt.Fprintf(&b, "%s %s\n", u, state[u])
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b.Bytes())
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/husio/worklog/wlog"
+)
+
+// builtinTemplates maps the template name to its source.
+var builtinTemplates = map[string]*string{
+	"invoice": &rawTmpl,
+	"fmt":     &htmlFmtTemplate,
+}
+
+func cmdTemplates(input io.Reader, output io.Writer, args []string) error {
+	fl := flag.NewFlagSet("templates", flag.ContinueOnError)
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), "Usage: templates list|export <name>")
+		fmt.Fprint(fl.Output(), `
+List or export built-in templates. An exported template is a starting point
+for a custom template, used with the -template flag of the invoice and fmt
+commands. Templates use the Go html/template syntax, values are escaped
+according to the context they are used in.
+
+	list            List names of built-in templates.
+	export <name>   Write the template to the output.
+
+The invoice template is executed with the invoice context. Its fields are
+the configuration values, for example .FromName, .ToCompany, .PaymentIBAN,
+and the values generated from the worklog:
+
+	.InvoiceNumber .InvoiceDate   Number and date of the invoice.
+	.Items                        Invoice items, each with .Description,
+	                              .Quantity, .Unit, .Rate and .Total.
+	                              .DescriptionHTML is the description
+	                              with its formatting.
+	.ItemHours .ItemTotal         Total hours and the net amount.
+	.VATPaymentPerc .VATTotal     VAT rate and amount.
+	.Total                        Amount due.
+	.Period .PeriodStart          Invoiced period, as text and as the first
+	.PeriodEnd                    and the last day.
+	.Conversion                   Total in another currency, if configured.
+	.GiroCodeImage .QRBillPart    Payment codes, if enabled.
+	.Money <amount>               Amount in the invoice currency and locale.
+	.Quantity <number>            Quantity in the invoice locale.
+
+The fmt template is executed with the report context:
+
+	.Entries     Entries grouped by month, the most recent month first.
+	.Days        All entries, in the worklog order.
+	.Total       Total duration of all entries.
+	.Generated   Time the report was generated.
+
+Each entry has .Day, .Status, .Tasks and .TotalDuration. Each task has
+.Duration, .Description, .Project, .Client, .Tags and .Attrs.
+
+Functions available in both templates:
+
+	narrowhours <duration>             Duration in the -duration style,
+	                                   empty if zero.
+	hoursduration <entries>            Total duration of entries.
+	holiday <time>                     Holiday name, empty if not a holiday.
+	prettyNumber <number>              Number in the German format.
+	quantity <float>                   Number with up to two decimals.
+	inc <int>                          Number increased by one.
+	date <layout> <time or YYYY-MM-DD> Date in the Go layout, for example
+	                                   {{date "02.01.2006" .InvoiceDate}}.
+	money <currency> <locale> <amount> Amount in the currency and locale,
+	                                   for example {{money "CHF" "ch" .Total}}.
+	markdown <text>                    Text with paragraphs, lists, headings,
+	                                   **bold**, *italic*, `+"`code`"+` and links
+	                                   converted to html.
+`)
+	}
+	if err := fl.Parse(args); err != nil {
+		return fmt.Errorf("flag parse: %w", err)
+	}
+
+	switch {
+	case fl.NArg() == 1 && fl.Arg(0) == "list":
+		names := make([]string, 0, len(builtinTemplates))
+		for name := range builtinTemplates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		_, err := fmt.Fprintln(output, strings.Join(names, "\n"))
+		return err
+	case fl.NArg() == 2 && fl.Arg(0) == "export":
+		src, ok := builtinTemplates[fl.Arg(1)]
+		if !ok {
+			return fmt.Errorf("unknown template %q, run 'templates list' to see built-in templates", fl.Arg(1))
+		}
+		if _, err := io.WriteString(output, *src); err != nil {
+			return fmt.Errorf("write: %w", err)
+		}
+		return nil
+	default:
+		fl.Usage()
+		return errors.New("missing subcommand")
+	}
+}
+
+// loadTemplate reads and parses the template file. The template is named
+// after the file, so that parse and execution errors refer to the file and
+// the line.
+func loadTemplate(path string, funcs template.FuncMap) (*template.Template, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read template: %w", err)
+	}
+	t, err := template.New(path).Funcs(funcs).Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("cannot load %w", err)
+	}
+	return t, nil
+}
+
+// templateFuncs returns functions available in all templates. Durations are
+// presented in given style and holidays are taken from the calendar, that
+// can be nil.
+func templateFuncs(style wlog.DurationStyle, holidays *wlog.HolidayCalendar) template.FuncMap {
+	return template.FuncMap{
+		"narrowhours": func(d time.Duration) string {
+			if d == 0 {
+				return ""
+			}
+			return wlog.FormatDuration(d, style)
+		},
+		"hoursduration": func(entries []*wlog.Entry) string {
+			var total time.Duration
+			for _, e := range entries {
+				total += e.TotalDuration()
+			}
+			return wlog.FormatDuration(total, style)
+		},
+		"holiday": func(day time.Time) string {
+			name, _ := holidays.Holiday(day)
+			return name
+		},
+		"prettyNumber": prettyFormatNumberDE,
+		"quantity":     formatFloat,
+		"inc": func(i int) int {
+			return i + 1
+		},
+		"date":  formatTemplateDate,
+		"money": formatTemplateMoney,
+		"markdown": func(text string) template.HTML {
+			return template.HTML(markdownToHTML(text))
+		},
+	}
+}
+
+// formatTemplateDate formats the time or the YYYY-MM-DD date using the
+// layout.
+func formatTemplateDate(layout string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(layout), nil
+	case string:
+		if v == "" {
+			return "", nil
+		}
+		day, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a valid date, expected YYYY-MM-DD", v)
+		}
+		return day.Format(layout), nil
+	default:
+		return "", fmt.Errorf("cannot format %T as a date", value)
+	}
+}
+
+func formatTemplateMoney(currency, locale string, amount float64) (string, error) {
+	m, err := newMoney(currency, locale)
+	if err != nil {
+		return "", err
+	}
+	return m.format(amount), nil
+}
+
+var (
+	markdownBlockRx   = regexp.MustCompile(`\n\s*\n`)
+	markdownHeadingRx = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	markdownItemRx    = regexp.MustCompile(`^[-*]\s+`)
+	markdownInline    = []struct {
+		rx   *regexp.Regexp
+		repl string
+	}{
+		{regexp.MustCompile("`([^`]+)`"), "<code>$1</code>"},
+		{regexp.MustCompile(`\*\*([^*]+)\*\*`), "<strong>$1</strong>"},
+		{regexp.MustCompile(`\*([^*]+)\*`), "<em>$1</em>"},
+		{regexp.MustCompile(`\[([^\]]+)\]\(((?:https?://|mailto:)[^)\s]+)\)`), `<a href="$2">$1</a>`},
+	}
+)
+
+// markdownToHTML converts the basic markdown to html. Blocks separated by
+// an empty line are paragraphs, headings or lists. The text is escaped, so
+// that html in the text is not interpreted.
+func markdownToHTML(text string) string {
+	inline := func(s string) string {
+		s = html.EscapeString(s)
+		for _, r := range markdownInline {
+			s = r.rx.ReplaceAllString(s, r.repl)
+		}
+		return s
+	}
+
+	var b strings.Builder
+	for _, block := range markdownBlockRx.Split(strings.TrimSpace(text), -1) {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+		for i := range lines {
+			lines[i] = strings.TrimSpace(lines[i])
+		}
+		if lines[0] == "" {
+			continue
+		}
+
+		if m := markdownHeadingRx.FindStringSubmatch(lines[0]); m != nil && len(lines) == 1 {
+			fmt.Fprintf(&b, "<h%d>%s</h%d>\n", len(m[1]), inline(m[2]), len(m[1]))
+			continue
+		}
+		list := true
+		for _, l := range lines {
+			list = list && markdownItemRx.MatchString(l)
+		}
+		if list {
+			b.WriteString("<ul>\n")
+			for _, l := range lines {
+				fmt.Fprintf(&b, "<li>%s</li>\n", inline(markdownItemRx.ReplaceAllString(l, "")))
+			}
+			b.WriteString("</ul>\n")
+			continue
+		}
+		fmt.Fprintf(&b, "<p>%s</p>\n", inline(strings.Join(lines, "\n")))
+	}
+	return b.String()
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/husio/worklog/wlog"
+)
+
+func TestMarkdownToHTML(t *testing.T) {
+	cases := map[string]struct {
+		input string
+		want  string
+	}{
+		"paragraphs": {
+			input: "First line\nsecond line\n\nNext *paragraph*.",
+			want:  "<p>First line\nsecond line</p>\n<p>Next <em>paragraph</em>.</p>\n",
+		},
+		"heading and list": {
+			input: "## Notes\n\n- **bold** item\n* `code` item",
+			want:  "<h2>Notes</h2>\n<ul>\n<li><strong>bold</strong> item</li>\n<li><code>code</code> item</li>\n</ul>\n",
+		},
+		"escaped html": {
+			input: "<script>alert(1)</script> & [site](https://example.com)",
+			want:  "<p>&lt;script&gt;alert(1)&lt;/script&gt; &amp; <a href=\"https://example.com\">site</a></p>\n",
+		},
+		"unsafe link": {
+			input: "[site](javascript:alert)",
+			want:  "<p>[site](javascript:alert)</p>\n",
+		},
+		"empty": {
+			input: " \n",
+			want:  "",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := markdownToHTML(tc.input); got != tc.want {
+				t.Fatalf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatTemplateDate(t *testing.T) {
+	day := time.Date(2021, 11, 30, 0, 0, 0, 0, time.UTC)
+	for _, value := range []interface{}{day, "2021-11-30"} {
+		got, err := formatTemplateDate("02.01.2006", value)
+		if err != nil {
+			t.Fatalf("format %v: %s", value, err)
+		}
+		if got != "30.11.2021" {
+			t.Fatalf("want 30.11.2021, got %q", got)
+		}
+	}
+	if _, err := formatTemplateDate("02.01.2006", "30.11.2021"); err == nil {
+		t.Fatal("want invalid date error")
+	}
+}
+
+func TestLoadTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "worklog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "report.html")
+	tmpl := "{{range .Days}}{{.Day.Format \"2006-01-02\"}} {{narrowhours .TotalDuration}}\n{{end}}{{hoursduration .Days}}"
+	if err := ioutil.WriteFile(path, []byte(tmpl), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := wlog.Parse(strings.NewReader("# 1 Nov 2021 Monday\n1h30m review\n\n# 2 Nov 2021 Tuesday\n2h coding\n"))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	report, err := loadTemplate(path, templateFuncs(wlog.DefaultDurationStyle, nil))
+	if err != nil {
+		t.Fatalf("load: %s", err)
+	}
+	var b bytes.Buffer
+	if err := executeReport(&b, report, entries, wlog.DurationDecimal, nil); err != nil {
+		t.Fatalf("execute: %s", err)
+	}
+	if want := "2021-11-01 1.5h\n2021-11-02 2h\n3.5h"; b.String() != want {
+		t.Fatalf("want %q, got %q", want, b.String())
+	}
+
+	if err := ioutil.WriteFile(path, []byte("<h1>Report</h1>\n{{if}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = loadTemplate(path, templateFuncs(wlog.DefaultDurationStyle, nil))
+	if err == nil || !strings.Contains(err.Error(), path+":2:") {
+		t.Fatalf("want error at %s:2, got %v", path, err)
+	}
+}
+
+func TestCmdTemplatesExport(t *testing.T) {
+	var b bytes.Buffer
+	if err := cmdTemplates(nil, &b, []string{"export", "invoice"}); err != nil {
+		t.Fatalf("export: %s", err)
+	}
+	if b.String() != rawTmpl {
+		t.Fatal("exported template is not the built-in invoice template")
+	}
+	if err := cmdTemplates(nil, &b, []string{"export", "letter"}); err == nil {
+		t.Fatal("want unknown template error")
+	}
+}
@@ -0,0 +1,408 @@
+// Package pdf implements a minimal PDF writer, sufficient to render text
+// documents with tables and images. Fonts are embedded, so documents look the
+// same on any system.
+//
+// All coordinates are given in points, with the origin in the top left corner
+// of the page.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+	"time"
+)
+
+// Dimensions of the A4 page in points.
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// Color is an RGB color, each component in the 0..1 range.
+type Color struct {
+	R, G, B float64
+}
+
+// Gray returns a shade of gray, 0 is black and 1 is white.
+func Gray(v float64) Color {
+	return Color{R: v, G: v, B: v}
+}
+
+// Black is the default color.
+var Black = Color{}
+
+// Align is the horizontal alignment of the text.
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignRight
+	AlignCenter
+)
+
+// Document is a PDF document made of A4 pages. Use New to create an
+// instance.
+type Document struct {
+	// Margin is the space left empty around the content of each page.
+	Margin float64
+	// Title and Author are stored in the document information.
+	Title  string
+	Author string
+	// Created is stored as the creation date, unless zero.
+	Created time.Time
+	// Attachments are files embedded in the document.
+	Attachments []Attachment
+	// PDFA enables the PDF/A-3b conformance, required for the long term
+	// archiving of documents with attachments.
+	PDFA bool
+	// XMPExtension is included in the XMP metadata of a PDF/A document.
+	// It must be a sequence of rdf:Description elements.
+	XMPExtension string
+
+	fonts    [2]*font
+	fontUsed [2]bool
+	pages    []*bytes.Buffer
+	current  int
+	images   []*imageObject
+	style    FontStyle
+	size     float64
+	color    Color
+	y        float64
+}
+
+type imageObject struct {
+	width, height int
+	rgb           []byte
+	// alpha is nil if the image is fully opaque.
+	alpha []byte
+}
+
+// New returns an empty document, with the regular font of size 10 selected.
+// Call AddPage before drawing.
+func New() *Document {
+	regular, err := parseFont("Go-Regular", goRegular)
+	if err != nil {
+		panic("embedded regular font: " + err.Error())
+	}
+	bold, err := parseFont("Go-Bold", goBold)
+	if err != nil {
+		panic("embedded bold font: " + err.Error())
+	}
+	return &Document{
+		Margin: 50,
+		fonts:  [2]*font{regular, bold},
+		size:   10,
+	}
+}
+
+// AddPage starts a new page and moves the cursor to the top margin.
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.current = len(d.pages) - 1
+	d.y = d.Margin
+}
+
+// PageCount returns the number of pages.
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+// SetPage selects the page that is drawn on. Pages are numbered from 1.
+func (d *Document) SetPage(n int) {
+	if n < 1 || n > len(d.pages) {
+		panic(fmt.Sprintf("page %d does not exist", n))
+	}
+	d.current = n - 1
+}
+
+// SetFont selects the font used for writing text.
+func (d *Document) SetFont(style FontStyle, size float64) {
+	d.style = style
+	d.size = size
+}
+
+// FontSize returns the size of the selected font.
+func (d *Document) FontSize() float64 {
+	return d.size
+}
+
+// SetColor selects the color of the text and filled shapes.
+func (d *Document) SetColor(c Color) {
+	d.color = c
+}
+
+// TextWidth returns the width of the text written with the selected font.
+func (d *Document) TextWidth(s string) float64 {
+	return d.fonts[d.style].width(s, d.size)
+}
+
+func (d *Document) page() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	return d.pages[d.current]
+}
+
+// Text writes a single line of text. Y is the position of the text
+// baseline.
+func (d *Document) Text(x, y float64, s string) {
+	if s == "" {
+		return
+	}
+	d.fontUsed[d.style] = true
+	fmt.Fprintf(d.page(), "BT %s rg /F%d %s Tf %s %s Td %s Tj ET\n",
+		colorOps(d.color), d.style+1, num(d.size), num(x), num(PageHeight-y), d.fonts[d.style].encode(s))
+}
+
+// TextAligned writes a single line of text aligned within given width.
+func (d *Document) TextAligned(x, y, width float64, s string, align Align) {
+	switch align {
+	case AlignRight:
+		x += width - d.TextWidth(s)
+	case AlignCenter:
+		x += (width - d.TextWidth(s)) / 2
+	}
+	d.Text(x, y, s)
+}
+
+// Line draws a straight line of given width, using the selected color.
+func (d *Document) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(d.page(), "%s RG %s w %s %s m %s %s l S\n",
+		colorOps(d.color), num(width), num(x1), num(PageHeight-y1), num(x2), num(PageHeight-y2))
+}
+
+// FillRect draws a rectangle filled with given color. X and Y point at the
+// top left corner.
+func (d *Document) FillRect(x, y, w, h float64, c Color) {
+	fmt.Fprintf(d.page(), "%s rg %s %s %s %s re f\n",
+		colorOps(c), num(x), num(PageHeight-y-h), num(w), num(h))
+}
+
+// Image draws the image scaled to given size. X and Y point at the top left
+// corner.
+func (d *Document) Image(img image.Image, x, y, w, h float64) {
+	b := img.Bounds()
+	obj := &imageObject{width: b.Dx(), height: b.Dy()}
+	obj.rgb = make([]byte, 0, 3*b.Dx()*b.Dy())
+	alpha := make([]byte, 0, b.Dx()*b.Dy())
+	opaque := true
+	for py := b.Min.Y; py < b.Max.Y; py++ {
+		for px := b.Min.X; px < b.Max.X; px++ {
+			r, g, bl, a := img.At(px, py).RGBA()
+			if a != 0 && a != 0xffff {
+				// Colors are premultiplied by alpha.
+				r, g, bl = r*0xffff/a, g*0xffff/a, bl*0xffff/a
+			}
+			obj.rgb = append(obj.rgb, byte(r>>8), byte(g>>8), byte(bl>>8))
+			alpha = append(alpha, byte(a>>8))
+			if a != 0xffff {
+				opaque = false
+			}
+		}
+	}
+	if !opaque {
+		obj.alpha = alpha
+	}
+	d.images = append(d.images, obj)
+	fmt.Fprintf(d.page(), "q %s 0 0 %s %s %s cm /Im%d Do Q\n",
+		num(w), num(h), num(x), num(PageHeight-y-h), len(d.images))
+}
+
+// WriteTo writes the document in the PDF format.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	pw := &pdfWriter{}
+	pw.buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+	catalog := pw.reserve()
+	pagesObj := pw.reserve()
+
+	var resources strings.Builder
+	resources.WriteString("<< /Font <<")
+	for i, f := range d.fonts {
+		if !d.fontUsed[i] {
+			continue
+		}
+		ref := pw.writeFont(f)
+		fmt.Fprintf(&resources, " /F%d %d 0 R", i+1, ref)
+	}
+	resources.WriteString(" >> /XObject <<")
+	for i, img := range d.images {
+		ref := pw.writeImage(img)
+		fmt.Fprintf(&resources, " /Im%d %d 0 R", i+1, ref)
+	}
+	resources.WriteString(" >> >>")
+	resourcesObj := pw.object(resources.String())
+
+	kids := make([]string, 0, len(d.pages))
+	for _, content := range d.pages {
+		contentObj := pw.stream("", content.Bytes())
+		pageObj := pw.object(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources %d 0 R /Contents %d 0 R >>",
+			pagesObj, num(PageWidth), num(PageHeight), resourcesObj, contentObj))
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageObj))
+	}
+	pw.define(pagesObj, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+
+	cat := fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R", pagesObj)
+	if len(d.Attachments) > 0 {
+		names, refs := pw.writeAttachments(d.Attachments)
+		cat += fmt.Sprintf(" /Names << /EmbeddedFiles << /Names [%s] >> >> /AF [%s]", names, refs)
+	}
+	if d.PDFA {
+		metadata := pw.rawStream("/Type /Metadata /Subtype /XML", []byte(d.xmpMetadata()))
+		profile := pw.stream("/N 3", srgbProfile())
+		cat += fmt.Sprintf(" /Metadata %d 0 R /OutputIntents [<< /Type /OutputIntent /S /GTS_PDFA1 /OutputConditionIdentifier (sRGB) /Info (sRGB) /DestOutputProfile %d 0 R >>]",
+			metadata, profile)
+	}
+	pw.define(catalog, cat+" >>")
+
+	info := "<< /Producer (worklog)"
+	if d.Title != "" {
+		info += " /Title " + textString(d.Title)
+	}
+	if d.Author != "" {
+		info += " /Author " + textString(d.Author)
+	}
+	if !d.Created.IsZero() {
+		info += " /CreationDate " + textString(d.Created.UTC().Format("D:20060102150405Z"))
+	}
+	info += " >>"
+	infoObj := pw.object(info)
+
+	pw.finish(catalog, infoObj)
+	return pw.buf.WriteTo(w)
+}
+
+// pdfWriter serializes PDF objects and tracks their offsets for the cross
+// reference table.
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+// reserve allocates an object number, that must be later defined.
+func (pw *pdfWriter) reserve() int {
+	pw.offsets = append(pw.offsets, -1)
+	return len(pw.offsets)
+}
+
+func (pw *pdfWriter) define(ref int, body string) {
+	pw.offsets[ref-1] = pw.buf.Len()
+	fmt.Fprintf(&pw.buf, "%d 0 obj\n%s\nendobj\n", ref, body)
+}
+
+func (pw *pdfWriter) object(body string) int {
+	ref := pw.reserve()
+	pw.define(ref, body)
+	return ref
+}
+
+// stream writes a compressed stream object. Dict contains additional
+// dictionary entries.
+func (pw *pdfWriter) stream(dict string, data []byte) int {
+	var z bytes.Buffer
+	zw, _ := zlib.NewWriterLevel(&z, zlib.BestCompression)
+	zw.Write(data)
+	zw.Close()
+
+	return pw.rawStream(dict+" /Filter /FlateDecode", z.Bytes())
+}
+
+// rawStream writes an uncompressed stream object.
+func (pw *pdfWriter) rawStream(dict string, data []byte) int {
+	ref := pw.reserve()
+	pw.offsets[ref-1] = pw.buf.Len()
+	fmt.Fprintf(&pw.buf, "%d 0 obj\n<< %s /Length %d >>\nstream\n", ref, dict, len(data))
+	pw.buf.Write(data)
+	pw.buf.WriteString("\nendstream\nendobj\n")
+	return ref
+}
+
+func (pw *pdfWriter) writeFont(f *font) int {
+	file := pw.stream(fmt.Sprintf("/Length1 %d", len(f.data)), f.data)
+	descriptor := pw.object(fmt.Sprintf(
+		"<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle %s /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		f.name, f.scale(f.bbox[0]), f.scale(f.bbox[1]), f.scale(f.bbox[2]), f.scale(f.bbox[3]),
+		num(f.italic), f.scale(f.ascent), f.scale(f.descent), f.scale(f.capHeight), file))
+	cid := pw.object(fmt.Sprintf(
+		"<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /CIDToGIDMap /Identity /W %s >>",
+		f.name, descriptor, f.widths()))
+	toUnicode := pw.stream("", []byte(f.toUnicode()))
+	return pw.object(fmt.Sprintf(
+		"<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		f.name, cid, toUnicode))
+}
+
+func (pw *pdfWriter) writeImage(img *imageObject) int {
+	var smask string
+	if img.alpha != nil {
+		ref := pw.stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8",
+			img.width, img.height), img.alpha)
+		smask = fmt.Sprintf(" /SMask %d 0 R", ref)
+	}
+	return pw.stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8%s",
+		img.width, img.height, smask), img.rgb)
+}
+
+func (pw *pdfWriter) finish(catalog, info int) {
+	// File identifier is required by PDF/A. It is derived from the
+	// content, so that the same document is always written the same way.
+	id := fmt.Sprintf("<%x>", md5.Sum(pw.buf.Bytes()))
+	xref := pw.buf.Len()
+	fmt.Fprintf(&pw.buf, "xref\n0 %d\n0000000000 65535 f \n", len(pw.offsets)+1)
+	for _, off := range pw.offsets {
+		fmt.Fprintf(&pw.buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&pw.buf, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R /ID [%s %s] >>\nstartxref\n%d\n%%%%EOF\n",
+		len(pw.offsets)+1, catalog, info, id, id, xref)
+}
+
+// num formats the number with up to two decimal places.
+func num(f float64) string {
+	s := fmt.Sprintf("%.2f", f)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+func colorOps(c Color) string {
+	return num(c.R) + " " + num(c.G) + " " + num(c.B)
+}
+
+// textString encodes the text as a PDF string. Non ASCII text is written in
+// UTF-16 with a byte order mark.
+func textString(s string) string {
+	ascii := true
+	for _, r := range s {
+		if r > 126 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		r := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`)
+		return "(" + r.Replace(s) + ")"
+	}
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, r := range s {
+		if r > 0xFFFF {
+			r -= 0x10000
+			fmt.Fprintf(&b, "%04X%04X", 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+		} else {
+			fmt.Fprintf(&b, "%04X", r)
+		}
+	}
+	b.WriteString(">")
+	return b.String()
+}
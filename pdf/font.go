@@ -0,0 +1,266 @@
+package pdf
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FontStyle selects one of the fonts embedded in the document.
+type FontStyle int
+
+const (
+	Regular FontStyle = iota
+	Bold
+)
+
+var (
+	//go:embed fonts/Go-Regular.ttf
+	goRegular []byte
+	//go:embed fonts/Go-Bold.ttf
+	goBold []byte
+)
+
+// font is a parsed TrueType font. Only the information required to measure
+// and embed the font is kept.
+type font struct {
+	name       string
+	data       []byte
+	unitsPerEm int
+	bbox       [4]int
+	ascent     int
+	descent    int
+	capHeight  int
+	italic     float64
+	// advances are glyph widths in font units, indexed by the glyph ID.
+	advances []int
+	// glyphs maps a character to the glyph ID.
+	glyphs map[rune]uint16
+	// used glyphs are listed in the font widths and the unicode map.
+	used map[uint16]rune
+}
+
+func parseFont(name string, data []byte) (*font, error) {
+	f := &font{name: name, data: data, glyphs: make(map[rune]uint16), used: make(map[uint16]rune)}
+
+	if len(data) < 12 {
+		return nil, errors.New("font file too short")
+	}
+	tables := make(map[string][]byte)
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < numTables; i++ {
+		rec := 12 + 16*i
+		if rec+16 > len(data) {
+			return nil, errors.New("truncated table directory")
+		}
+		tag := string(data[rec : rec+4])
+		offset := int(binary.BigEndian.Uint32(data[rec+8:]))
+		length := int(binary.BigEndian.Uint32(data[rec+12:]))
+		if offset+length > len(data) {
+			return nil, fmt.Errorf("table %q out of bounds", tag)
+		}
+		tables[tag] = data[offset : offset+length]
+	}
+	for _, tag := range []string{"head", "hhea", "hmtx", "cmap", "maxp"} {
+		if _, ok := tables[tag]; !ok {
+			return nil, fmt.Errorf("missing %q table", tag)
+		}
+	}
+
+	head := tables["head"]
+	f.unitsPerEm = int(binary.BigEndian.Uint16(head[18:]))
+	for i := range f.bbox {
+		f.bbox[i] = int(int16(binary.BigEndian.Uint16(head[36+2*i:])))
+	}
+
+	hhea := tables["hhea"]
+	f.ascent = int(int16(binary.BigEndian.Uint16(hhea[4:])))
+	f.descent = int(int16(binary.BigEndian.Uint16(hhea[6:])))
+	numHMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	numGlyphs := int(binary.BigEndian.Uint16(tables["maxp"][4:]))
+
+	f.capHeight = f.ascent
+	if os2 := tables["OS/2"]; len(os2) >= 90 {
+		f.capHeight = int(int16(binary.BigEndian.Uint16(os2[88:])))
+	}
+	if post := tables["post"]; len(post) >= 8 {
+		fixed := int32(binary.BigEndian.Uint32(post[4:]))
+		f.italic = float64(fixed) / 65536
+	}
+
+	hmtx := tables["hmtx"]
+	if len(hmtx) < 4*numHMetrics {
+		return nil, errors.New("truncated hmtx table")
+	}
+	f.advances = make([]int, numGlyphs)
+	var last int
+	for i := 0; i < numGlyphs; i++ {
+		if i < numHMetrics {
+			last = int(binary.BigEndian.Uint16(hmtx[4*i:]))
+		}
+		f.advances[i] = last
+	}
+
+	if err := f.parseCmap(tables["cmap"]); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// parseCmap reads the unicode character to glyph mapping. Formats 4 and 12
+// are supported.
+func (f *font) parseCmap(cmap []byte) error {
+	u16 := func(b []byte, off int) int { return int(binary.BigEndian.Uint16(b[off:])) }
+	u32 := func(b []byte, off int) int { return int(binary.BigEndian.Uint32(b[off:])) }
+
+	var best []byte
+	bestFormat := 0
+	for i := 0; i < u16(cmap, 2); i++ {
+		rec := 4 + 8*i
+		platform, encoding := u16(cmap, rec), u16(cmap, rec+2)
+		unicode := platform == 0 || (platform == 3 && (encoding == 1 || encoding == 10))
+		if !unicode {
+			continue
+		}
+		sub := cmap[u32(cmap, rec+4):]
+		if format := u16(sub, 0); (format == 4 || format == 12) && format > bestFormat {
+			best, bestFormat = sub, format
+		}
+	}
+
+	switch bestFormat {
+	case 4:
+		segCount := u16(best, 6) / 2
+		endCodes := 14
+		startCodes := endCodes + 2*segCount + 2
+		deltas := startCodes + 2*segCount
+		rangeOffsets := deltas + 2*segCount
+		for s := 0; s < segCount; s++ {
+			start, end := u16(best, startCodes+2*s), u16(best, endCodes+2*s)
+			delta := u16(best, deltas+2*s)
+			rangeOffset := u16(best, rangeOffsets+2*s)
+			for c := start; c <= end && c != 0xFFFF; c++ {
+				var gid int
+				if rangeOffset == 0 {
+					gid = (c + delta) & 0xFFFF
+				} else {
+					off := rangeOffsets + 2*s + rangeOffset + 2*(c-start)
+					if off+2 > len(best) {
+						continue
+					}
+					if gid = u16(best, off); gid != 0 {
+						gid = (gid + delta) & 0xFFFF
+					}
+				}
+				if gid != 0 {
+					f.glyphs[rune(c)] = uint16(gid)
+				}
+			}
+		}
+	case 12:
+		groups := u32(best, 12)
+		for g := 0; g < groups; g++ {
+			rec := 16 + 12*g
+			start, end, gid := u32(best, rec), u32(best, rec+4), u32(best, rec+8)
+			for c := start; c <= end; c++ {
+				f.glyphs[rune(c)] = uint16(gid + c - start)
+			}
+		}
+	default:
+		return errors.New("no unicode character map")
+	}
+	return nil
+}
+
+// glyph returns the glyph ID of the character and records it as used.
+// Characters missing in the font are rendered as the .notdef glyph.
+func (f *font) glyph(r rune) uint16 {
+	gid := f.glyphs[r]
+	if _, ok := f.used[gid]; !ok {
+		f.used[gid] = r
+	}
+	return gid
+}
+
+// width returns the width of the text in points.
+func (f *font) width(s string, size float64) float64 {
+	var units int
+	for _, r := range s {
+		units += f.advances[f.glyphs[r]]
+	}
+	return float64(units) * size / float64(f.unitsPerEm)
+}
+
+// scale converts font units to the PDF glyph space of 1000 units per em.
+func (f *font) scale(v int) int {
+	return v * 1000 / f.unitsPerEm
+}
+
+// encode returns the text encoded as a hex string of glyph IDs, as
+// expected by the Identity-H encoding.
+func (f *font) encode(s string) string {
+	var b strings.Builder
+	b.WriteByte('<')
+	for _, r := range s {
+		fmt.Fprintf(&b, "%04X", f.glyph(r))
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+// widths returns the W array of the CID font, listing all used glyphs.
+func (f *font) widths() string {
+	gids := f.usedGlyphs()
+	var b strings.Builder
+	b.WriteByte('[')
+	for _, gid := range gids {
+		fmt.Fprintf(&b, "%d [%d] ", gid, f.scale(f.advances[gid]))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// toUnicode returns the CMap that maps glyph IDs back to characters, so that
+// the text can be copied from the document.
+func (f *font) toUnicode() string {
+	var b strings.Builder
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	b.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	b.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	b.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	gids := f.usedGlyphs()
+	for len(gids) > 0 {
+		chunk := gids
+		if len(chunk) > 100 {
+			chunk = chunk[:100]
+		}
+		gids = gids[len(chunk):]
+		fmt.Fprintf(&b, "%d beginbfchar\n", len(chunk))
+		for _, gid := range chunk {
+			r := f.used[gid]
+			var utf16 string
+			if r > 0xFFFF {
+				r -= 0x10000
+				utf16 = fmt.Sprintf("%04X%04X", 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+			} else {
+				utf16 = fmt.Sprintf("%04X", r)
+			}
+			fmt.Fprintf(&b, "<%04X> <%s>\n", gid, utf16)
+		}
+		b.WriteString("endbfchar\n")
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return b.String()
+}
+
+func (f *font) usedGlyphs() []uint16 {
+	gids := make([]uint16, 0, len(f.used))
+	for gid := range f.used {
+		gids = append(gids, gid)
+	}
+	sort.Slice(gids, func(i, j int) bool { return gids[i] < gids[j] })
+	return gids
+}
@@ -0,0 +1,178 @@
+package pdf
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Y returns the vertical position of the cursor. Flowing content is written
+// at the cursor and moves it down.
+func (d *Document) Y() float64 {
+	return d.y
+}
+
+// SetY moves the cursor to given vertical position.
+func (d *Document) SetY(y float64) {
+	d.y = y
+}
+
+// LineHeight returns the height of a single line of text written with the
+// selected font.
+func (d *Document) LineHeight() float64 {
+	return d.size * 1.35
+}
+
+// EnsureSpace starts a new page if there is less than h space left on the
+// current page. It returns true if a page was added.
+func (d *Document) EnsureSpace(h float64) bool {
+	if len(d.pages) == 0 {
+		d.AddPage()
+		return true
+	}
+	if d.y+h <= PageHeight-d.Margin || d.y <= d.Margin {
+		return false
+	}
+	d.AddPage()
+	return true
+}
+
+// Paragraph writes the text wrapped to given width at the cursor and moves
+// the cursor below it. Pages are added as needed.
+func (d *Document) Paragraph(x, width float64, text string, align Align) {
+	lh := d.LineHeight()
+	for _, line := range d.WrapText(text, width) {
+		d.EnsureSpace(lh)
+		d.TextAligned(x, d.y+d.size, width, line, align)
+		d.y += lh
+	}
+}
+
+// WrapText splits the text into lines that fit in given width when written
+// with the selected font. New line characters always break the line. Words
+// longer than the width are split.
+func (d *Document) WrapText(text string, width float64) []string {
+	var lines []string
+	for _, para := range strings.Split(text, "\n") {
+		var line string
+		for _, word := range strings.FieldsFunc(para, unicode.IsSpace) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if d.TextWidth(candidate) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			// Split words that do not fit on a line alone.
+			for d.TextWidth(word) > width {
+				cut := len(word)
+				for cut > 1 && d.TextWidth(word[:cut]) > width {
+					_, size := utf8.DecodeLastRuneInString(word[:cut])
+					cut -= size
+				}
+				lines = append(lines, word[:cut])
+				word = word[cut:]
+			}
+			line = word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Column describes a single column of a table.
+type Column struct {
+	Header string
+	Width  float64
+	Align  Align
+}
+
+// Row is a single row of a table. Cells can contain multiple lines of text.
+type Row struct {
+	Cells []string
+	Bold  bool
+	// Fill is the background color of the row, if set.
+	Fill *Color
+}
+
+// CellPadding is the space between the cell border and the text.
+const CellPadding = 4
+
+// Table writes the table at the cursor and moves the cursor below it. Rows
+// that do not fit on the current page are moved to the next page, where the
+// header is repeated.
+func (d *Document) Table(x float64, columns []Column, rows []Row) {
+	var width float64
+	for _, c := range columns {
+		width += c.Width
+	}
+	style := d.style
+
+	header := Row{Bold: true, Fill: &headerFill}
+	for _, c := range columns {
+		header.Cells = append(header.Cells, c.Header)
+	}
+	drawHeader := func() {
+		d.tableRow(x, width, columns, header)
+	}
+	drawHeader()
+
+	for _, row := range rows {
+		if d.EnsureSpace(d.rowHeight(columns, row, style)) {
+			drawHeader()
+		}
+		d.tableRow(x, width, columns, row)
+	}
+	d.style = style
+}
+
+func (d *Document) rowHeight(columns []Column, row Row, style FontStyle) float64 {
+	if row.Bold {
+		d.style = Bold
+	} else {
+		d.style = style
+	}
+	lines := 1
+	for i, c := range columns {
+		if i < len(row.Cells) {
+			if n := len(d.WrapText(row.Cells[i], c.Width-2*CellPadding)); n > lines {
+				lines = n
+			}
+		}
+	}
+	d.style = style
+	return float64(lines)*d.LineHeight() + 2*CellPadding
+}
+
+var headerFill = Gray(0.93)
+
+func (d *Document) tableRow(x, width float64, columns []Column, row Row) {
+	style, color := d.style, d.color
+	h := d.rowHeight(columns, row, style)
+	if row.Fill != nil {
+		d.FillRect(x, d.y, width, h, *row.Fill)
+	}
+	if row.Bold {
+		d.style = Bold
+	}
+	cx := x
+	for i, c := range columns {
+		if i < len(row.Cells) {
+			y := d.y + CellPadding
+			for _, line := range d.WrapText(row.Cells[i], c.Width-2*CellPadding) {
+				d.TextAligned(cx+CellPadding, y+d.size, c.Width-2*CellPadding, line, c.Align)
+				y += d.LineHeight()
+			}
+		}
+		cx += c.Width
+	}
+	d.style, d.color = style, color
+	d.y += h
+	d.color = Gray(0.75)
+	d.Line(x, d.y, x+width, d.y, 0.5)
+	d.color = color
+}
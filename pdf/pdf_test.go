@@ -0,0 +1,191 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDocument(t *testing.T) {
+	doc := New()
+	doc.Title = "Test (document)"
+	doc.AddPage()
+	doc.Text(50, 60, "Zażółć gęślą jaźń")
+	doc.SetFont(Bold, 12)
+	doc.Text(50, 80, "Bold")
+	doc.Line(50, 90, 100, 90, 1)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.NRGBA{R: 255, A: 128})
+	doc.Image(img, 50, 100, 20, 20)
+	doc.AddPage()
+
+	var b bytes.Buffer
+	if _, err := doc.WriteTo(&b); err != nil {
+		t.Fatalf("write: %s", err)
+	}
+	raw := b.Bytes()
+
+	if !bytes.HasPrefix(raw, []byte("%PDF-1.7\n")) {
+		t.Fatalf("missing header: %q", raw[:20])
+	}
+	if !bytes.HasSuffix(raw, []byte("%%EOF\n")) {
+		t.Fatalf("missing EOF marker")
+	}
+	for _, want := range []string{"/Count 2", "/SMask", "/FontFile2", "/Title (Test \\(document\\))"} {
+		if !bytes.Contains(raw, []byte(want)) {
+			t.Errorf("document does not contain %q", want)
+		}
+	}
+	if n := bytes.Count(raw, []byte("/Type /Font /Subtype /Type0")); n != 2 {
+		t.Errorf("want 2 fonts, got %d", n)
+	}
+
+	// Each object offset in the cross reference table must point at the
+	// object definition.
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(raw)
+	if m == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	lines := strings.Split(string(raw[xref:]), "\n")
+	if lines[0] != "xref" {
+		t.Fatalf("startxref does not point at xref table: %q", lines[0])
+	}
+	count, _ := strconv.Atoi(strings.Fields(lines[1])[1])
+	for i := 1; i < count; i++ {
+		offset, _ := strconv.Atoi(strings.Fields(lines[2+i])[0])
+		if want := strconv.Itoa(i) + " 0 obj"; !bytes.HasPrefix(raw[offset:], []byte(want)) {
+			t.Errorf("object %d offset %d does not point at its definition", i, offset)
+		}
+	}
+}
+
+func TestDocumentToUnicode(t *testing.T) {
+	doc := New()
+	doc.Text(50, 50, "ż")
+	var b bytes.Buffer
+	if _, err := doc.WriteTo(&b); err != nil {
+		t.Fatalf("write: %s", err)
+	}
+
+	// Decompress all streams and look for the unicode mapping of ż.
+	var found bool
+	for _, m := range regexp.MustCompile(`(?s)stream\n(.*?)\nendstream`).FindAllSubmatch(b.Bytes(), -1) {
+		rd, err := zlib.NewReader(bytes.NewReader(m[1]))
+		if err != nil {
+			continue
+		}
+		content, _ := ioutil.ReadAll(rd)
+		if bytes.Contains(content, []byte("<017C>")) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("unicode map of the used character not found")
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	doc := New()
+	space := doc.TextWidth(" ")
+	word := doc.TextWidth("word")
+
+	cases := map[string]struct {
+		text  string
+		width float64
+		want  []string
+	}{
+		"single line": {
+			text:  "word word",
+			width: 2*word + space,
+			want:  []string{"word word"},
+		},
+		"wrapped": {
+			text:  "word word word",
+			width: 2*word + space,
+			want:  []string{"word word", "word"},
+		},
+		"new line": {
+			text:  "word\n\nword",
+			width: 100,
+			want:  []string{"word", "", "word"},
+		},
+		"long word": {
+			text:  "wordword",
+			width: word,
+			want:  []string{"word", "word"},
+		},
+		"empty": {
+			text:  "",
+			width: 100,
+			want:  []string{""},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := doc.WrapText(tc.text, tc.width)
+			if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+				t.Fatalf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTablePageBreak(t *testing.T) {
+	doc := New()
+	doc.AddPage()
+	rows := make([]Row, 100)
+	for i := range rows {
+		rows[i].Cells = []string{strconv.Itoa(i), "row"}
+	}
+	doc.Table(doc.Margin, []Column{{Header: "No", Width: 50}, {Header: "Text", Width: 200}}, rows)
+	if doc.PageCount() < 2 {
+		t.Fatalf("want table to span multiple pages, got %d", doc.PageCount())
+	}
+	if doc.Y() > PageHeight-doc.Margin {
+		t.Fatalf("cursor %v below the bottom margin", doc.Y())
+	}
+}
+
+func TestDocumentPDFA(t *testing.T) {
+	doc := New()
+	doc.Title = "Invoice"
+	doc.PDFA = true
+	doc.Attachments = []Attachment{{
+		Name:         "factur-x.xml",
+		MimeType:     "text/xml",
+		Relationship: "Alternative",
+		Data:         []byte("<xml/>"),
+	}}
+	doc.Text(50, 50, "Invoice")
+
+	var b bytes.Buffer
+	if _, err := doc.WriteTo(&b); err != nil {
+		t.Fatalf("write: %s", err)
+	}
+	raw := b.Bytes()
+	for _, want := range []string{
+		"/Subtype /text#2Fxml",
+		"/AFRelationship /Alternative",
+		"/EmbeddedFiles << /Names [(factur-x.xml) ",
+		"<pdfaid:part>3</pdfaid:part>",
+		"/S /GTS_PDFA1",
+		"/ID [<",
+	} {
+		if !bytes.Contains(raw, []byte(want)) {
+			t.Errorf("document does not contain %q", want)
+		}
+	}
+
+	profile := srgbProfile()
+	if size := int(profile[0])<<24 | int(profile[1])<<16 | int(profile[2])<<8 | int(profile[3]); size != len(profile) {
+		t.Errorf("profile size %d does not match the header %d", len(profile), size)
+	}
+}
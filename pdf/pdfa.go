@@ -0,0 +1,187 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Attachment is a file embedded in the document.
+type Attachment struct {
+	Name        string
+	Description string
+	MimeType    string
+	// Relationship describes how the file relates to the document content,
+	// for example Data, Source or Alternative. Required by PDF/A-3.
+	Relationship string
+	Data         []byte
+	Modified     time.Time
+}
+
+// writeAttachments writes file specifications of all attachments. It returns
+// the content of the embedded files name tree and the list of references.
+func (pw *pdfWriter) writeAttachments(attachments []Attachment) (names, refs string) {
+	var nb, rb strings.Builder
+	for i, a := range attachments {
+		params := fmt.Sprintf("/Size %d", len(a.Data))
+		if !a.Modified.IsZero() {
+			params += " /ModDate " + textString(a.Modified.UTC().Format("D:20060102150405Z"))
+		}
+		dict := fmt.Sprintf("/Type /EmbeddedFile /Params << %s >>", params)
+		if a.MimeType != "" {
+			dict += " /Subtype " + nameString(a.MimeType)
+		}
+		file := pw.stream(dict, a.Data)
+
+		spec := fmt.Sprintf("<< /Type /Filespec /F %s /UF %s /EF << /F %d 0 R /UF %d 0 R >>",
+			textString(a.Name), textString(a.Name), file, file)
+		if a.Description != "" {
+			spec += " /Desc " + textString(a.Description)
+		}
+		if a.Relationship != "" {
+			spec += " /AFRelationship " + nameString(a.Relationship)
+		}
+		ref := pw.object(spec + " >>")
+
+		if i > 0 {
+			nb.WriteByte(' ')
+			rb.WriteByte(' ')
+		}
+		fmt.Fprintf(&nb, "%s %d 0 R", textString(a.Name), ref)
+		fmt.Fprintf(&rb, "%d 0 R", ref)
+	}
+	return nb.String(), rb.String()
+}
+
+// nameString encodes the text as a PDF name. Delimiters and characters
+// outside of the printable ASCII range are written as hex codes.
+func nameString(s string) string {
+	var b strings.Builder
+	b.WriteByte('/')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c > '~' || strings.IndexByte("#/()<>[]{}%", c) >= 0 {
+			fmt.Fprintf(&b, "#%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// xmpMetadata returns the XMP metadata packet of a PDF/A-3b document. The
+// metadata must be consistent with the document information dictionary.
+func (d *Document) xmpMetadata() string {
+	esc := func(s string) string {
+		var b bytes.Buffer
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+
+	var b strings.Builder
+	b.WriteString("<?xpacket begin=\"\xef\xbb\xbf\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">` + "\n")
+	b.WriteString(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` + "\n")
+	b.WriteString(`<rdf:Description rdf:about="" xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/">` + "\n")
+	b.WriteString("<pdfaid:part>3</pdfaid:part>\n<pdfaid:conformance>B</pdfaid:conformance>\n")
+	b.WriteString("</rdf:Description>\n")
+	b.WriteString(`<rdf:Description rdf:about="" xmlns:pdf="http://ns.adobe.com/pdf/1.3/">` + "\n")
+	b.WriteString("<pdf:Producer>worklog</pdf:Producer>\n")
+	b.WriteString("</rdf:Description>\n")
+	if d.Title != "" || d.Author != "" {
+		b.WriteString(`<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">` + "\n")
+		if d.Title != "" {
+			fmt.Fprintf(&b, "<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:title>\n", esc(d.Title))
+		}
+		if d.Author != "" {
+			fmt.Fprintf(&b, "<dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>\n", esc(d.Author))
+		}
+		b.WriteString("</rdf:Description>\n")
+	}
+	if !d.Created.IsZero() {
+		b.WriteString(`<rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/">` + "\n")
+		fmt.Fprintf(&b, "<xmp:CreateDate>%s</xmp:CreateDate>\n", d.Created.UTC().Format(time.RFC3339))
+		b.WriteString("</rdf:Description>\n")
+	}
+	if d.XMPExtension != "" {
+		b.WriteString(strings.TrimSpace(d.XMPExtension))
+		b.WriteByte('\n')
+	}
+	b.WriteString("</rdf:RDF>\n</x:xmpmeta>\n")
+	b.WriteString(`<?xpacket end="w"?>`)
+	return b.String()
+}
+
+// srgbProfile returns a minimal ICC profile of the sRGB color space, used as
+// the output intent of PDF/A documents.
+func srgbProfile() []byte {
+	s15 := func(f float64) uint32 { return uint32(int32(f * 65536)) }
+	xyz := func(x, y, z float64) []byte {
+		b := []byte("XYZ \x00\x00\x00\x00")
+		for _, v := range []float64{x, y, z} {
+			b = appendUint32(b, s15(v))
+		}
+		return b
+	}
+	desc := func(s string) []byte {
+		b := []byte("desc\x00\x00\x00\x00")
+		b = appendUint32(b, uint32(len(s)+1))
+		b = append(b, s...)
+		b = append(b, 0)
+		// Empty unicode and script code descriptions.
+		b = append(b, make([]byte, 4+4+2+1+67)...)
+		return b
+	}
+	// Gamma 2.2 approximates the sRGB transfer function.
+	curve := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x01\x02\x33\x00\x00")
+
+	tags := []struct {
+		sig  string
+		data []byte
+	}{
+		{"desc", desc("sRGB")},
+		{"cprt", append([]byte("text\x00\x00\x00\x00No copyright, use freely"), 0)},
+		{"wtpt", xyz(0.9642, 1, 0.8249)},
+		{"rXYZ", xyz(0.4361, 0.2225, 0.0139)},
+		{"gXYZ", xyz(0.3851, 0.7169, 0.0971)},
+		{"bXYZ", xyz(0.1431, 0.0606, 0.7141)},
+		{"rTRC", curve},
+		{"gTRC", curve},
+		{"bTRC", curve},
+	}
+
+	var data []byte
+	table := appendUint32(nil, uint32(len(tags)))
+	offset := 128 + 4 + 12*len(tags)
+	for _, t := range tags {
+		table = append(table, t.sig...)
+		table = appendUint32(table, uint32(offset+len(data)))
+		table = appendUint32(table, uint32(len(t.data)))
+		data = append(data, t.data...)
+		// Tag data must be aligned to 4 bytes.
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+	}
+
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header[0:], uint32(128+len(table)+len(data)))
+	binary.BigEndian.PutUint32(header[8:], 0x02100000)
+	copy(header[12:], "mntrRGB XYZ ")
+	binary.BigEndian.PutUint16(header[24:], 2000)
+	binary.BigEndian.PutUint16(header[26:], 1)
+	binary.BigEndian.PutUint16(header[28:], 1)
+	copy(header[36:], "acsp")
+	binary.BigEndian.PutUint32(header[68:], s15(0.9642))
+	binary.BigEndian.PutUint32(header[72:], s15(1))
+	binary.BigEndian.PutUint32(header[76:], s15(0.8249))
+
+	return append(append(header, table...), data...)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
@@ -0,0 +1,100 @@
+package qrcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncodeGolden(t *testing.T) {
+	want := []string{
+		"#######...#.#.#######",
+		"#.....#..###..#.....#",
+		"#.###.#.##..#.#.###.#",
+		"#.###.#.##.##.#.###.#",
+		"#.###.#.###.#.#.###.#",
+		"#.....#.#.##..#.....#",
+		"#######.#.#.#.#######",
+		"........###..........",
+		"#.#####...##..#####..",
+		"##.##......#####.#..#",
+		"#...#.#####.#.##.#.#.",
+		"...##..#...####.#####",
+		"...##.#####.#....#.#.",
+		"........###.#..##.###",
+		"#######..#.#.#.....#.",
+		"#.....#.###.....#####",
+		"#.###.#.#.##.#..#..#.",
+		"#.###.#.#########....",
+		"#.###.#.##..#.#..#...",
+		"#.....#..#######.##..",
+		"#######.#...#...#..#.",
+	}
+	c := encode([]byte("worklog"), 1, M, 2)
+	if c.Size != len(want) {
+		t.Fatalf("want size %d, got %d", len(want), c.Size)
+	}
+	for y, row := range want {
+		for x, m := range row {
+			if c.Black(x, y) != (m == '#') {
+				t.Fatalf("module %d,%d differs", x, y)
+			}
+		}
+	}
+}
+
+func TestEncodeVersion(t *testing.T) {
+	cases := map[string]struct {
+		length int
+		level  Level
+		size   int
+	}{
+		"version 1":      {length: 14, level: M, size: 21},
+		"version 2":      {length: 15, level: M, size: 25},
+		"version 7 L":    {length: 154, level: L, size: 45},
+		"version 13 M":   {length: 331, level: M, size: 69},
+		"version 40 L":   {length: 2953, level: L, size: 177},
+		"high ecc level": {length: 14, level: H, size: 25},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c, err := Encode(bytes.Repeat([]byte("x"), tc.length), tc.level)
+			if err != nil {
+				t.Fatalf("encode: %s", err)
+			}
+			if c.Size != tc.size {
+				t.Fatalf("want size %d, got %d", tc.size, c.Size)
+			}
+		})
+	}
+
+	if _, err := Encode(make([]byte, 2954), L); err != ErrTooLong {
+		t.Fatalf("want ErrTooLong, got %v", err)
+	}
+}
+
+func TestImage(t *testing.T) {
+	c, err := Encode([]byte("worklog"), M)
+	if err != nil {
+		t.Fatalf("encode: %s", err)
+	}
+	img := c.Image(2)
+	if size := img.Bounds().Dx(); size != (21+2*QuietZone)*2 {
+		t.Fatalf("unexpected image size %d", size)
+	}
+	// Top left corner of the finder pattern.
+	if r, _, _, _ := img.At(2*QuietZone, 2*QuietZone).RGBA(); r != 0 {
+		t.Fatal("want finder pattern to be dark")
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r == 0 {
+		t.Fatal("want quiet zone to be light")
+	}
+
+	var b strings.Builder
+	if err := c.WriteSVG(&b); err != nil {
+		t.Fatalf("write svg: %s", err)
+	}
+	if !strings.HasPrefix(b.String(), "<svg") || !strings.Contains(b.String(), "M4 4h1v1h-1z") {
+		t.Fatalf("unexpected svg: %s", b.String())
+	}
+}
@@ -0,0 +1,95 @@
+package wlog
+
+import (
+	"strings"
+	"unicode"
+)
+
+// annotate extracts annotations from the task description. Supported
+// annotations are +project, @client, #tag and key:value. Annotations are
+// kept in the description.
+func (t *Task) annotate() {
+	t.Project = ""
+	t.Client = ""
+	t.Tags = nil
+	t.Attrs = nil
+
+	for _, word := range strings.Fields(t.Description) {
+		word = strings.TrimRight(word, ".,;!?)]}\"'")
+		word = strings.TrimLeft(word, "([{\"'")
+		if len(word) < 2 {
+			continue
+		}
+		switch name := word[1:]; word[0] {
+		case '+':
+			if isAnnotationName(name) && !isNumber(name) && t.Project == "" {
+				t.Project = name
+			}
+		case '@':
+			if isAnnotationName(name) && !isNumber(name) && t.Client == "" {
+				t.Client = name
+			}
+		case '#':
+			if isAnnotationName(name) && !isNumber(name) && !t.HasTag(name) {
+				t.Tags = append(t.Tags, name)
+			}
+		default:
+			i := strings.IndexByte(word, ':')
+			if i <= 0 {
+				continue
+			}
+			key, value := word[:i], word[i+1:]
+			if !unicode.IsLetter(rune(key[0])) || !isAnnotationName(key) {
+				continue
+			}
+			// Ignore URLs and any other value that is not a simple word.
+			if value == "" || strings.HasPrefix(value, "/") {
+				continue
+			}
+			if t.Attrs == nil {
+				t.Attrs = make(map[string]string)
+			}
+			if _, ok := t.Attrs[key]; !ok {
+				t.Attrs[key] = value
+			}
+		}
+	}
+}
+
+// HasTag returns true if the task is annotated with given #tag. The name
+// must be provided without the # prefix.
+func (t *Task) HasTag(name string) bool {
+	for _, tag := range t.Tags {
+		if tag == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isAnnotationName returns true if given text can be used as an annotation
+// name. Name must start with a letter or a digit and can contain letters,
+// digits and -_./ characters.
+func isAnnotationName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case unicode.IsLetter(c), unicode.IsDigit(c):
+		case i > 0 && strings.ContainsRune("-_./", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isNumber(s string) bool {
+	for _, c := range s {
+		if !unicode.IsDigit(c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,25 @@
+package wlog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTaskAnnotate(t *testing.T) {
+	task := Task{Description: "deploy +shop @acme (#ops) #deploy, +other ticket:JIRA-12\n" +
+		"see https://example.com and #123, +1 at 10:30 #ops"}
+	task.annotate()
+
+	if task.Project != "shop" {
+		t.Errorf("want shop project, got %q", task.Project)
+	}
+	if task.Client != "acme" {
+		t.Errorf("want acme client, got %q", task.Client)
+	}
+	if want := []string{"ops", "deploy"}; !reflect.DeepEqual(task.Tags, want) {
+		t.Errorf("want %q tags, got %q", want, task.Tags)
+	}
+	if want := map[string]string{"ticket": "JIRA-12"}; !reflect.DeepEqual(task.Attrs, want) {
+		t.Errorf("want %v attributes, got %v", want, task.Attrs)
+	}
+}
@@ -0,0 +1,380 @@
+package wlog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// File is the syntax tree of a worklog document. It keeps every byte of the
+// source, so that writing it back produces identical content.
+type File struct {
+	Lines []*Line
+
+	// Diagnostics contains all syntax problems found while parsing.
+	Diagnostics Diagnostics
+}
+
+// LineKind describes the role of a line in the worklog document.
+type LineKind int
+
+const (
+	// BlankLine contains only white space characters.
+	BlankLine LineKind = iota
+	// HeaderLine starts a new day.
+	HeaderLine
+	// TaskLine starts a new task. Task duration is optional.
+	TaskLine
+	// ContinuationLine continues the description of the previous task.
+	ContinuationLine
+	// TextLine is a text that does not belong to any day, for example
+	// notes written before the first day header.
+	TextLine
+	// ConflictMarkerLine is one of the lines that surround an unresolved
+	// merge conflict: <<<<<<<, ======= or >>>>>>>.
+	ConflictMarkerLine
+)
+
+// Merge conflict markers. Local and remote versions of the conflicting
+// content are written between the start and the separator, and the
+// separator and the end markers.
+const (
+	ConflictStart     = "<<<<<<<"
+	ConflictSeparator = "======="
+	ConflictEnd       = ">>>>>>>"
+)
+
+// isConflictMarker returns the merge conflict marker that the line starts
+// with, or an empty string.
+func isConflictMarker(line string) string {
+	for _, marker := range []string{ConflictStart, ConflictSeparator, ConflictEnd} {
+		if line == marker || strings.HasPrefix(line, marker+" ") {
+			return marker
+		}
+	}
+	return ""
+}
+
+func (k LineKind) String() string {
+	switch k {
+	case BlankLine:
+		return "blank"
+	case HeaderLine:
+		return "header"
+	case TaskLine:
+		return "task"
+	case ContinuationLine:
+		return "continuation"
+	case TextLine:
+		return "text"
+	case ConflictMarkerLine:
+		return "conflict marker"
+	default:
+		return fmt.Sprintf("LineKind(%d)", int(k))
+	}
+}
+
+// Line is a single line of the worklog document.
+type Line struct {
+	Kind LineKind
+	// Num is the line number, starting with 1.
+	Num int
+	// Col is the column of the first non white space character, starting
+	// with 1.
+	Col int
+	// Text is the line content as written, without the line terminator.
+	Text string
+	// EOL is the line terminator as written. It is empty for the last line
+	// if the document does not end with a new line.
+	EOL string
+
+	// Day and Status are set for HeaderLine.
+	Day    time.Time
+	Status DayStatus
+
+	// Duration and DurationText are set for TaskLine. DurationText is empty
+	// if the task was written without a duration.
+	Duration     time.Duration
+	DurationText string
+}
+
+// Description returns the task description part of a TaskLine,
+// ContinuationLine or TextLine.
+func (l *Line) Description() string {
+	text := strings.TrimSpace(l.Text)
+	if l.Kind == TaskLine && l.DurationText != "" {
+		text = strings.TrimSpace(text[len(l.DurationText):])
+	}
+	return text
+}
+
+// ParseFile reads and parses the worklog document. Content problems are
+// reported via File.Diagnostics and never result in an error.
+func ParseFile(r io.Reader) (*File, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+
+	var (
+		f            File
+		inDay        bool
+		inTask       bool
+		seenPreamble bool
+		lineNo       int
+		rest         = string(raw)
+	)
+	for len(rest) > 0 {
+		lineNo++
+		l := &Line{Num: lineNo}
+		if i := strings.IndexByte(rest, '\n'); i >= 0 {
+			l.Text, rest = rest[:i], rest[i+1:]
+			l.EOL = "\n"
+			if strings.HasSuffix(l.Text, "\r") {
+				l.Text = l.Text[:len(l.Text)-1]
+				l.EOL = "\r\n"
+			}
+		} else {
+			l.Text, rest = rest, ""
+		}
+		f.Lines = append(f.Lines, l)
+
+		l.Col = strings.IndexFunc(l.Text, func(r rune) bool { return !unicode.IsSpace(r) }) + 1
+		text := strings.TrimSpace(l.Text)
+		if text == "" {
+			l.Kind = BlankLine
+			continue
+		}
+
+		if marker := isConflictMarker(text); marker != "" {
+			l.Kind = ConflictMarkerLine
+			inTask = false
+			msg := "unresolved merge conflict, keep the right version and remove the markers"
+			if marker != ConflictStart {
+				msg = fmt.Sprintf("unresolved merge conflict marker %s", marker)
+			}
+			f.Diagnostics = append(f.Diagnostics, Diagnostic{
+				Line:       lineNo,
+				Col:        l.Col,
+				Message:    msg,
+				Structural: true,
+			})
+			continue
+		}
+
+		dateText, marker, markerCol := splitDayMarker(text)
+		day, err := time.Parse(TimeFormat, dateText)
+		if err == nil {
+			l.Kind = HeaderLine
+			l.Day = day
+			inDay = true
+			inTask = false
+			if d, ok := checkWeekday(dateText, day); ok {
+				d.Line = lineNo
+				d.Col += l.Col
+				f.Diagnostics = append(f.Diagnostics, d)
+			}
+			if markerCol != 0 {
+				if status, ok := ParseDayStatus(marker); ok {
+					l.Status = status
+				} else {
+					f.Diagnostics = append(f.Diagnostics, Diagnostic{
+						Line:    lineNo,
+						Col:     l.Col + markerCol,
+						Message: fmt.Sprintf("unknown day marker %q, valid markers are vacation, sick, holiday and half-day", marker),
+					})
+				}
+			}
+			continue
+		}
+		if isHeaderLike(text) {
+			f.Diagnostics = append(f.Diagnostics, Diagnostic{
+				Line:       lineNo,
+				Col:        l.Col,
+				Message:    fmt.Sprintf("invalid day header %q: %s", text, headerError(err)),
+				Structural: true,
+			})
+			// Keep it apart from the tasks, so that formatting
+			// does not indent it as a task description.
+			l.Kind = TextLine
+			inTask = false
+			continue
+		}
+
+		if word, _ := firstWord(text); word != "" && (ClockDurations() || !strings.Contains(word, ":")) {
+			if d, err := ParseDuration(word); err == nil {
+				l.Kind = TaskLine
+				l.Duration = d
+				l.DurationText = word
+				inTask = true
+				if !inDay {
+					f.Diagnostics = append(f.Diagnostics, Diagnostic{
+						Line:    lineNo,
+						Col:     l.Col,
+						Message: "task before the first day header",
+					})
+				}
+				continue
+			} else if isDurationLike(word) {
+				f.Diagnostics = append(f.Diagnostics, Diagnostic{
+					Line:    lineNo,
+					Col:     l.Col,
+					Message: fmt.Sprintf("invalid duration %q", word),
+				})
+			}
+		}
+
+		switch {
+		case inTask:
+			l.Kind = ContinuationLine
+		case inDay:
+			l.Kind = TaskLine
+			inTask = true
+		default:
+			l.Kind = TextLine
+			if !seenPreamble {
+				seenPreamble = true
+				f.Diagnostics = append(f.Diagnostics, Diagnostic{
+					Line:    lineNo,
+					Col:     l.Col,
+					Message: "text before the first day header",
+				})
+			}
+		}
+	}
+	return &f, nil
+}
+
+// HasConflicts returns true if the document contains unresolved merge
+// conflict markers.
+func (f *File) HasConflicts() bool {
+	for _, l := range f.Lines {
+		if l.Kind == ConflictMarkerLine {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrConflict is returned when the worklog contains unresolved merge
+// conflict markers.
+var ErrConflict = errors.New("unresolved merge conflict, keep the right version and remove the markers")
+
+// CheckConflicts returns ErrConflict, annotated with the line of the first
+// conflict marker, if the document contains unresolved merge conflicts.
+func (f *File) CheckConflicts() error {
+	for _, l := range f.Lines {
+		if l.Kind == ConflictMarkerLine {
+			return fmt.Errorf("line %d: %w", l.Num, ErrConflict)
+		}
+	}
+	return nil
+}
+
+// Bytes returns the document content. It is identical to the parsed source
+// unless the syntax tree was modified.
+func (f *File) Bytes() []byte {
+	var b bytes.Buffer
+	for _, l := range f.Lines {
+		b.WriteString(l.Text)
+		b.WriteString(l.EOL)
+	}
+	return b.Bytes()
+}
+
+// Entries returns all days described by the document. Content that does not
+// belong to any day is ignored.
+func (f *File) Entries() []*Entry {
+	var (
+		entries []*Entry
+		entry   *Entry
+		task    *Task
+	)
+	for _, l := range f.Lines {
+		switch l.Kind {
+		case HeaderLine:
+			entry = &Entry{Day: l.Day, Status: l.Status, Line: l.Num, Col: l.Col}
+			entries = append(entries, entry)
+			task = nil
+		case TaskLine:
+			if entry == nil {
+				continue
+			}
+			task = &Task{Duration: l.Duration, Line: l.Num, Col: l.Col}
+			entry.Tasks = append(entry.Tasks, task)
+			task.appendDescription(l.Description())
+		case ContinuationLine:
+			if task == nil {
+				continue
+			}
+			task.appendDescription(l.Description())
+		}
+	}
+	for _, e := range entries {
+		for _, t := range e.Tasks {
+			t.annotate()
+		}
+	}
+	return entries
+}
+
+func (t *Task) appendDescription(line string) {
+	line = strings.ReplaceAll(line, "; ", ".\n")
+	if len(t.Description) != 0 {
+		t.Description += "\n"
+	}
+	t.Description += line
+}
+
+// Format writes the document in the canonical form. Days are separated by a
+// single blank line, blank lines within a day are removed, durations are
+// written in given style and task continuation lines are aligned with the
+// task description. Trailing white space is removed and each line ends with a
+// new line character. Content is never dropped.
+func (f *File) Format(w io.Writer, style DurationStyle) error {
+	var (
+		b      bytes.Buffer
+		indent string
+	)
+	for _, l := range f.Lines {
+		text := strings.TrimSpace(l.Text)
+		switch l.Kind {
+		case BlankLine:
+			continue
+		case HeaderLine:
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(text)
+			indent = ""
+		case TaskLine:
+			desc := l.Description()
+			if l.DurationText == "" {
+				b.WriteString(desc)
+				indent = ""
+			} else {
+				duration := FormatDuration(l.Duration, style)
+				b.WriteString(duration)
+				if desc != "" {
+					b.WriteString(" ")
+					b.WriteString(desc)
+				}
+				indent = strings.Repeat(" ", len(duration)+1)
+			}
+		case ContinuationLine:
+			b.WriteString(indent)
+			b.WriteString(text)
+		case TextLine, ConflictMarkerLine:
+			b.WriteString(text)
+		}
+		b.WriteString("\n")
+	}
+	if _, err := b.WriteTo(w); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package wlog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFileRoundTrip(t *testing.T) {
+	sources := map[string]string{
+		"empty":            "",
+		"no final newline": "# 1 Mar 2021 Monday\n2h work",
+		"windows newlines": "# 1 Mar 2021 Monday\r\n2h work\r\n\r\n",
+		"messy": "notes\n\n\n# 1 Mar 2021 Monday   \n\n  2h   one; two\n\t  continued  \n\n\n" +
+			"# 2 Mar 2021 Tuesday\nno duration\n",
+	}
+	for name, src := range sources {
+		t.Run(name, func(t *testing.T) {
+			f, err := ParseFile(strings.NewReader(src))
+			if err != nil {
+				t.Fatalf("parse: %s", err)
+			}
+			if got := string(f.Bytes()); got != src {
+				t.Fatalf("round trip failed\nwant %q\n got %q", src, got)
+			}
+		})
+	}
+}
+
+func TestFileFormat(t *testing.T) {
+	const src = "notes\n\n\n# 1 Mar 2021 Monday   \n\n  2h   one; two\n\t  continued  \n" +
+		"30m short\n\n\n# 2 Mar 2021 Tuesday\nno duration\n  more\n\n# 3 Mar 2021 Wednesday\n"
+	const want = `notes
+
+# 1 Mar 2021 Monday
+2h one; two
+   continued
+30m short
+
+# 2 Mar 2021 Tuesday
+no duration
+more
+
+# 3 Mar 2021 Wednesday
+`
+	f, err := ParseFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	var b bytes.Buffer
+	if err := f.Format(&b, DurationHM); err != nil {
+		t.Fatalf("format: %s", err)
+	}
+	if b.String() != want {
+		t.Fatalf("unexpected format result\n%s", b.String())
+	}
+
+	// Formatting must be stable.
+	again, err := ParseFile(&b)
+	if err != nil {
+		t.Fatalf("parse formatted: %s", err)
+	}
+	var b2 bytes.Buffer
+	if err := again.Format(&b2, DurationHM); err != nil {
+		t.Fatalf("format: %s", err)
+	}
+	if b2.String() != want {
+		t.Fatalf("format is not stable\n%s", b2.String())
+	}
+}
+
+func TestFileInvalidHeader(t *testing.T) {
+	const src = "# 1 Mar 2021 Monday\n2h review\n# 31 Feb 2021 Monday\n1h coding\n"
+	f, err := ParseFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	if kind := f.Lines[2].Kind; kind != TextLine {
+		t.Fatalf("want invalid header to be a text line, got %s", kind)
+	}
+	if kind := f.Lines[3].Kind; kind != TaskLine {
+		t.Fatalf("want a task after the invalid header, got %s", kind)
+	}
+	var b bytes.Buffer
+	if err := f.Format(&b, DurationHM); err != nil {
+		t.Fatalf("format: %s", err)
+	}
+	if !strings.Contains(b.String(), "\n# 31 Feb 2021 Monday\n") {
+		t.Fatalf("invalid header must not be indented\n%s", b.String())
+	}
+}
@@ -0,0 +1,275 @@
+package wlog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Contract describes the expected working time. It is made of periods, each
+// defining how much work is expected on every weekday.
+type Contract struct {
+	Periods []ContractPeriod
+	// DaysOff are days without any expected work, for example holidays.
+	// The value is the reason.
+	DaysOff map[time.Time]string
+	// Holidays is the calendar of public holidays without any expected
+	// work. It is nil if not defined.
+	Holidays *HolidayCalendar
+}
+
+// ContractPeriod defines the expected work time on each weekday during the
+// period. To is zero if the period does not end.
+type ContractPeriod struct {
+	From  time.Time
+	To    time.Time
+	Hours [7]time.Duration
+}
+
+// Weekly returns the work time expected during a whole week.
+func (p ContractPeriod) Weekly() time.Duration {
+	var total time.Duration
+	for _, d := range p.Hours {
+		total += d
+	}
+	return total
+}
+
+// ParseContract reads the contract definition. Each line describes either a
+// contract period or days off. A period starts with the first day, optionally
+// followed by the last day and then the expected time for weekdays. A period
+// that has no last day lasts until the next period starts. Days off are
+// defined by the "off" keyword followed by a day or a date range and an
+// optional reason. Public holidays of a region are defined by the "holidays"
+// keyword followed by the region code. Lines starting with # are comments. For
+// example:
+//
+//	# 32h over 4 days.
+//	holidays DE-BY
+//	2021-01-01 mon-thu=8h
+//	2021-07-01 2021-12-31 mon-fri=6h24m
+//	off 2021-12-24 Christmas Eve
+//	off 2021-08-02..2021-08-13 vacation
+func ParseContract(r io.Reader) (*Contract, error) {
+	c := Contract{DaysOff: make(map[time.Time]string)}
+
+	sc := bufio.NewScanner(r)
+	var lineNo int
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+
+		if fields[0] == "holidays" {
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("contract:%d: holidays requires a single region code", lineNo)
+			}
+			cal, err := NewHolidayCalendar(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("contract:%d: %w", lineNo, err)
+			}
+			c.Holidays = cal
+			continue
+		}
+
+		if fields[0] == "off" {
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("contract:%d: off requires a day or a date range", lineNo)
+			}
+			dr, err := ParseDateRange(fields[1], time.Now())
+			if err != nil || dr.From.IsZero() || dr.To.IsZero() {
+				return nil, fmt.Errorf("contract:%d: invalid day off %q", lineNo, fields[1])
+			}
+			reason := strings.Join(fields[2:], " ")
+			if reason == "" {
+				reason = "day off"
+			}
+			for day := dr.From; !day.After(dr.To); day = day.AddDate(0, 0, 1) {
+				c.DaysOff[day] = reason
+			}
+			continue
+		}
+
+		from, err := ParseDate(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("contract:%d: %w", lineNo, err)
+		}
+		period := ContractPeriod{From: from}
+		rest := fields[1:]
+		if len(rest) > 0 && !strings.Contains(rest[0], "=") {
+			to, err := ParseDate(rest[0])
+			if err != nil {
+				return nil, fmt.Errorf("contract:%d: %w", lineNo, err)
+			}
+			if to.Before(from) {
+				return nil, fmt.Errorf("contract:%d: period ends before it starts", lineNo)
+			}
+			period.To = to
+			rest = rest[1:]
+		}
+		if len(rest) == 0 {
+			return nil, fmt.Errorf("contract:%d: no working time defined", lineNo)
+		}
+		for _, def := range rest {
+			chunks := strings.SplitN(def, "=", 2)
+			if len(chunks) != 2 {
+				return nil, fmt.Errorf("contract:%d: invalid working time %q, expected <weekdays>=<duration>", lineNo, def)
+			}
+			days, err := parseWeekdays(chunks[0])
+			if err != nil {
+				return nil, fmt.Errorf("contract:%d: %w", lineNo, err)
+			}
+			d, err := ParseDuration(chunks[1])
+			if err != nil {
+				return nil, fmt.Errorf("contract:%d: invalid duration %q", lineNo, chunks[1])
+			}
+			for _, wd := range days {
+				period.Hours[wd] = d
+			}
+		}
+		c.Periods = append(c.Periods, period)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	if len(c.Periods) == 0 {
+		return nil, fmt.Errorf("contract: no contract period defined")
+	}
+
+	sort.SliceStable(c.Periods, func(i, j int) bool {
+		return c.Periods[i].From.Before(c.Periods[j].From)
+	})
+	for i := range c.Periods[:len(c.Periods)-1] {
+		next := c.Periods[i+1].From
+		if c.Periods[i].To.IsZero() {
+			c.Periods[i].To = next.AddDate(0, 0, -1)
+		} else if !c.Periods[i].To.Before(next) {
+			return nil, fmt.Errorf("contract: period starting %s overlaps with the period starting %s",
+				c.Periods[i].From.Format(dateLayout), next.Format(dateLayout))
+		}
+	}
+	return &c, nil
+}
+
+// Period returns the contract period that given day belongs to.
+func (c *Contract) Period(day time.Time) (ContractPeriod, bool) {
+	day = truncateDay(day)
+	for _, p := range c.Periods {
+		if day.Before(p.From) {
+			continue
+		}
+		if !p.To.IsZero() && day.After(p.To) {
+			continue
+		}
+		return p, true
+	}
+	return ContractPeriod{}, false
+}
+
+// Start returns the first day of the contract.
+func (c *Contract) Start() time.Time {
+	return c.Periods[0].From
+}
+
+// Expected returns the work time expected on given day.
+func (c *Contract) Expected(day time.Time) time.Duration {
+	day = truncateDay(day)
+	if _, ok := c.DaysOff[day]; ok {
+		return 0
+	}
+	if _, ok := c.Holidays.Holiday(day); ok {
+		return 0
+	}
+	p, ok := c.Period(day)
+	if !ok {
+		return 0
+	}
+	return p.Hours[day.Weekday()]
+}
+
+// BalanceRow is the comparison of expected and logged work time during a
+// period of time.
+type BalanceRow struct {
+	// Period is the name of the period, for example 2021-W14 or 2021-04.
+	Period   string
+	From     time.Time
+	To       time.Time
+	Expected time.Duration
+	Worked   time.Duration
+	// Running is the sum of all differences between worked and expected
+	// time, including this period. Positive value is an overtime.
+	Running time.Duration
+}
+
+// Diff returns the overtime (positive) or the missing time (negative) of
+// the period.
+func (r BalanceRow) Diff() time.Duration {
+	return r.Worked - r.Expected
+}
+
+// Balance compares the work time expected by the contract with the work time
+// logged in the entries, for each day of the date range. Result is grouped by
+// week or by month. Running balance starts with the first day of the range.
+// Days marked as a day off reduce the expected work time.
+func Balance(entries []*Entry, c *Contract, dr DateRange, groupBy string) ([]BalanceRow, error) {
+	if dr.From.IsZero() || dr.To.IsZero() {
+		return nil, fmt.Errorf("balance requires a closed date range")
+	}
+	var periodOf func(day time.Time) (string, time.Time, time.Time)
+	switch groupBy {
+	case "week":
+		periodOf = func(day time.Time) (string, time.Time, time.Time) {
+			year, week := day.ISOWeek()
+			start := weekStart(day)
+			return fmt.Sprintf("%d-W%02d", year, week), start, start.AddDate(0, 0, 6)
+		}
+	case "month":
+		periodOf = func(day time.Time) (string, time.Time, time.Time) {
+			r := monthRange(day.Year(), day.Month())
+			return day.Format("2006-01"), r.From, r.To
+		}
+	default:
+		return nil, fmt.Errorf("cannot group balance by %q, valid groups are week and month", groupBy)
+	}
+
+	worked := make(map[time.Time]time.Duration)
+	status := make(map[time.Time]DayStatus)
+	for _, e := range entries {
+		day := truncateDay(e.Day)
+		worked[day] += e.TotalDuration()
+		if e.Status != StatusWork {
+			status[day] = e.Status
+		}
+	}
+
+	var (
+		rows    []BalanceRow
+		running time.Duration
+	)
+	for day := truncateDay(dr.From); !day.After(dr.To); day = day.AddDate(0, 0, 1) {
+		name, from, to := periodOf(day)
+		if len(rows) == 0 || rows[len(rows)-1].Period != name {
+			if from.Before(dr.From) {
+				from = dr.From
+			}
+			if to.After(dr.To) {
+				to = dr.To
+			}
+			rows = append(rows, BalanceRow{Period: name, From: from, To: to, Running: running})
+		}
+		expected := c.Expected(day)
+		expected -= time.Duration(float64(expected) * status[day].Absence())
+		row := &rows[len(rows)-1]
+		row.Expected += expected
+		row.Worked += worked[day]
+		running += worked[day] - expected
+		row.Running = running
+	}
+	return rows, nil
+}
@@ -0,0 +1,87 @@
+package wlog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBalance(t *testing.T) {
+	contract, err := ParseContract(strings.NewReader(`
+# 32h over 4 days.
+2021-03-01 mon-thu=8h
+2021-03-08 mon-fri=6h
+off 2021-03-04 holiday
+`))
+	if err != nil {
+		t.Fatalf("parse contract: %s", err)
+	}
+	entries, err := Parse(strings.NewReader(`# 1 Mar 2021 Monday
+9h a
+
+# 2 Mar 2021 Tuesday
+8h a
+
+# 3 Mar 2021 Wednesday
+7h30m b
+
+# 6 Mar 2021 Saturday
+2h c
+
+# 8 Mar 2021 Monday
+6h x
+`))
+	if err != nil {
+		t.Fatalf("parse entries: %s", err)
+	}
+
+	dr, _ := ParseDateRange("2021-03-01..2021-03-09", time.Now())
+	rows, err := Balance(entries, contract, dr, "week")
+	if err != nil {
+		t.Fatalf("balance: %s", err)
+	}
+	want := []BalanceRow{
+		{Period: "2021-W09", Expected: 24 * time.Hour, Worked: 26*time.Hour + 30*time.Minute, Running: 150 * time.Minute},
+		{Period: "2021-W10", Expected: 12 * time.Hour, Worked: 6 * time.Hour, Running: -210 * time.Minute},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("want %d rows, got %d", len(want), len(rows))
+	}
+	for i, w := range want {
+		r := rows[i]
+		if r.Period != w.Period || r.Expected != w.Expected || r.Worked != w.Worked || r.Running != w.Running {
+			t.Errorf("row %d: want %+v, got %+v", i, w, r)
+		}
+	}
+}
+
+func TestContractHolidays(t *testing.T) {
+	contract, err := ParseContract(strings.NewReader("holidays DE-BY\n2021-01-01 mon-fri=8h"))
+	if err != nil {
+		t.Fatalf("parse contract: %s", err)
+	}
+	if got := contract.Expected(time.Date(2021, time.January, 6, 0, 0, 0, 0, time.UTC)); got != 0 {
+		t.Errorf("want no work expected on Epiphany, got %s", got)
+	}
+	if got := contract.Expected(time.Date(2021, time.January, 7, 0, 0, 0, 0, time.UTC)); got != 8*time.Hour {
+		t.Errorf("want 8h expected, got %s", got)
+	}
+}
+
+func TestParseContractErrors(t *testing.T) {
+	cases := map[string]string{
+		"2021-03-01":        "contract:1: no working time defined",
+		"2021-03-01 fun=8h": `contract:1: invalid weekday "fun"`,
+		"2021-03-01 mon=8x": `contract:1: invalid duration "8x"`,
+		"2021-03-01 2021-04-01 mon=8h\n" +
+			"2021-03-15 mon=6h": "contract: period starting 2021-03-01 overlaps with the period starting 2021-03-15",
+		"off": "contract:1: off requires a day or a date range",
+		"holidays XX\n2021-03-01 mon=8h": `contract:1: unknown holiday region "XX", valid regions are ` +
+			strings.Join(HolidayRegions(), ", "),
+	}
+	for src, want := range cases {
+		if _, err := ParseContract(strings.NewReader(src)); err == nil || err.Error() != want {
+			t.Errorf("%q: want %q, got %v", src, want, err)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package wlog
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateRange is an inclusive range of days. Zero From or To means that the
+// range is not limited on that side.
+type DateRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// Contains returns true if given day belongs to the range.
+func (r DateRange) Contains(day time.Time) bool {
+	day = truncateDay(day)
+	if !r.From.IsZero() && day.Before(r.From) {
+		return false
+	}
+	if !r.To.IsZero() && day.After(r.To) {
+		return false
+	}
+	return true
+}
+
+// Intersect returns a range that contains only days present in both ranges.
+func (r DateRange) Intersect(other DateRange) DateRange {
+	if other.From.After(r.From) {
+		r.From = other.From
+	}
+	if !other.To.IsZero() && (r.To.IsZero() || other.To.Before(r.To)) {
+		r.To = other.To
+	}
+	return r
+}
+
+// Filter returns only entries that belong to the range.
+func (r DateRange) Filter(entries []*Entry) []*Entry {
+	var res []*Entry
+	for _, e := range entries {
+		if r.Contains(e.Day) {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+func (r DateRange) String() string {
+	format := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(dateLayout)
+	}
+	return format(r.From) + ".." + format(r.To)
+}
+
+const dateLayout = "2006-01-02"
+
+// ParseDateRange returns the range of days described by the expression.
+// Relative expressions are resolved using now. Supported expressions are:
+//
+//	2021-03-05                 a single day
+//	2021-03, March, Mar        a month, month name refers to the current year
+//	2021-W14                   an ISO week
+//	2021-Q2                    a quarter
+//	2021                       a year
+//	today, yesterday
+//	this-week, last-week, this-month, last-month, this-quarter,
+//	last-quarter, this-year, last-year
+//	last 30 days, last-2-weeks, last 3 months
+//	<expr>..<expr>             from the start of the first until the end of
+//	                           the second expression, either side can be
+//	                           omitted
+func ParseDateRange(expr string, now time.Time) (DateRange, error) {
+	return ParseDateRangeYear(expr, now.Year(), now)
+}
+
+// ParseDateRangeYear is like ParseDateRange, but a month name refers to
+// given year instead of the current one.
+func ParseDateRangeYear(expr string, year int, now time.Time) (DateRange, error) {
+	expr = strings.TrimSpace(expr)
+	if i := strings.Index(expr, ".."); i >= 0 {
+		var r DateRange
+		if from := strings.TrimSpace(expr[:i]); from != "" {
+			fr, err := parseDateExpr(from, year, now)
+			if err != nil {
+				return DateRange{}, err
+			}
+			r.From = fr.From
+		}
+		if to := strings.TrimSpace(expr[i+2:]); to != "" {
+			tr, err := parseDateExpr(to, year, now)
+			if err != nil {
+				return DateRange{}, err
+			}
+			r.To = tr.To
+		}
+		return r, nil
+	}
+	return parseDateExpr(expr, year, now)
+}
+
+var (
+	dayRx     = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})$`)
+	monthRx   = regexp.MustCompile(`^(\d{4})-(\d{2})$`)
+	weekRx    = regexp.MustCompile(`^(\d{4})-[Ww](\d{1,2})$`)
+	quarterRx = regexp.MustCompile(`^(\d{4})-[Qq]([1-4])$`)
+	yearRx    = regexp.MustCompile(`^(\d{4})$`)
+	lastRx    = regexp.MustCompile(`^last[ -](\d+)[ -](day|days|week|weeks|month|months|year|years)$`)
+)
+
+func parseDateExpr(expr string, year int, now time.Time) (DateRange, error) {
+	today := truncateDay(now)
+	lower := strings.ToLower(expr)
+
+	if m := dayRx.FindStringSubmatch(expr); m != nil {
+		day, err := time.Parse(dateLayout, expr)
+		if err != nil {
+			return DateRange{}, fmt.Errorf("invalid date %q: %w", expr, err)
+		}
+		return DateRange{From: day, To: day}, nil
+	}
+	if m := monthRx.FindStringSubmatch(expr); m != nil {
+		month := atoi(m[2])
+		if month < 1 || month > 12 {
+			return DateRange{}, fmt.Errorf("invalid month %q", expr)
+		}
+		return monthRange(atoi(m[1]), time.Month(month)), nil
+	}
+	if m := weekRx.FindStringSubmatch(expr); m != nil {
+		y, w := atoi(m[1]), atoi(m[2])
+		start := isoWeekStart(y, w)
+		if _, got := start.ISOWeek(); got != w {
+			return DateRange{}, fmt.Errorf("invalid week %q, year %d has no week %d", expr, y, w)
+		}
+		return DateRange{From: start, To: start.AddDate(0, 0, 6)}, nil
+	}
+	if m := quarterRx.FindStringSubmatch(expr); m != nil {
+		return quarterRange(atoi(m[1]), atoi(m[2])), nil
+	}
+	if m := yearRx.FindStringSubmatch(expr); m != nil {
+		y := atoi(m[1])
+		return DateRange{
+			From: time.Date(y, time.January, 1, 0, 0, 0, 0, time.UTC),
+			To:   time.Date(y, time.December, 31, 0, 0, 0, 0, time.UTC),
+		}, nil
+	}
+	if month, ok := parseMonth(lower); ok {
+		return monthRange(year, month), nil
+	}
+	if m := lastRx.FindStringSubmatch(lower); m != nil {
+		n := atoi(m[1])
+		if n < 1 {
+			return DateRange{}, fmt.Errorf("invalid range %q", expr)
+		}
+		var from time.Time
+		switch strings.TrimSuffix(m[2], "s") {
+		case "day":
+			from = today.AddDate(0, 0, -n+1)
+		case "week":
+			from = today.AddDate(0, 0, -7*n+1)
+		case "month":
+			from = today.AddDate(0, -n, 1)
+		case "year":
+			from = today.AddDate(-n, 0, 1)
+		}
+		return DateRange{From: from, To: today}, nil
+	}
+
+	switch strings.ReplaceAll(lower, " ", "-") {
+	case "today":
+		return DateRange{From: today, To: today}, nil
+	case "yesterday":
+		day := today.AddDate(0, 0, -1)
+		return DateRange{From: day, To: day}, nil
+	case "this-week":
+		start := weekStart(today)
+		return DateRange{From: start, To: start.AddDate(0, 0, 6)}, nil
+	case "last-week":
+		start := weekStart(today).AddDate(0, 0, -7)
+		return DateRange{From: start, To: start.AddDate(0, 0, 6)}, nil
+	case "this-month":
+		return monthRange(today.Year(), today.Month()), nil
+	case "last-month":
+		prev := time.Date(today.Year(), today.Month()-1, 1, 0, 0, 0, 0, time.UTC)
+		return monthRange(prev.Year(), prev.Month()), nil
+	case "this-quarter":
+		return quarterRange(today.Year(), quarterOf(today.Month())), nil
+	case "last-quarter":
+		prev := time.Date(today.Year(), today.Month()-3, 1, 0, 0, 0, 0, time.UTC)
+		return quarterRange(prev.Year(), quarterOf(prev.Month())), nil
+	case "this-year":
+		return parseDateExpr(strconv.Itoa(today.Year()), year, now)
+	case "last-year":
+		return parseDateExpr(strconv.Itoa(today.Year()-1), year, now)
+	}
+	return DateRange{}, fmt.Errorf("invalid date range %q", expr)
+}
+
+// ParseDate parses a single day in YYYY-MM-DD format.
+func ParseDate(s string) (time.Time, error) {
+	day, err := time.Parse(dateLayout, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD format", s)
+	}
+	return day, nil
+}
+
+func monthRange(year int, month time.Month) DateRange {
+	from := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	return DateRange{From: from, To: from.AddDate(0, 1, -1)}
+}
+
+func quarterRange(year, quarter int) DateRange {
+	from := time.Date(year, time.Month(3*(quarter-1)+1), 1, 0, 0, 0, 0, time.UTC)
+	return DateRange{From: from, To: from.AddDate(0, 3, -1)}
+}
+
+func quarterOf(m time.Month) int {
+	return (int(m)-1)/3 + 1
+}
+
+// weekStart returns the Monday of the week that given day belongs to.
+func weekStart(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// isoWeekStart returns the Monday of given ISO week.
+func isoWeekStart(year, week int) time.Time {
+	// 4 January is always in the first ISO week.
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+	return weekStart(jan4).AddDate(0, 0, 7*(week-1))
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// parseMonth returns the month of given full or abbreviated English name.
+// Matching is case insensitive.
+func parseMonth(name string) (time.Month, bool) {
+	for m := time.January; m <= time.December; m++ {
+		full := m.String()
+		if strings.EqualFold(name, full) || strings.EqualFold(name, full[:3]) {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
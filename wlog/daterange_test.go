@@ -0,0 +1,48 @@
+package wlog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDateRange(t *testing.T) {
+	now := time.Date(2021, time.April, 14, 15, 4, 5, 0, time.Local)
+	cases := map[string]struct {
+		expr string
+		want string
+	}{
+		"day":          {expr: "2021-03-05", want: "2021-03-05..2021-03-05"},
+		"month":        {expr: "2021-02", want: "2021-02-01..2021-02-28"},
+		"month name":   {expr: "September", want: "2021-09-01..2021-09-30"},
+		"iso week":     {expr: "2021-W14", want: "2021-04-05..2021-04-11"},
+		"iso week 53":  {expr: "2020-W53", want: "2020-12-28..2021-01-03"},
+		"quarter":      {expr: "2021-Q2", want: "2021-04-01..2021-06-30"},
+		"year":         {expr: "2020", want: "2020-01-01..2020-12-31"},
+		"today":        {expr: "today", want: "2021-04-14..2021-04-14"},
+		"this week":    {expr: "this-week", want: "2021-04-12..2021-04-18"},
+		"last week":    {expr: "last week", want: "2021-04-05..2021-04-11"},
+		"last month":   {expr: "last-month", want: "2021-03-01..2021-03-31"},
+		"last quarter": {expr: "last-quarter", want: "2021-01-01..2021-03-31"},
+		"last days":    {expr: "last 30 days", want: "2021-03-16..2021-04-14"},
+		"range":        {expr: "2021-01..2021-Q2", want: "2021-01-01..2021-06-30"},
+		"open end":     {expr: "2021-03-10..", want: "2021-03-10.."},
+		"open start":   {expr: "..March", want: "..2021-03-31"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r, err := ParseDateRange(tc.expr, now)
+			if err != nil {
+				t.Fatalf("parse: %s", err)
+			}
+			if got := r.String(); got != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, got)
+			}
+		})
+	}
+
+	for _, expr := range []string{"Septembe", "2021-13", "2021-W54", "last 0 days"} {
+		if _, err := ParseDateRange(expr, now); err == nil {
+			t.Errorf("%q: want error", expr)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package wlog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DurationStyle describes how a duration is presented in the output.
+type DurationStyle string
+
+const (
+	// DurationHM presents duration as hours and minutes, for example 1h30m.
+	DurationHM DurationStyle = "1h30m"
+	// DurationDecimal presents duration as a fractional number of hours,
+	// for example 1.5h.
+	DurationDecimal DurationStyle = "1.5h"
+	// DurationClock presents duration as hours and minutes separated by a
+	// colon, for example 1:30.
+	DurationClock DurationStyle = "1:30"
+)
+
+// DefaultDurationStyle is the style used when none was explicitly requested.
+var DefaultDurationStyle = mustDurationStyle(env("WORKLOG_DURATION", string(DurationHM)))
+
+func mustDurationStyle(s string) DurationStyle {
+	style, err := ParseDurationStyle(s)
+	if err != nil {
+		return DurationHM
+	}
+	return style
+}
+
+// ParseDurationStyle returns the duration style described by given name.
+// Both the example form (1h30m, 1.5h, 1:30) and the descriptive name (hm,
+// decimal, clock) are accepted.
+func ParseDurationStyle(s string) (DurationStyle, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1h30m", "hm":
+		return DurationHM, nil
+	case "1.5h", "decimal":
+		return DurationDecimal, nil
+	case "1:30", "clock":
+		return DurationClock, nil
+	default:
+		return "", fmt.Errorf("unknown duration style %q, valid styles are 1h30m, 1.5h and 1:30", s)
+	}
+}
+
+// FormatDuration returns a text representation of the duration using given
+// style. No precision is lost, minutes and seconds are always included if
+// present. The decimal style uses at most two decimal places, a duration
+// that cannot be written exactly that way, for example 1h20m, is presented
+// in the hours and minutes style instead.
+func FormatDuration(d time.Duration, style DurationStyle) string {
+	var sign string
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	hours := d / time.Hour
+	minutes := (d % time.Hour) / time.Minute
+	seconds := (d % time.Minute) / time.Second
+
+	switch style {
+	case DurationDecimal:
+		if d%(time.Hour/100) == 0 {
+			return sign + strconv.FormatFloat(d.Hours(), 'f', -1, 64) + "h"
+		}
+		return sign + FormatDuration(d, DurationHM)
+	case DurationClock:
+		if seconds != 0 {
+			return fmt.Sprintf("%s%d:%02d:%02d", sign, hours, minutes, seconds)
+		}
+		return fmt.Sprintf("%s%d:%02d", sign, hours, minutes)
+	default:
+		var b strings.Builder
+		b.WriteString(sign)
+		if hours != 0 || (minutes == 0 && seconds == 0) {
+			fmt.Fprintf(&b, "%dh", hours)
+		}
+		if minutes != 0 {
+			fmt.Fprintf(&b, "%dm", minutes)
+		}
+		if seconds != 0 {
+			fmt.Fprintf(&b, "%ds", seconds)
+		}
+		return b.String()
+	}
+}
+
+// ClockDurations returns true if task durations written in the clock style
+// are recognized in worklog documents. A task starting with a value such as
+// 10:00 usually describes the time of day, so the clock style is recognized
+// only if it is the default style, set with WORKLOG_DURATION=1:30.
+func ClockDurations() bool {
+	return DefaultDurationStyle == DurationClock
+}
+
+// ParseDuration parses duration written in any of the supported styles.
+func ParseDuration(s string) (time.Duration, error) {
+	if !strings.Contains(s, ":") {
+		return time.ParseDuration(s)
+	}
+
+	var sign time.Duration = 1
+	raw := s
+	if strings.HasPrefix(s, "-") {
+		sign = -1
+		s = s[1:]
+	}
+	chunks := strings.Split(s, ":")
+	if len(chunks) > 3 {
+		return 0, fmt.Errorf("invalid duration %q", raw)
+	}
+	units := []time.Duration{time.Hour, time.Minute, time.Second}
+	var total time.Duration
+	for i, c := range chunks {
+		n, err := strconv.ParseUint(c, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", raw)
+		}
+		if i > 0 && (len(c) != 2 || n > 59) {
+			return 0, fmt.Errorf("invalid duration %q", raw)
+		}
+		total += time.Duration(n) * units[i]
+	}
+	return sign * total, nil
+}
+
+// RoundingMode specifies in which direction a duration is rounded.
+type RoundingMode string
+
+const (
+	RoundNone    RoundingMode = "none"
+	RoundUp      RoundingMode = "up"
+	RoundDown    RoundingMode = "down"
+	RoundNearest RoundingMode = "nearest"
+)
+
+// Rounding is a policy of adjusting durations to a multiple of a unit, for
+// example rounding up each task to the next quarter of an hour.
+type Rounding struct {
+	Mode RoundingMode
+	Unit time.Duration
+}
+
+// ParseRounding returns rounding policy described by given text. Policy is
+// either "none" or a mode and an unit separated by a colon, for example
+// "up:15m" or "nearest:6m".
+func ParseRounding(s string) (Rounding, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == string(RoundNone) {
+		return Rounding{Mode: RoundNone}, nil
+	}
+	chunks := strings.SplitN(s, ":", 2)
+	if len(chunks) != 2 {
+		return Rounding{}, fmt.Errorf("invalid rounding %q, expected none or <mode>:<unit>", s)
+	}
+	mode := RoundingMode(chunks[0])
+	switch mode {
+	case RoundUp, RoundDown, RoundNearest:
+	default:
+		return Rounding{}, fmt.Errorf("invalid rounding mode %q, valid modes are up, down and nearest", chunks[0])
+	}
+	unit, err := time.ParseDuration(chunks[1])
+	if err != nil {
+		return Rounding{}, fmt.Errorf("invalid rounding unit: %w", err)
+	}
+	if unit <= 0 {
+		return Rounding{}, fmt.Errorf("rounding unit must be positive, got %s", unit)
+	}
+	return Rounding{Mode: mode, Unit: unit}, nil
+}
+
+// Apply returns the duration rounded according to the policy.
+func (r Rounding) Apply(d time.Duration) time.Duration {
+	if r.Unit <= 0 {
+		return d
+	}
+	switch r.Mode {
+	case RoundUp:
+		if rest := d % r.Unit; rest > 0 {
+			return d - rest + r.Unit
+		}
+		return d
+	case RoundDown:
+		return d - d%r.Unit
+	case RoundNearest:
+		return d.Round(r.Unit)
+	default:
+		return d
+	}
+}
+
+func (r Rounding) String() string {
+	if r.Mode == "" || r.Mode == RoundNone || r.Unit <= 0 {
+		return string(RoundNone)
+	}
+	return fmt.Sprintf("%s:%s", r.Mode, FormatDuration(r.Unit, DurationHM))
+}
@@ -0,0 +1,65 @@
+package wlog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatDuration(t *testing.T) {
+	cases := map[string]struct {
+		input time.Duration
+		style DurationStyle
+		want  string
+	}{
+		"zero hm":           {input: 0, style: DurationHM, want: "0h"},
+		"full hours hm":     {input: 8 * time.Hour, style: DurationHM, want: "8h"},
+		"hours and minutes": {input: 90 * time.Minute, style: DurationHM, want: "1h30m"},
+		"minutes only":      {input: 45 * time.Minute, style: DurationHM, want: "45m"},
+		"decimal":           {input: 90 * time.Minute, style: DurationDecimal, want: "1.5h"},
+		"decimal quarter":   {input: 15 * time.Minute, style: DurationDecimal, want: "0.25h"},
+		"decimal inexact":   {input: 80 * time.Minute, style: DurationDecimal, want: "1h20m"},
+		"decimal 6 minutes": {input: 66 * time.Minute, style: DurationDecimal, want: "1.1h"},
+		"clock":             {input: 90 * time.Minute, style: DurationClock, want: "1:30"},
+		"clock padded":      {input: 10*time.Hour + 5*time.Minute, style: DurationClock, want: "10:05"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := FormatDuration(tc.input, tc.style); got != tc.want {
+				t.Fatalf("want %q, got %q", tc.want, got)
+			}
+			back, err := ParseDuration(tc.want)
+			if err != nil {
+				t.Fatalf("parse %q: %s", tc.want, err)
+			}
+			if back != tc.input {
+				t.Fatalf("round trip: want %s, got %s", tc.input, back)
+			}
+		})
+	}
+}
+
+func TestRoundingApply(t *testing.T) {
+	cases := map[string]struct {
+		policy string
+		input  time.Duration
+		want   time.Duration
+	}{
+		"none":                {policy: "none", input: 7 * time.Minute, want: 7 * time.Minute},
+		"up to quarter":       {policy: "up:15m", input: 61 * time.Minute, want: 75 * time.Minute},
+		"up exact":            {policy: "up:15m", input: time.Hour, want: time.Hour},
+		"nearest six minutes": {policy: "nearest:6m", input: 62 * time.Minute, want: 60 * time.Minute},
+		"nearest half up":     {policy: "nearest:6m", input: 63 * time.Minute, want: 66 * time.Minute},
+		"down":                {policy: "down:30m", input: 89 * time.Minute, want: time.Hour},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r, err := ParseRounding(tc.policy)
+			if err != nil {
+				t.Fatalf("parse rounding: %s", err)
+			}
+			if got := r.Apply(tc.input); got != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
@@ -4,29 +4,35 @@ import (
 	"fmt"
 	"io"
 	"strings"
-	"time"
 )
 
 // ToText conver given entries into text format.
 func ToText(w io.Writer, entries []*Entry) error {
+	return ToTextStyle(w, entries, DefaultDurationStyle)
+}
+
+// ToTextStyle conver given entries into text format, using given style for
+// writing task durations.
+func ToTextStyle(w io.Writer, entries []*Entry, style DurationStyle) error {
 	for _, e := range entries {
-		// Ignore empty days.
-		if e.TotalDuration() == 0 {
+		// Ignore empty days, unless marked as a day off.
+		if e.TotalDuration() == 0 && e.Status == StatusWork {
 			continue
 		}
-		if _, err := fmt.Fprintln(w, e.Day.Format(TimeFormat)); err != nil {
+		header := e.Day.Format(TimeFormat)
+		if e.Status != StatusWork {
+			header += " [" + string(e.Status) + "]"
+		}
+		if _, err := fmt.Fprintln(w, header); err != nil {
 			return fmt.Errorf("write entry info: %w", err)
 		}
 		for _, t := range e.Tasks {
-			hours := t.Duration / time.Hour
+			duration := FormatDuration(t.Duration, style)
 			lines := strings.Split(t.Description, "\n")
-			if _, err := fmt.Fprintf(w, "%dh %s\n", hours, strings.TrimSpace(lines[0])); err != nil {
+			if _, err := fmt.Fprintf(w, "%s %s\n", duration, strings.TrimSpace(lines[0])); err != nil {
 				return fmt.Errorf("write task info: %w", err)
 			}
-			indent := strings.Repeat(" ", 3)
-			if hours > 9 {
-				indent = strings.Repeat(" ", 4)
-			}
+			indent := strings.Repeat(" ", len(duration)+1)
 			for _, line := range lines[1:] {
 				if _, err := io.WriteString(w, indent+strings.TrimSpace(line)+"\n"); err != nil {
 					return fmt.Errorf("write task info: %w", err)
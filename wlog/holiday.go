@@ -0,0 +1,521 @@
+package wlog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Holiday is a public holiday or any other day without work.
+type Holiday struct {
+	Day  time.Time
+	Name string
+}
+
+// HolidayCalendar computes public holidays of a region for any year. It is
+// built from rules and does not require any external data.
+type HolidayCalendar struct {
+	Region string
+	rules  []holidayRule
+	cache  map[int][]Holiday
+}
+
+// holidayRule describes how to compute a single holiday.
+type holidayRule struct {
+	name string
+	kind ruleKind
+
+	month   time.Month
+	day     int
+	offset  int
+	nth     int
+	weekday time.Weekday
+
+	// year is the only year of a one time holiday. firstYear is the year
+	// the holiday was introduced. Zero means no limit. In the except years
+	// the holiday was moved or cancelled.
+	year      int
+	firstYear int
+	except    []int
+
+	// Holiday that falls on a weekend is moved, either to the nearest
+	// weekday (observed) or to the next free weekday (substituted).
+	observed    bool
+	substituted bool
+}
+
+type ruleKind int
+
+const (
+	// fixedRule is the same date every year.
+	fixedRule ruleKind = iota
+	// easterRule is the offset in days from the Easter Sunday.
+	easterRule
+	// nthRule is the nth weekday of the month.
+	nthRule
+	// onOrAfterRule is the first weekday on or after the date.
+	onOrAfterRule
+)
+
+func (r holidayRule) in(year int) bool {
+	if r.year != 0 && r.year != year {
+		return false
+	}
+	for _, y := range r.except {
+		if y == year {
+			return false
+		}
+	}
+	return r.firstYear == 0 || year >= r.firstYear
+}
+
+func (r holidayRule) dayIn(year int) time.Time {
+	switch r.kind {
+	case easterRule:
+		return Easter(year).AddDate(0, 0, r.offset)
+	case nthRule:
+		return nthWeekday(year, r.month, r.nth, r.weekday)
+	case onOrAfterRule:
+		day := time.Date(year, r.month, r.day, 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, (int(r.weekday)-int(day.Weekday())+7)%7)
+	default:
+		return time.Date(year, r.month, r.day, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func fixed(month time.Month, day int, name string) holidayRule {
+	return holidayRule{name: name, kind: fixedRule, month: month, day: day}
+}
+
+func easter(offset int, name string) holidayRule {
+	return holidayRule{name: name, kind: easterRule, offset: offset}
+}
+
+// nth returns a rule of the nth weekday of the month. Negative n counts from
+// the end of the month, -1 is the last weekday of the month.
+func nth(n int, weekday time.Weekday, month time.Month, name string) holidayRule {
+	return holidayRule{name: name, kind: nthRule, month: month, nth: n, weekday: weekday}
+}
+
+func onOrAfter(weekday time.Weekday, month time.Month, day int, name string) holidayRule {
+	return holidayRule{name: name, kind: onOrAfterRule, month: month, day: day, weekday: weekday}
+}
+
+func (r holidayRule) from(year int) holidayRule {
+	r.firstYear = year
+	return r
+}
+
+func (r holidayRule) only(year int) holidayRule {
+	r.year = year
+	return r
+}
+
+// skip returns the rule that does not apply in given years, for example
+// because the holiday was moved to another day.
+func (r holidayRule) skip(years ...int) holidayRule {
+	r.except = append(append([]int(nil), r.except...), years...)
+	return r
+}
+
+func (r holidayRule) observe() holidayRule {
+	r.observed = true
+	return r
+}
+
+func (r holidayRule) substitute() holidayRule {
+	r.substituted = true
+	return r
+}
+
+// Easter returns the Easter Sunday of given year in the Gregorian calendar.
+func Easter(year int) time.Time {
+	// Anonymous Gregorian algorithm.
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+func nthWeekday(year int, month time.Month, n int, weekday time.Weekday) time.Time {
+	if n < 0 {
+		last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+		back := (int(last.Weekday()) - int(weekday) + 7) % 7
+		return last.AddDate(0, 0, -back+7*(n+1))
+	}
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	forward := (int(weekday) - int(first.Weekday()) + 7) % 7
+	return first.AddDate(0, 0, forward+7*(n-1))
+}
+
+var (
+	germany = []holidayRule{
+		fixed(time.January, 1, "New Year's Day"),
+		easter(-2, "Good Friday"),
+		easter(1, "Easter Monday"),
+		fixed(time.May, 1, "Labour Day"),
+		easter(39, "Ascension Day"),
+		easter(50, "Whit Monday"),
+		fixed(time.October, 3, "German Unity Day"),
+		fixed(time.October, 31, "Reformation Day").only(2017),
+		fixed(time.December, 25, "Christmas Day"),
+		fixed(time.December, 26, "Second Day of Christmas"),
+	}
+
+	epiphany         = fixed(time.January, 6, "Epiphany")
+	corpusChristi    = easter(60, "Corpus Christi")
+	assumption       = fixed(time.August, 15, "Assumption Day")
+	allSaints        = fixed(time.November, 1, "All Saints' Day")
+	reformation      = fixed(time.October, 31, "Reformation Day")
+	reformation2018  = reformation.from(2018)
+	repentanceDay    = onOrAfter(time.Wednesday, time.November, 16, "Repentance and Prayer Day")
+	womensDay        = fixed(time.March, 8, "International Women's Day")
+	childrensDay     = fixed(time.September, 20, "World Children's Day").from(2019)
+	easterSunday     = easter(0, "Easter Sunday")
+	whitSunday       = easter(49, "Whit Sunday")
+	germanStateRules = map[string][]holidayRule{
+		"BW": {epiphany, corpusChristi, allSaints},
+		"BY": {epiphany, corpusChristi, assumption, allSaints},
+		"BE": {
+			womensDay.from(2019),
+			fixed(time.May, 8, "Liberation Day").only(2020),
+			fixed(time.May, 8, "Liberation Day").only(2025),
+		},
+		"BB": {easterSunday, whitSunday, reformation},
+		"HB": {reformation2018},
+		"HH": {reformation2018},
+		"HE": {corpusChristi},
+		"MV": {reformation, womensDay.from(2023)},
+		"NI": {reformation2018},
+		"NW": {corpusChristi, allSaints},
+		"RP": {corpusChristi, allSaints},
+		"SL": {corpusChristi, assumption, allSaints},
+		"SN": {reformation, repentanceDay},
+		"ST": {epiphany, reformation},
+		"SH": {reformation2018},
+		"TH": {reformation, childrensDay},
+	}
+
+	austria = []holidayRule{
+		fixed(time.January, 1, "New Year's Day"),
+		epiphany,
+		easter(1, "Easter Monday"),
+		fixed(time.May, 1, "National Holiday"),
+		easter(39, "Ascension Day"),
+		easter(50, "Whit Monday"),
+		corpusChristi,
+		assumption,
+		fixed(time.October, 26, "National Day"),
+		allSaints,
+		fixed(time.December, 8, "Immaculate Conception"),
+		fixed(time.December, 25, "Christmas Day"),
+		fixed(time.December, 26, "St. Stephen's Day"),
+	}
+
+	poland = []holidayRule{
+		fixed(time.January, 1, "New Year's Day"),
+		epiphany.from(2011),
+		easterSunday,
+		easter(1, "Easter Monday"),
+		fixed(time.May, 1, "Labour Day"),
+		fixed(time.May, 3, "Constitution Day"),
+		whitSunday,
+		corpusChristi,
+		assumption,
+		allSaints,
+		fixed(time.November, 11, "Independence Day"),
+		fixed(time.December, 24, "Christmas Eve").from(2025),
+		fixed(time.December, 25, "Christmas Day"),
+		fixed(time.December, 26, "Second Day of Christmas"),
+	}
+
+	unitedKingdom = []holidayRule{
+		easter(-2, "Good Friday"),
+		nth(1, time.Monday, time.May, "Early May Bank Holiday").skip(2020),
+		nth(-1, time.Monday, time.May, "Spring Bank Holiday").skip(2012, 2022),
+		fixed(time.December, 25, "Christmas Day").substitute(),
+		fixed(time.December, 26, "Boxing Day").substitute(),
+
+		// Bank holidays moved or added by a royal proclamation.
+		fixed(time.April, 29, "Royal Wedding").only(2011),
+		fixed(time.June, 4, "Spring Bank Holiday").only(2012),
+		fixed(time.June, 5, "Diamond Jubilee").only(2012),
+		fixed(time.May, 8, "Early May Bank Holiday (VE Day)").only(2020),
+		fixed(time.June, 2, "Spring Bank Holiday").only(2022),
+		fixed(time.June, 3, "Platinum Jubilee").only(2022),
+		fixed(time.September, 19, "State Funeral of Queen Elizabeth II").only(2022),
+		fixed(time.May, 8, "Coronation of King Charles III").only(2023),
+	}
+	englandAndWales = append([]holidayRule{
+		fixed(time.January, 1, "New Year's Day").substitute(),
+		easter(1, "Easter Monday"),
+		nth(-1, time.Monday, time.August, "Summer Bank Holiday"),
+	}, unitedKingdom...)
+	scotland = append([]holidayRule{
+		fixed(time.January, 1, "New Year's Day").substitute(),
+		fixed(time.January, 2, "2nd January").substitute(),
+		nth(1, time.Monday, time.August, "Summer Bank Holiday"),
+		fixed(time.November, 30, "St Andrew's Day").substitute(),
+	}, unitedKingdom...)
+	northernIreland = append([]holidayRule{
+		fixed(time.January, 1, "New Year's Day").substitute(),
+		fixed(time.March, 17, "St Patrick's Day").substitute(),
+		easter(1, "Easter Monday"),
+		fixed(time.July, 12, "Battle of the Boyne").substitute(),
+		nth(-1, time.Monday, time.August, "Summer Bank Holiday"),
+	}, unitedKingdom...)
+
+	unitedStates = []holidayRule{
+		fixed(time.January, 1, "New Year's Day").observe(),
+		nth(3, time.Monday, time.January, "Martin Luther King Jr. Day").from(1986),
+		nth(3, time.Monday, time.February, "Washington's Birthday"),
+		nth(-1, time.Monday, time.May, "Memorial Day"),
+		fixed(time.June, 19, "Juneteenth").from(2021).observe(),
+		fixed(time.July, 4, "Independence Day").observe(),
+		nth(1, time.Monday, time.September, "Labor Day"),
+		nth(2, time.Monday, time.October, "Columbus Day"),
+		fixed(time.November, 11, "Veterans Day").observe(),
+		nth(4, time.Thursday, time.November, "Thanksgiving Day"),
+		fixed(time.December, 25, "Christmas Day").observe(),
+	}
+)
+
+// holidayRegions maps the region code to the holiday rules. Codes follow
+// ISO 3166, GB and UK both refer to England and Wales.
+var holidayRegions = func() map[string][]holidayRule {
+	regions := map[string][]holidayRule{
+		"DE":     germany,
+		"AT":     austria,
+		"PL":     poland,
+		"GB":     englandAndWales,
+		"UK":     englandAndWales,
+		"GB-ENG": englandAndWales,
+		"GB-WLS": englandAndWales,
+		"GB-SCT": scotland,
+		"GB-NIR": northernIreland,
+		"US":     unitedStates,
+	}
+	for state, rules := range germanStateRules {
+		regions["DE-"+state] = append(append([]holidayRule(nil), germany...), rules...)
+	}
+	return regions
+}()
+
+// HolidayRegions returns the sorted list of all supported region codes.
+func HolidayRegions() []string {
+	codes := make([]string, 0, len(holidayRegions))
+	for code := range holidayRegions {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// NewHolidayCalendar returns the calendar of public holidays in given
+// region, for example DE-BY, PL or US. Empty region returns a calendar
+// without any holidays, that can be extended with AddDays.
+func NewHolidayCalendar(region string) (*HolidayCalendar, error) {
+	region = strings.ToUpper(strings.TrimSpace(region))
+	c := &HolidayCalendar{Region: region}
+	if region == "" {
+		return c, nil
+	}
+	rules, ok := holidayRegions[region]
+	if !ok {
+		return nil, fmt.Errorf("unknown holiday region %q, valid regions are %s", region, strings.Join(HolidayRegions(), ", "))
+	}
+	c.rules = append(c.rules, rules...)
+	return c, nil
+}
+
+var (
+	extraEasterRx = regexp.MustCompile(`^(?i)easter([+-]\d+)?$`)
+	extraDayRx    = regexp.MustCompile(`^(\d{2})-(\d{2})$`)
+)
+
+// AddDays reads user defined holidays and adds them to the calendar. Each
+// line contains a day followed by the holiday name. A day is either a date,
+// a month and day repeated every year or an offset from the Easter Sunday.
+// Lines starting with # are comments. For example:
+//
+//	2021-06-14 Company anniversary
+//	12-24 Christmas Eve
+//	easter-3 Maundy Thursday
+func (c *HolidayCalendar) AddDays(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	var lineNo int
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		when, name := line, "holiday"
+		if i := strings.IndexFunc(line, isSpace); i >= 0 {
+			when, name = line[:i], strings.TrimSpace(line[i:])
+		}
+
+		var rule holidayRule
+		if m := extraEasterRx.FindStringSubmatch(when); m != nil {
+			offset, _ := strconv.Atoi(m[1])
+			rule = easter(offset, name)
+		} else if m := extraDayRx.FindStringSubmatch(when); m != nil {
+			day, err := time.Parse(dateLayout, "2000-"+when)
+			if err != nil {
+				return fmt.Errorf("holidays:%d: invalid day %q", lineNo, when)
+			}
+			rule = fixed(day.Month(), day.Day(), name)
+		} else {
+			day, err := ParseDate(when)
+			if err != nil {
+				return fmt.Errorf("holidays:%d: invalid day %q, expected YYYY-MM-DD, MM-DD or easter+N", lineNo, when)
+			}
+			rule = fixed(day.Month(), day.Day(), name).only(day.Year())
+		}
+		c.rules = append(c.rules, rule)
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+	c.cache = nil
+	return nil
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t'
+}
+
+// Holidays returns all holidays of given year, ordered by day.
+func (c *HolidayCalendar) Holidays(year int) []Holiday {
+	if c == nil {
+		return nil
+	}
+	if hs, ok := c.cache[year]; ok {
+		return hs
+	}
+
+	// Moved holidays can cross the year boundary, for example the New
+	// Year's Day observed on 31 December.
+	var all []Holiday
+	for y := year - 1; y <= year+1; y++ {
+		all = append(all, c.compute(y)...)
+	}
+	var res []Holiday
+	for _, h := range all {
+		if h.Day.Year() == year {
+			res = append(res, h)
+		}
+	}
+
+	if c.cache == nil {
+		c.cache = make(map[int][]Holiday)
+	}
+	c.cache[year] = res
+	return res
+}
+
+func (c *HolidayCalendar) compute(year int) []Holiday {
+	var (
+		res   []Holiday
+		moved []holidayRule
+		taken = make(map[time.Time]bool)
+	)
+	for _, r := range c.rules {
+		if !r.in(year) {
+			continue
+		}
+		day := r.dayIn(year)
+		res = append(res, Holiday{Day: day, Name: r.name})
+		if isWeekend(day) && (r.observed || r.substituted) {
+			moved = append(moved, r)
+		} else {
+			taken[day] = true
+		}
+	}
+	sort.SliceStable(moved, func(i, j int) bool {
+		return moved[i].dayIn(year).Before(moved[j].dayIn(year))
+	})
+	for _, r := range moved {
+		day := r.dayIn(year)
+		if r.observed {
+			// Saturday holiday is observed on Friday, Sunday
+			// holiday on Monday.
+			if day.Weekday() == time.Saturday {
+				day = day.AddDate(0, 0, -1)
+			} else {
+				day = day.AddDate(0, 0, 1)
+			}
+			res = append(res, Holiday{Day: day, Name: r.name + " (observed)"})
+			continue
+		}
+		for isWeekend(day) || taken[day] {
+			day = day.AddDate(0, 0, 1)
+		}
+		taken[day] = true
+		res = append(res, Holiday{Day: day, Name: r.name + " (substitute day)"})
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Day.Before(res[j].Day)
+	})
+	// Regional rules can repeat a country wide holiday.
+	uniq := res[:0]
+	for i, h := range res {
+		if i > 0 && h == res[i-1] {
+			continue
+		}
+		uniq = append(uniq, h)
+	}
+	return uniq
+}
+
+// Holiday returns the name of the holiday on given day. If there is more
+// than one holiday on that day, names are joined.
+func (c *HolidayCalendar) Holiday(day time.Time) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	day = truncateDay(day)
+	var names []string
+	for _, h := range c.Holidays(day.Year()) {
+		if h.Day.Equal(day) {
+			names = append(names, h.Name)
+		}
+	}
+	return strings.Join(names, ", "), len(names) != 0
+}
+
+// WorkingDays returns the number of days from Monday to Friday in the range
+// that are not holidays. Range must be closed.
+func (c *HolidayCalendar) WorkingDays(dr DateRange) int {
+	var n int
+	for day := truncateDay(dr.From); !day.After(dr.To); day = day.AddDate(0, 0, 1) {
+		if isWeekend(day) {
+			continue
+		}
+		if _, ok := c.Holiday(day); ok {
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+func isWeekend(day time.Time) bool {
+	return day.Weekday() == time.Saturday || day.Weekday() == time.Sunday
+}
@@ -0,0 +1,189 @@
+package wlog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEaster(t *testing.T) {
+	cases := map[int]string{
+		2019: "2019-04-21",
+		2021: "2021-04-04",
+		2022: "2022-04-17",
+		2024: "2024-03-31",
+		2038: "2038-04-25",
+	}
+	for year, want := range cases {
+		if got := Easter(year).Format(dateLayout); got != want {
+			t.Errorf("%d: want %s, got %s", year, want, got)
+		}
+	}
+}
+
+func TestHolidayCalendar(t *testing.T) {
+	cases := map[string]struct {
+		region  string
+		year    int
+		want    []string
+		notWant []string
+	}{
+		"bavaria": {
+			region: "DE-BY",
+			year:   2021,
+			want: []string{
+				"2021-01-01 New Year's Day",
+				"2021-01-06 Epiphany",
+				"2021-04-02 Good Friday",
+				"2021-04-05 Easter Monday",
+				"2021-05-01 Labour Day",
+				"2021-05-13 Ascension Day",
+				"2021-05-24 Whit Monday",
+				"2021-06-03 Corpus Christi",
+				"2021-08-15 Assumption Day",
+				"2021-10-03 German Unity Day",
+				"2021-11-01 All Saints' Day",
+				"2021-12-25 Christmas Day",
+				"2021-12-26 Second Day of Christmas",
+			},
+		},
+		"saxony repentance day": {
+			region: "DE-SN",
+			year:   2022,
+			want: []string{
+				"2022-10-31 Reformation Day",
+				"2022-11-16 Repentance and Prayer Day",
+			},
+		},
+		"reformation anniversary is not repeated": {
+			region: "DE-BB",
+			year:   2017,
+			want:   []string{"2017-10-31 Reformation Day"},
+		},
+		"england substitute days": {
+			region: "GB",
+			year:   2021,
+			want: []string{
+				"2021-05-03 Early May Bank Holiday",
+				"2021-05-31 Spring Bank Holiday",
+				"2021-08-30 Summer Bank Holiday",
+				"2021-12-25 Christmas Day",
+				"2021-12-26 Boxing Day",
+				"2021-12-27 Christmas Day (substitute day)",
+				"2021-12-28 Boxing Day (substitute day)",
+			},
+		},
+		"england 2020 moved early may": {
+			region:  "GB",
+			year:    2020,
+			want:    []string{"2020-05-08 Early May Bank Holiday (VE Day)"},
+			notWant: []string{"2020-05-04 Early May Bank Holiday"},
+		},
+		"england 2022 platinum jubilee": {
+			region: "GB",
+			year:   2022,
+			want: []string{
+				"2022-05-02 Early May Bank Holiday",
+				"2022-06-02 Spring Bank Holiday",
+				"2022-06-03 Platinum Jubilee",
+				"2022-09-19 State Funeral of Queen Elizabeth II",
+			},
+			notWant: []string{"2022-05-30 Spring Bank Holiday"},
+		},
+		"scotland 2023 coronation": {
+			region: "GB-SCT",
+			year:   2023,
+			want: []string{
+				"2023-05-01 Early May Bank Holiday",
+				"2023-05-08 Coronation of King Charles III",
+				"2023-05-29 Spring Bank Holiday",
+			},
+		},
+		"berlin 2020 liberation day": {
+			region: "DE-BE",
+			year:   2020,
+			want:   []string{"2020-03-08 International Women's Day", "2020-05-08 Liberation Day"},
+		},
+		"berlin liberation day is not repeated": {
+			region:  "DE-BE",
+			year:    2021,
+			notWant: []string{"2021-05-08 Liberation Day"},
+		},
+		"us observed": {
+			region: "US",
+			year:   2021,
+			want: []string{
+				"2021-01-18 Martin Luther King Jr. Day",
+				"2021-06-18 Juneteenth (observed)",
+				"2021-07-05 Independence Day (observed)",
+				"2021-11-25 Thanksgiving Day",
+				"2021-12-24 Christmas Day (observed)",
+				"2021-12-31 New Year's Day (observed)",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c, err := NewHolidayCalendar(tc.region)
+			if err != nil {
+				t.Fatalf("new calendar: %s", err)
+			}
+			got := make(map[string]bool)
+			for _, h := range c.Holidays(tc.year) {
+				got[h.Day.Format(dateLayout)+" "+h.Name] = true
+			}
+			for _, w := range tc.want {
+				if !got[w] {
+					t.Errorf("missing %s", w)
+				}
+			}
+			for _, w := range tc.notWant {
+				if got[w] {
+					t.Errorf("unexpected %s", w)
+				}
+			}
+			if tc.region == "DE-BY" && len(got) != len(tc.want) {
+				t.Errorf("want %d holidays, got %d", len(tc.want), len(got))
+			}
+		})
+	}
+}
+
+func TestHolidayCalendarAddDays(t *testing.T) {
+	c, err := NewHolidayCalendar("PL")
+	if err != nil {
+		t.Fatalf("new calendar: %s", err)
+	}
+	err = c.AddDays(strings.NewReader(`
+# Company days.
+2021-06-14 Company anniversary
+12-31 New Year's Eve
+easter-3 Maundy Thursday
+`))
+	if err != nil {
+		t.Fatalf("add days: %s", err)
+	}
+	for day, want := range map[string]string{
+		"2021-06-14": "Company anniversary",
+		"2022-12-31": "New Year's Eve",
+		"2021-04-01": "Maundy Thursday",
+		"2021-11-11": "Independence Day",
+	} {
+		d, _ := ParseDate(day)
+		if got, _ := c.Holiday(d); got != want {
+			t.Errorf("%s: want %q, got %q", day, want, got)
+		}
+	}
+	if _, ok := c.Holiday(time.Date(2022, time.June, 14, 0, 0, 0, 0, time.UTC)); ok {
+		t.Errorf("a dated holiday must not repeat")
+	}
+
+	dr, _ := ParseDateRange("2021-11", time.Now())
+	if got := c.WorkingDays(dr); got != 20 {
+		t.Errorf("want 20 working days in November, got %d", got)
+	}
+
+	if err := c.AddDays(strings.NewReader("02-30 invalid")); err == nil {
+		t.Errorf("want error for an invalid day")
+	}
+}
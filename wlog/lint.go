@@ -0,0 +1,129 @@
+package wlog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Diagnostic describes a single problem found in the worklog content.
+type Diagnostic struct {
+	Line    int
+	Col     int
+	Message string
+	// Structural is true if the problem changes how the document is
+	// read, for example an unresolved merge conflict or an invalid day
+	// header that makes its tasks a part of the previous day.
+	Structural bool
+}
+
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("%d:%d: %s", d.Line, d.Col, d.Message)
+}
+
+// Diagnostics is a list of problems found in the worklog content.
+type Diagnostics []Diagnostic
+
+func (ds Diagnostics) Error() string {
+	switch len(ds) {
+	case 0:
+		return "no problems"
+	case 1:
+		return ds[0].Error()
+	default:
+		msgs := make([]string, 0, len(ds))
+		for _, d := range ds {
+			msgs = append(msgs, d.Error())
+		}
+		return fmt.Sprintf("%d problems: %s", len(ds), strings.Join(msgs, "; "))
+	}
+}
+
+// Structural returns only the structural problems, see
+// Diagnostic.Structural.
+func (ds Diagnostics) Structural() Diagnostics {
+	var structural Diagnostics
+	for _, d := range ds {
+		if d.Structural {
+			structural = append(structural, d)
+		}
+	}
+	return structural
+}
+
+// Sort orders diagnostics by their position in the source.
+func (ds Diagnostics) Sort() {
+	sort.SliceStable(ds, func(i, j int) bool {
+		if ds[i].Line != ds[j].Line {
+			return ds[i].Line < ds[j].Line
+		}
+		return ds[i].Col < ds[j].Col
+	})
+}
+
+// MaxDayDuration is the longest time that can be logged for a single day.
+const MaxDayDuration = 24 * time.Hour
+
+// Lint returns all semantic problems found in given entries. Entries are
+// expected to be in the order as they were parsed. Any day after now is
+// considered a mistake.
+func Lint(entries []*Entry, now time.Time) Diagnostics {
+	var diags Diagnostics
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	seen := make(map[time.Time]*Entry)
+	var prev *Entry
+	for _, e := range entries {
+		if first, ok := seen[e.Day]; ok {
+			diags = append(diags, Diagnostic{
+				Line:    e.Line,
+				Col:     e.Col,
+				Message: fmt.Sprintf("duplicate day header, %s was first defined at line %d", e.Day.Format("2 Jan 2006"), first.Line),
+			})
+		} else {
+			seen[e.Day] = e
+		}
+		if prev != nil && e.Day.Before(prev.Day) {
+			diags = append(diags, Diagnostic{
+				Line:    e.Line,
+				Col:     e.Col,
+				Message: fmt.Sprintf("day out of order, %s comes after %s", e.Day.Format("2 Jan 2006"), prev.Day.Format("2 Jan 2006")),
+			})
+		}
+		prev = e
+
+		if e.Day.After(today) {
+			diags = append(diags, Diagnostic{
+				Line:    e.Line,
+				Col:     e.Col,
+				Message: fmt.Sprintf("%s is in the future", e.Day.Format("2 Jan 2006")),
+			})
+		}
+		if total := e.TotalDuration(); total > MaxDayDuration {
+			diags = append(diags, Diagnostic{
+				Line:    e.Line,
+				Col:     e.Col,
+				Message: fmt.Sprintf("day total of %s is more than %s", FormatDuration(total, DurationHM), FormatDuration(MaxDayDuration, DurationHM)),
+			})
+		}
+		for _, t := range e.Tasks {
+			if t.Duration == 0 {
+				msg := "task without duration"
+				if word, _ := firstWord(t.Description); strings.Contains(word, ":") && !ClockDurations() {
+					if _, err := ParseDuration(word); err == nil {
+						msg = fmt.Sprintf("task without duration, %q is read as the time of day, set WORKLOG_DURATION=1:30 to log durations in the clock style", word)
+					}
+				}
+				diags = append(diags, Diagnostic{
+					Line:    t.Line,
+					Col:     t.Col,
+					Message: msg,
+				})
+			}
+		}
+	}
+
+	diags.Sort()
+	return diags
+}
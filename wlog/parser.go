@@ -1,7 +1,6 @@
 package wlog
 
 import (
-	"bufio"
 	"fmt"
 	"io"
 	"strings"
@@ -9,60 +8,42 @@ import (
 	"unicode"
 )
 
-// Parse given worklog.
+// Parse given worklog. Parse is lenient and accepts any content. Lines that
+// cannot be understood are folded into task descriptions. Use ParseStrict to
+// learn about problems with the content. The only content that is rejected
+// are unresolved merge conflicts, because entries of both versions would be
+// counted.
 func Parse(r io.Reader) ([]*Entry, error) {
-	var entries []*Entry
-
-	rd := bufio.NewReader(r)
-
-	currentTask := &Task{}
-	currentEntry := &Entry{}
-	for {
-		line, err := rd.ReadString('\n')
-		switch err {
-		case nil:
-			// all good
-		case io.EOF:
-			if line == "" {
-				return entries, nil
-			}
-		default:
-			return nil, fmt.Errorf("read line: %w", err)
-		}
-		line = strings.TrimSpace(line)
-		if len(line) == 0 {
-			continue
-		}
-
-		if t, err := time.Parse(TimeFormat, line); err == nil {
-			if len(currentTask.Description) > 0 && len(currentEntry.Tasks) == 0 {
-				currentEntry.Tasks = append(currentEntry.Tasks, currentTask)
-			}
-			currentTask = &Task{}
-			currentEntry = &Entry{Day: t}
-			entries = append(entries, currentEntry)
-			continue
-		}
-
-		if potentialDuration, offset := firstWord(line); len(potentialDuration) != 0 {
-			if d, err := time.ParseDuration(potentialDuration); err == nil {
-				if len(currentTask.Description) > 0 && len(currentEntry.Tasks) == 0 {
-					currentEntry.Tasks = append(currentEntry.Tasks, currentTask)
-				}
-				currentTask = &Task{Duration: d}
-				currentEntry.Tasks = append(currentEntry.Tasks, currentTask)
-				line = line[offset:]
-			}
-		}
+	f, err := ParseFile(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.CheckConflicts(); err != nil {
+		return nil, err
+	}
+	return f.Entries(), nil
+}
 
-		line = strings.TrimSpace(line)
-		line = strings.ReplaceAll(line, "; ", ".\n")
-		if len(currentTask.Description) != 0 {
-			currentTask.Description += "\n"
-		}
-		currentTask.Description += line
+// ParseStrict parses given worklog the same way Parse does, but in addition
+// returns Diagnostics error if any line of the content is malformed.
+// Entries are always returned, even if the content contains problems.
+func ParseStrict(r io.Reader) ([]*Entry, error) {
+	entries, diags, err := parse(r)
+	if err != nil {
+		return nil, err
 	}
+	if len(diags) != 0 {
+		return entries, diags
+	}
+	return entries, nil
+}
 
+func parse(r io.Reader) ([]*Entry, Diagnostics, error) {
+	f, err := ParseFile(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f.Entries(), f.Diagnostics, nil
 }
 
 func firstWord(line string) (string, int) {
@@ -74,11 +55,94 @@ func firstWord(line string) (string, int) {
 	return line, len(line)
 }
 
+// isHeaderLike returns true if given line starts the same way as the day
+// header does, but it was not possible to parse it.
+func isHeaderLike(line string) bool {
+	prefix := headerPrefix()
+	if prefix == "" || !strings.HasPrefix(line, prefix) {
+		return false
+	}
+	rest := strings.TrimSpace(line[len(prefix):])
+	return rest != "" && unicode.IsDigit(rune(rest[0]))
+}
+
+// headerPrefix returns the literal text that every day header starts with.
+func headerPrefix() string {
+	for i, c := range TimeFormat {
+		if unicode.IsDigit(c) || unicode.IsLetter(c) {
+			return TimeFormat[:i]
+		}
+	}
+	return TimeFormat
+}
+
+// headerError returns a short explanation of why header parsing failed.
+func headerError(err error) string {
+	if pe, ok := err.(*time.ParseError); ok && pe.Message != "" {
+		return strings.TrimPrefix(pe.Message, ": ")
+	}
+	return fmt.Sprintf("does not match %q format", TimeFormat)
+}
+
+// isDurationLike returns true if given word was most likely meant to be a
+// duration, for example "2hh" or "1:3".
+func isDurationLike(word string) bool {
+	if word == "" || !unicode.IsDigit(rune(word[0])) {
+		return false
+	}
+	var hasUnit bool
+	for _, c := range word {
+		switch {
+		case unicode.IsDigit(c), c == '.':
+		case strings.ContainsRune("hms:", c):
+			hasUnit = true
+		default:
+			return false
+		}
+	}
+	return hasUnit
+}
+
+// checkWeekday returns a diagnostic if the header contains a weekday name
+// that does not match the date. Diagnostic column is relative to the line
+// start.
+func checkWeekday(line string, day time.Time) (Diagnostic, bool) {
+	for i := 0; i < len(line); {
+		word, offset := firstWord(line[i:])
+		if weekday, ok := parseWeekday(word); ok && weekday != day.Weekday() {
+			return Diagnostic{
+				Col:     i,
+				Message: fmt.Sprintf("%s is %s, not %s", day.Format("2 Jan 2006"), day.Weekday(), weekday),
+			}, true
+		}
+		i += offset + 1
+	}
+	return Diagnostic{}, false
+}
+
+// parseWeekday returns the weekday of given full or abbreviated English
+// name. Matching is case insensitive.
+func parseWeekday(name string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		full := d.String()
+		if strings.EqualFold(name, full) || strings.EqualFold(name, full[:3]) {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
 var TimeFormat = env("WORKLOG_HEADER", "# 2 Jan 2006 Monday")
 
 type Entry struct {
 	Day   time.Time
 	Tasks []*Task
+	// Status is set if the day is marked as a day off.
+	Status DayStatus
+
+	// Line and Col point at the day header in the source.
+	Line int
+	Col  int
 }
 
 func (e *Entry) TotalDuration() time.Duration {
@@ -92,4 +156,16 @@ func (e *Entry) TotalDuration() time.Duration {
 type Task struct {
 	Duration    time.Duration
 	Description string
+
+	// Annotations found in the description. Project is the first +project,
+	// Client is the first @client, Tags are all #tag and Attrs are all
+	// key:value annotations. Names are stored without the prefix.
+	Project string
+	Client  string
+	Tags    []string
+	Attrs   map[string]string
+
+	// Line and Col point at the first line of the task in the source.
+	Line int
+	Col  int
 }
@@ -0,0 +1,165 @@
+package wlog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStrictDiagnostics(t *testing.T) {
+	const content = `notes
+# 2 Mar 2021 Monday
+2hh typo
+1h30m ok
+   continued
+
+# 31 Feb 2021 Sunday
+`
+	entries, err := ParseStrict(strings.NewReader(content))
+	var diags Diagnostics
+	if !errors.As(err, &diags) {
+		t.Fatalf("want diagnostics, got %v", err)
+	}
+	want := []string{
+		`1:1: text before the first day header`,
+		`2:14: 2 Mar 2021 is Tuesday, not Monday`,
+		`3:1: invalid duration "2hh"`,
+		`7:1: invalid day header "# 31 Feb 2021 Sunday": day out of range`,
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("want %d diagnostics, got %d: %v", len(want), len(diags), diags)
+	}
+	for i, d := range diags {
+		if d.Error() != want[i] {
+			t.Errorf("diagnostic %d: want %q, got %q", i, want[i], d.Error())
+		}
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("want one entry, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Line != 2 || e.Col != 1 {
+		t.Errorf("want entry at 2:1, got %d:%d", e.Line, e.Col)
+	}
+	if len(e.Tasks) != 2 {
+		t.Fatalf("want two tasks, got %d", len(e.Tasks))
+	}
+	if task := e.Tasks[1]; task.Line != 4 || task.Duration != 90*time.Minute || !strings.HasPrefix(task.Description, "ok\ncontinued") {
+		t.Errorf("unexpected task: %+v", task)
+	}
+}
+
+func TestParseStrictConflictMarkers(t *testing.T) {
+	const content = `# 1 Mar 2021 Monday
+<<<<<<< local
+2h review
+=======
+3h review
+>>>>>>> remote
+1h coding
+`
+	file, err := ParseFile(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	if !file.HasConflicts() {
+		t.Fatal("want conflicts found")
+	}
+	want := []string{
+		`2:1: unresolved merge conflict, keep the right version and remove the markers`,
+		`4:1: unresolved merge conflict marker =======`,
+		`6:1: unresolved merge conflict marker >>>>>>>`,
+	}
+	if len(file.Diagnostics) != len(want) {
+		t.Fatalf("want %d diagnostics, got %d: %v", len(want), len(file.Diagnostics), file.Diagnostics)
+	}
+	for i, d := range file.Diagnostics {
+		if d.Error() != want[i] {
+			t.Errorf("diagnostic %d: want %q, got %q", i, want[i], d.Error())
+		}
+	}
+	// Markers do not continue the task description.
+	entries := file.Entries()
+	if len(entries) != 1 || len(entries[0].Tasks) != 3 {
+		t.Fatalf("want one day with three tasks, got %+v", entries)
+	}
+	if desc := entries[0].Tasks[0].Description; desc != "review" {
+		t.Fatalf("want review task, got %q", desc)
+	}
+
+	// Both versions would be counted, so Parse rejects the content.
+	if _, err := Parse(strings.NewReader(content)); !errors.Is(err, ErrConflict) {
+		t.Fatalf("want conflict error, got %v", err)
+	} else if !strings.HasPrefix(err.Error(), "line 2: ") {
+		t.Fatalf("want the first marker line in the error, got %q", err)
+	}
+}
+
+func TestLint(t *testing.T) {
+	const content = `# 2 Mar 2021 Tuesday
+25h too much
+
+# 1 Mar 2021 Monday
+1h ok
+
+# 1 Mar 2021 Monday
+
+# 1 Mar 2030 Friday
+0h nothing
+`
+	entries, err := ParseStrict(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	now := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	var got []string
+	for _, d := range Lint(entries, now) {
+		got = append(got, d.Error())
+	}
+	want := []string{
+		`1:1: day total of 25h is more than 24h`,
+		`4:1: day out of order, 1 Mar 2021 comes after 2 Mar 2021`,
+		`7:1: duplicate day header, 1 Mar 2021 was first defined at line 4`,
+		`9:1: 1 Mar 2030 is in the future`,
+		`10:1: task without duration`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected diagnostics:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestParseTimeOfDay(t *testing.T) {
+	const content = `# 1 Mar 2021 Monday
+10:00 standup with team
+1h30m coding
+`
+	entries, err := ParseStrict(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	if got := entries[0].TotalDuration(); got != 90*time.Minute {
+		t.Fatalf("want the time of day not counted, got %s total", got)
+	}
+	if desc := entries[0].Tasks[0].Description; desc != "10:00 standup with team" {
+		t.Fatalf("want the time of day kept in the description, got %q", desc)
+	}
+	now := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	diags := Lint(entries, now)
+	want := `2:1: task without duration, "10:00" is read as the time of day, set WORKLOG_DURATION=1:30 to log durations in the clock style`
+	if len(diags) != 1 || diags[0].Error() != want {
+		t.Fatalf("want time of day reported, got %v", diags)
+	}
+
+	// Clock durations are recognized only if explicitly enabled.
+	defer func(style DurationStyle) { DefaultDurationStyle = style }(DefaultDurationStyle)
+	DefaultDurationStyle = DurationClock
+	entries, err = ParseStrict(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	if got := entries[0].TotalDuration(); got != 11*time.Hour+30*time.Minute {
+		t.Fatalf("want clock duration counted, got %s total", got)
+	}
+}
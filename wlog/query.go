@@ -0,0 +1,715 @@
+package wlog
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Query is a compiled task filter expression. Query is evaluated for every
+// task separately. Expressions are comparisons joined with "and", "or" and
+// "not" operators, grouped with parentheses. For example:
+//
+//	desc ~ /deploy/i and duration > 2h and weekday in (Sat, Sun)
+//	not has project and weekday in (Mon..Fri) and date = 2021-Q2
+//	tag = ops or project in (shop, blog)
+//
+// Supported fields are:
+//
+//	desc       task description, compared with =, != or matched with ~, !~
+//	           against a /regexp/flags or a case insensitive "substring"
+//	duration   task duration, for example 2h, 1h30m, 1:30 or 1.5
+//	date       day, compared with a date range expression, for example
+//	           2021-03-05, 2021-03, 2021-Q2 or last-month
+//	weekday    day of the week, for example Mon, Sunday or Mon..Fri
+//	month      month number or name
+//	year       year number
+//	quarter    quarter number, for example 2 or Q2
+//	week       ISO week number
+//	tag        any of the task #tags
+//	project    task +project
+//	client     task @client
+//	attr.<key> value of the task key:value annotation
+//
+// Use "has <field>" to test if the task has a tag, project, client or an
+// attribute, and "<field> in (<value>, ...)" to compare with many values.
+type Query struct {
+	src   string
+	match matcher
+}
+
+type matcher func(e *Entry, t *Task) bool
+
+// ParseQuery compiles the query expression. Relative dates are resolved
+// using the current time.
+func ParseQuery(expr string) (*Query, error) {
+	return ParseQueryAt(expr, time.Now())
+}
+
+// ParseQueryAt compiles the query expression. Relative dates are resolved
+// using given time.
+func ParseQueryAt(expr string, now time.Time) (*Query, error) {
+	tokens, err := lexQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := queryParser{src: expr, tokens: tokens, now: now}
+	m, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf(tok, "unexpected %q", tok.text)
+	}
+	return &Query{src: expr, match: m}, nil
+}
+
+// Match returns true if the task of given entry matches the query.
+func (q *Query) Match(e *Entry, t *Task) bool {
+	return q.match(e, t)
+}
+
+// Filter returns entries with only the tasks that match the query. Entries
+// without any matching task are not returned. Given entries are not
+// modified.
+func (q *Query) Filter(entries []*Entry) []*Entry {
+	var res []*Entry
+	for _, e := range entries {
+		var tasks []*Task
+		for _, t := range e.Tasks {
+			if q.match(e, t) {
+				tasks = append(tasks, t)
+			}
+		}
+		if len(tasks) == 0 {
+			continue
+		}
+		filtered := *e
+		filtered.Tasks = tasks
+		res = append(res, &filtered)
+	}
+	return res
+}
+
+func (q *Query) String() string {
+	return q.src
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokRegexp
+	tokOperator
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// flags are set for the regular expression token only.
+	flags string
+	pos   int
+}
+
+func lexQuery(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokComma, text: ",", pos: i})
+			i++
+		case c == '"' || c == '\'':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(s) && rune(s[j]) != c; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j == len(s) {
+				return nil, fmt.Errorf("query:%d: unterminated string", i+1)
+			}
+			tokens = append(tokens, token{kind: tokString, text: b.String(), pos: i})
+			i = j + 1
+		case c == '/':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(s) && s[j] != '/'; j++ {
+				if s[j] == '\\' && j+1 < len(s) && s[j+1] == '/' {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j == len(s) {
+				return nil, fmt.Errorf("query:%d: unterminated regular expression", i+1)
+			}
+			j++
+			start := j
+			for j < len(s) && unicode.IsLetter(rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokRegexp, text: b.String(), flags: s[start:j], pos: i})
+			i = j
+		case strings.ContainsRune("=!~<>", c):
+			j := i + 1
+			if j < len(s) && strings.ContainsRune("=~", rune(s[j])) {
+				j++
+			}
+			op := s[i:j]
+			switch op {
+			case "=", "==", "!=", "~", "!~", "<", "<=", ">", ">=":
+			default:
+				return nil, fmt.Errorf("query:%d: invalid operator %q", i+1, op)
+			}
+			tokens = append(tokens, token{kind: tokOperator, text: op, pos: i})
+			i = j
+		case isQueryWordChar(c):
+			j := i
+			for j < len(s) && isQueryWordChar(rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokWord, text: s[i:j], pos: i})
+			i = j
+		default:
+			return nil, fmt.Errorf("query:%d: unexpected character %q", i+1, c)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(s)}), nil
+}
+
+func isQueryWordChar(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || strings.ContainsRune("_-.:+@#", c) || c > unicode.MaxASCII
+}
+
+type queryParser struct {
+	src    string
+	tokens []token
+	pos    int
+	now    time.Time
+}
+
+func (p *queryParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// keyword returns true and consumes the token if it is given keyword.
+func (p *queryParser) keyword(name string) bool {
+	if tok := p.peek(); tok.kind == tokWord && strings.EqualFold(tok.text, name) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) errorf(tok token, format string, args ...interface{}) error {
+	if tok.kind == tokEOF {
+		return fmt.Errorf("query:%d: %s, got end of query", tok.pos+1, fmt.Sprintf(format, args...))
+	}
+	return fmt.Errorf("query:%d: %s", tok.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *queryParser) parseOr() (matcher, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(e *Entry, t *Task) bool { return l(e, t) || right(e, t) }
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (matcher, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(e *Entry, t *Task) bool { return l(e, t) && right(e, t) }
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseUnary() (matcher, error) {
+	if p.keyword("not") {
+		m, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(e *Entry, t *Task) bool { return !m(e, t) }, nil
+	}
+	if tok := p.peek(); tok.kind == tokLParen {
+		p.next()
+		m, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); tok.kind != tokRParen {
+			return nil, p.errorf(tok, "expected )")
+		}
+		return m, nil
+	}
+	if p.keyword("has") {
+		tok := p.next()
+		if tok.kind != tokWord {
+			return nil, p.errorf(tok, "expected field name")
+		}
+		get, err := stringField(tok.text)
+		if err != nil {
+			return nil, p.errorf(tok, "%s", err)
+		}
+		return func(e *Entry, t *Task) bool {
+			for _, v := range get(t) {
+				if v != "" {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *queryParser) parseComparison() (matcher, error) {
+	fieldTok := p.next()
+	if fieldTok.kind != tokWord {
+		return nil, p.errorf(fieldTok, "expected field name")
+	}
+	field := strings.ToLower(fieldTok.text)
+
+	var (
+		op     string
+		values []token
+	)
+	switch tok := p.peek(); {
+	case tok.kind == tokOperator:
+		op = p.next().text
+		if op == "==" {
+			op = "="
+		}
+		value := p.next()
+		if value.kind != tokWord && value.kind != tokString && value.kind != tokRegexp {
+			return nil, p.errorf(value, "expected value")
+		}
+		values = []token{value}
+	case tok.kind == tokWord && (strings.EqualFold(tok.text, "in") || strings.EqualFold(tok.text, "not")):
+		op = "in"
+		if p.keyword("not") {
+			op = "not in"
+		}
+		if !p.keyword("in") {
+			return nil, p.errorf(p.peek(), "expected in")
+		}
+		var err error
+		if values, err = p.parseList(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, p.errorf(tok, "expected operator after %q", fieldTok.text)
+	}
+
+	m, err := p.compare(fieldTok, field, op, values)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// parseList parses a parenthesized, comma separated list of values or a
+// single value.
+func (p *queryParser) parseList() ([]token, error) {
+	if p.peek().kind != tokLParen {
+		tok := p.next()
+		if tok.kind != tokWord && tok.kind != tokString {
+			return nil, p.errorf(tok, "expected value")
+		}
+		return []token{tok}, nil
+	}
+	p.next()
+	var values []token
+	for {
+		tok := p.next()
+		if tok.kind != tokWord && tok.kind != tokString {
+			return nil, p.errorf(tok, "expected value")
+		}
+		values = append(values, tok)
+		switch tok := p.next(); tok.kind {
+		case tokComma:
+		case tokRParen:
+			return values, nil
+		default:
+			return nil, p.errorf(tok, "expected , or )")
+		}
+	}
+}
+
+func (p *queryParser) compare(fieldTok token, field, op string, values []token) (matcher, error) {
+	switch field {
+	case "desc", "description":
+		return p.compareDescription(op, values)
+	case "duration":
+		return p.compareOrdered(op, values, func(tok token) (int64, error) {
+			d, err := parseQueryDuration(tok.text)
+			return int64(d), err
+		}, func(e *Entry, t *Task) int64 {
+			return int64(t.Duration)
+		})
+	case "date", "day":
+		return p.compareDate(op, values)
+	case "weekday":
+		return p.compareSet(op, values, func(tok token) ([]int64, error) {
+			return parseWeekdays(tok.text)
+		}, func(e *Entry, t *Task) int64 {
+			return int64(e.Day.Weekday())
+		})
+	case "month":
+		return p.compareOrdered(op, values, func(tok token) (int64, error) {
+			if m, ok := parseMonth(tok.text); ok {
+				return int64(m), nil
+			}
+			return parseQueryInt(tok.text, 1, 12)
+		}, func(e *Entry, t *Task) int64 {
+			return int64(e.Day.Month())
+		})
+	case "year":
+		return p.compareOrdered(op, values, func(tok token) (int64, error) {
+			return parseQueryInt(tok.text, 1, 9999)
+		}, func(e *Entry, t *Task) int64 {
+			return int64(e.Day.Year())
+		})
+	case "quarter":
+		return p.compareOrdered(op, values, func(tok token) (int64, error) {
+			return parseQueryInt(strings.TrimPrefix(strings.ToUpper(tok.text), "Q"), 1, 4)
+		}, func(e *Entry, t *Task) int64 {
+			return int64(quarterOf(e.Day.Month()))
+		})
+	case "week":
+		return p.compareOrdered(op, values, func(tok token) (int64, error) {
+			return parseQueryInt(tok.text, 1, 53)
+		}, func(e *Entry, t *Task) int64 {
+			_, week := e.Day.ISOWeek()
+			return int64(week)
+		})
+	}
+
+	get, err := stringField(fieldTok.text)
+	if err != nil {
+		return nil, p.errorf(fieldTok, "%s", err)
+	}
+	return p.compareStrings(op, values, get)
+}
+
+// stringField returns the getter of an annotation field.
+func stringField(field string) (func(t *Task) []string, error) {
+	switch strings.ToLower(field) {
+	case "tag", "tags":
+		return func(t *Task) []string { return t.Tags }, nil
+	case "project":
+		return func(t *Task) []string { return []string{t.Project} }, nil
+	case "client":
+		return func(t *Task) []string { return []string{t.Client} }, nil
+	case "desc", "description":
+		return func(t *Task) []string { return []string{t.Description} }, nil
+	}
+	if strings.HasPrefix(field, "attr.") && len(field) > len("attr.") {
+		key := field[len("attr."):]
+		return func(t *Task) []string { return []string{t.Attrs[key]} }, nil
+	}
+	return nil, fmt.Errorf("unknown field %q", field)
+}
+
+func (p *queryParser) compareDescription(op string, values []token) (matcher, error) {
+	switch op {
+	case "~", "!~":
+		rx, err := valueRegexp(values[0])
+		if err != nil {
+			return nil, p.errorf(values[0], "%s", err)
+		}
+		negate := op == "!~"
+		return func(e *Entry, t *Task) bool {
+			return rx.MatchString(t.Description) != negate
+		}, nil
+	case "=", "!=", "in", "not in":
+		get, _ := stringField("desc")
+		return p.compareStrings(op, values, get)
+	default:
+		return nil, p.errorf(values[0], "operator %s cannot be used with desc", op)
+	}
+}
+
+// compareStrings compares annotation values, ignoring the case. Any value
+// matching means the comparison is successful.
+func (p *queryParser) compareStrings(op string, values []token, get func(*Task) []string) (matcher, error) {
+	normalize := func(s string) string {
+		return strings.TrimLeft(s, "+@#")
+	}
+	switch op {
+	case "=", "!=", "in", "not in":
+		want := make([]string, 0, len(values))
+		for _, v := range values {
+			if v.kind == tokRegexp {
+				return nil, p.errorf(v, "regular expression requires ~ operator")
+			}
+			if v.kind == tokWord {
+				want = append(want, normalize(v.text))
+			} else {
+				want = append(want, v.text)
+			}
+		}
+		negate := op == "!=" || op == "not in"
+		return func(e *Entry, t *Task) bool {
+			for _, have := range get(t) {
+				for _, w := range want {
+					if strings.EqualFold(strings.TrimSpace(have), w) {
+						return !negate
+					}
+				}
+			}
+			return negate
+		}, nil
+	case "~", "!~":
+		rx, err := valueRegexp(values[0])
+		if err != nil {
+			return nil, p.errorf(values[0], "%s", err)
+		}
+		negate := op == "!~"
+		return func(e *Entry, t *Task) bool {
+			for _, have := range get(t) {
+				if have != "" && rx.MatchString(have) {
+					return !negate
+				}
+			}
+			return negate
+		}, nil
+	default:
+		return nil, p.errorf(values[0], "operator %s cannot be used with text", op)
+	}
+}
+
+// valueRegexp returns the regular expression for the ~ operator. String and
+// word values are matched as case insensitive substrings.
+func valueRegexp(tok token) (*regexp.Regexp, error) {
+	if tok.kind != tokRegexp {
+		return regexp.MustCompile("(?i)" + regexp.QuoteMeta(tok.text)), nil
+	}
+	expr := tok.text
+	if tok.flags != "" {
+		for _, f := range tok.flags {
+			if !strings.ContainsRune("imsU", f) {
+				return nil, fmt.Errorf("invalid regular expression flag %q", f)
+			}
+		}
+		expr = "(?" + tok.flags + ")" + expr
+	}
+	rx, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %w", err)
+	}
+	return rx, nil
+}
+
+func (p *queryParser) compareOrdered(
+	op string,
+	values []token,
+	parse func(token) (int64, error),
+	get func(*Entry, *Task) int64,
+) (matcher, error) {
+	want := make([]int64, 0, len(values))
+	for _, v := range values {
+		n, err := parse(v)
+		if err != nil {
+			return nil, p.errorf(v, "%s", err)
+		}
+		want = append(want, n)
+	}
+	switch op {
+	case "=", "!=", "in", "not in":
+		negate := op == "!=" || op == "not in"
+		return func(e *Entry, t *Task) bool {
+			have := get(e, t)
+			for _, w := range want {
+				if have == w {
+					return !negate
+				}
+			}
+			return negate
+		}, nil
+	case "<":
+		return func(e *Entry, t *Task) bool { return get(e, t) < want[0] }, nil
+	case "<=":
+		return func(e *Entry, t *Task) bool { return get(e, t) <= want[0] }, nil
+	case ">":
+		return func(e *Entry, t *Task) bool { return get(e, t) > want[0] }, nil
+	case ">=":
+		return func(e *Entry, t *Task) bool { return get(e, t) >= want[0] }, nil
+	default:
+		return nil, p.errorf(values[0], "operator %s cannot be used here", op)
+	}
+}
+
+func (p *queryParser) compareSet(
+	op string,
+	values []token,
+	parse func(token) ([]int64, error),
+	get func(*Entry, *Task) int64,
+) (matcher, error) {
+	want := make(map[int64]bool)
+	for _, v := range values {
+		ns, err := parse(v)
+		if err != nil {
+			return nil, p.errorf(v, "%s", err)
+		}
+		for _, n := range ns {
+			want[n] = true
+		}
+	}
+	switch op {
+	case "=", "in":
+		return func(e *Entry, t *Task) bool { return want[get(e, t)] }, nil
+	case "!=", "not in":
+		return func(e *Entry, t *Task) bool { return !want[get(e, t)] }, nil
+	default:
+		return nil, p.errorf(values[0], "operator %s cannot be used here", op)
+	}
+}
+
+func (p *queryParser) compareDate(op string, values []token) (matcher, error) {
+	ranges := make([]DateRange, 0, len(values))
+	for _, v := range values {
+		r, err := ParseDateRange(v.text, p.now)
+		if err != nil {
+			return nil, p.errorf(v, "%s", err)
+		}
+		ranges = append(ranges, r)
+	}
+	within := func(day time.Time) bool {
+		for _, r := range ranges {
+			if r.Contains(day) {
+				return true
+			}
+		}
+		return false
+	}
+	r := ranges[0]
+	switch op {
+	case "=", "in":
+		return func(e *Entry, t *Task) bool { return within(e.Day) }, nil
+	case "!=", "not in":
+		return func(e *Entry, t *Task) bool { return !within(e.Day) }, nil
+	case "<":
+		return func(e *Entry, t *Task) bool { return !r.From.IsZero() && e.Day.Before(r.From) }, nil
+	case "<=":
+		return func(e *Entry, t *Task) bool { return r.To.IsZero() || !e.Day.After(r.To) }, nil
+	case ">":
+		return func(e *Entry, t *Task) bool { return !r.To.IsZero() && e.Day.After(r.To) }, nil
+	case ">=":
+		return func(e *Entry, t *Task) bool { return r.From.IsZero() || !e.Day.Before(r.From) }, nil
+	default:
+		return nil, p.errorf(values[0], "operator %s cannot be used with date", op)
+	}
+}
+
+// parseQueryDuration parses a duration. A number without an unit is a
+// number of hours.
+func parseQueryDuration(s string) (time.Duration, error) {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(f * float64(time.Hour)), nil
+	}
+	d, err := ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+func parseQueryInt(s string, min, max int64) (int64, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("invalid value %q, expected a number between %d and %d", s, min, max)
+	}
+	return n, nil
+}
+
+// parseWeekdays parses a weekday name, a range of weekdays, for example
+// Mon..Fri or Mon-Fri, or a comma separated list of them.
+func parseWeekdays(s string) ([]int64, error) {
+	if strings.Contains(s, ",") {
+		var days []int64
+		for _, chunk := range strings.Split(s, ",") {
+			ds, err := parseWeekdays(chunk)
+			if err != nil {
+				return nil, err
+			}
+			days = append(days, ds...)
+		}
+		return days, nil
+	}
+	sep := ".."
+	if !strings.Contains(s, sep) {
+		sep = "-"
+	}
+	if i := strings.Index(s, sep); i >= 0 {
+		from, ok := parseWeekday(s[:i])
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday %q", s[:i])
+		}
+		to, ok := parseWeekday(s[i+len(sep):])
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday %q", s[i+len(sep):])
+		}
+		var days []int64
+		for d := from; ; d = (d + 1) % 7 {
+			days = append(days, int64(d))
+			if d == to {
+				return days, nil
+			}
+		}
+	}
+	switch strings.ToLower(s) {
+	case "workday", "workdays":
+		return []int64{1, 2, 3, 4, 5}, nil
+	case "weekend":
+		return []int64{0, 6}, nil
+	}
+	d, ok := parseWeekday(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid weekday %q", s)
+	}
+	return []int64{int64(d)}, nil
+}
@@ -0,0 +1,99 @@
+package wlog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryFilter(t *testing.T) {
+	const content = `# 3 Apr 2021 Saturday
+3h30m Deploy the shop +shop @acme #ops
+1h fix JIRA-123 +shop ticket:JIRA-123
+
+# 5 Apr 2021 Monday
+4h planning
+2h review +blog #review #ops
+
+# 5 Jul 2021 Monday
+1h deploy blog +blog
+`
+	entries, err := Parse(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+
+	cases := map[string]struct {
+		query string
+		want  []string
+	}{
+		"regexp and duration": {
+			query: `desc ~ /deploy/i and duration > 2h and weekday in (Sat, Sun)`,
+			want:  []string{"Deploy the shop"},
+		},
+		"substring": {
+			query: `desc ~ "jira-123"`,
+			want:  []string{"fix JIRA-123"},
+		},
+		"attribute": {
+			query: `attr.ticket = JIRA-123`,
+			want:  []string{"fix JIRA-123"},
+		},
+		"no project on workdays in quarter": {
+			query: `not has project and weekday in (Mon..Fri) and date = 2021-Q2`,
+			want:  []string{"planning"},
+		},
+		"tag or project": {
+			query: `tag = #ops or (project in (blog) and month = Jul)`,
+			want:  []string{"Deploy the shop", "review", "deploy blog"},
+		},
+		"date comparison": {
+			query: `date >= 2021-04-05 and date < 2021-Q3 and duration <= 2`,
+			want:  []string{"review"},
+		},
+		"negation": {
+			query: `client != acme and not tag = review and quarter = Q2`,
+			want:  []string{"fix JIRA-123", "planning"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			q, err := ParseQueryAt(tc.query, time.Date(2021, time.August, 1, 0, 0, 0, 0, time.UTC))
+			if err != nil {
+				t.Fatalf("parse query: %s", err)
+			}
+			var got []string
+			for _, e := range q.Filter(entries) {
+				for _, task := range e.Tasks {
+					got = append(got, task.Description)
+				}
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("want %d tasks, got %q", len(tc.want), got)
+			}
+			for i := range got {
+				if !strings.HasPrefix(got[i], tc.want[i]) {
+					t.Errorf("task %d: want %q, got %q", i, tc.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	cases := map[string]string{
+		`duration >`:           `query:11: expected value, got end of query`,
+		`colour = red`:         `query:1: unknown field "colour"`,
+		`desc ~ /(/`:           "query:8: invalid regular expression: error parsing regexp: missing closing ): `(`",
+		`weekday = Funday`:     `query:11: invalid weekday "Funday"`,
+		`duration > 2h or`:     `query:17: expected field name, got end of query`,
+		`(tag = ops`:           `query:11: expected ), got end of query`,
+		`tag = ops project`:    `query:11: unexpected "project"`,
+		`desc ~ "unterminated`: `query:8: unterminated string`,
+	}
+	for query, want := range cases {
+		if _, err := ParseQuery(query); err == nil || err.Error() != want {
+			t.Errorf("%s: want %q error, got %v", query, want, err)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package wlog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateTable resolves the hourly rate of each task. A rate is selected by the
+// most specific annotation of the task and then adjusted by all matching
+// multipliers.
+type RateTable struct {
+	rates       []rateRule
+	multipliers []rateRule
+}
+
+type rateRule struct {
+	// kind is one of default, tag, project, client or weekday.
+	kind     string
+	name     string
+	weekdays []int64
+	value    float64
+	from     time.Time
+}
+
+// rateKinds lists rate rule kinds from the most to the least specific.
+var rateKinds = []string{"tag", "project", "client", "default"}
+
+func (r rateRule) match(e *Entry, t *Task) bool {
+	switch r.kind {
+	case "default":
+		return true
+	case "tag":
+		return t.HasTag(r.name)
+	case "project":
+		return t.Project == r.name
+	case "client":
+		return t.Client == r.name
+	case "weekday":
+		for _, wd := range r.weekdays {
+			if int64(e.Day.Weekday()) == wd {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ParseRateTable reads the rate table definition. Each line defines either a
+// rate or a multiplier. A rate is an amount per hour, a multiplier is written
+// with the x prefix. The first word selects the tasks: "default" applies to
+// all tasks, #tag, +project and @client to annotated tasks and weekday names
+// or ranges (for example sat, mon-fri, weekend) to tasks logged on those days.
+// Rates can be followed by "from" and the day the rate is effective from.
+// Lines starting with # followed by a space are comments. For example:
+//
+//	# Rates per hour.
+//	default 100
+//	default 110 from 2021-07-01
+//	@acme 120
+//	+shop 130 from 2021-03-01
+//	#rush x2
+//	weekend x1.5
+//
+// A task annotated with a tag, a project and a client is billed using the
+// tag rate first, then the project rate, the client rate and the default
+// rate. All multipliers matching the task are applied.
+func ParseRateTable(r io.Reader) (*RateTable, error) {
+	var rt RateTable
+
+	sc := bufio.NewScanner(r)
+	var lineNo int
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line == "#" || strings.HasPrefix(line, "# ") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("rates:%d: missing rate", lineNo)
+		}
+
+		var rule rateRule
+		switch name := fields[0]; {
+		case name == "default":
+			rule.kind = "default"
+		case name[0] == '#' && isAnnotationName(name[1:]):
+			rule.kind, rule.name = "tag", name[1:]
+		case name[0] == '+' && isAnnotationName(name[1:]):
+			rule.kind, rule.name = "project", name[1:]
+		case name[0] == '@' && isAnnotationName(name[1:]):
+			rule.kind, rule.name = "client", name[1:]
+		default:
+			days, err := parseWeekdays(name)
+			if err != nil {
+				return nil, fmt.Errorf("rates:%d: %q is not a default, #tag, +project, @client or weekday", lineNo, name)
+			}
+			rule.kind, rule.weekdays = "weekday", days
+		}
+
+		value := fields[1]
+		multiplier := strings.HasPrefix(value, "x")
+		n, err := strconv.ParseFloat(strings.TrimPrefix(value, "x"), 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("rates:%d: invalid rate %q", lineNo, value)
+		}
+		rule.value = n
+
+		switch rest := fields[2:]; {
+		case len(rest) == 0:
+		case len(rest) == 2 && rest[0] == "from":
+			if multiplier {
+				return nil, fmt.Errorf("rates:%d: multiplier cannot have an effective date", lineNo)
+			}
+			if rule.from, err = ParseDate(rest[1]); err != nil {
+				return nil, fmt.Errorf("rates:%d: %w", lineNo, err)
+			}
+		default:
+			return nil, fmt.Errorf("rates:%d: unexpected %q, expected from <YYYY-MM-DD>", lineNo, strings.Join(rest, " "))
+		}
+
+		if multiplier {
+			rt.multipliers = append(rt.multipliers, rule)
+		} else if rule.kind == "weekday" {
+			return nil, fmt.Errorf("rates:%d: weekday can define only a multiplier, for example %s x1.5", lineNo, fields[0])
+		} else {
+			rt.rates = append(rt.rates, rule)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+
+	// The latest effective rate is checked first.
+	sort.SliceStable(rt.rates, func(i, j int) bool {
+		return rt.rates[i].from.After(rt.rates[j].from)
+	})
+	return &rt, nil
+}
+
+// Rate returns the hourly rate of the task logged on given day. The fallback
+// rate is used if there is no rate defined for the task. Matching multipliers
+// are applied to either rate. Nil table returns the fallback rate.
+func (rt *RateTable) Rate(e *Entry, t *Task, fallback float64) float64 {
+	if rt == nil {
+		return fallback
+	}
+	rate, ok := rt.baseRate(e, t)
+	if !ok {
+		rate = fallback
+	}
+	for _, m := range rt.multipliers {
+		if m.match(e, t) {
+			rate *= m.value
+		}
+	}
+	return rate
+}
+
+// baseRate returns the most specific rate effective on the day of the task,
+// without multipliers.
+func (rt *RateTable) baseRate(e *Entry, t *Task) (float64, bool) {
+	day := truncateDay(e.Day)
+	for _, kind := range rateKinds {
+		for _, r := range rt.rates {
+			if r.kind == kind && !day.Before(r.from) && r.match(e, t) {
+				return r.value, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,98 @@
+package wlog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRateTable(t *testing.T) {
+	rates, err := ParseRateTable(strings.NewReader(`
+# Rates per hour.
+default 100
+default 110 from 2021-07-01
+@acme 120
++shop 130 from 2021-03-01
+#rush x2
+weekend x1.5
+`))
+	if err != nil {
+		t.Fatalf("parse rates: %s", err)
+	}
+	entries, err := Parse(strings.NewReader(`# 1 Feb 2021 Monday
+1h a
+1h b @acme +shop
+
+# 1 Mar 2021 Monday
+1h b @acme +shop
+1h c #rush
+
+# 3 Jul 2021 Saturday
+1h d
+1h e #rush
+`))
+	if err != nil {
+		t.Fatalf("parse entries: %s", err)
+	}
+	want := []float64{100, 120, 130, 200, 165, 330}
+	var i int
+	for _, e := range entries {
+		for _, task := range e.Tasks {
+			if got := rates.Rate(e, task, 0); got != want[i] {
+				t.Errorf("%s %q: want %v rate, got %v", e.Day.Format(dateLayout), task.Description, want[i], got)
+			}
+			i++
+		}
+	}
+}
+
+func TestRateTableFallback(t *testing.T) {
+	rates, err := ParseRateTable(strings.NewReader(`
++shop 130
+#rush x2
+weekend x1.5
+`))
+	if err != nil {
+		t.Fatalf("parse rates: %s", err)
+	}
+	entries, err := Parse(strings.NewReader(`# 3 Jul 2021 Saturday
+1h a
+1h b #rush
+1h c +shop
+
+# 5 Jul 2021 Monday
+1h d
+`))
+	if err != nil {
+		t.Fatalf("parse entries: %s", err)
+	}
+	want := []float64{150, 300, 195, 100}
+	var i int
+	for _, e := range entries {
+		for _, task := range e.Tasks {
+			if got := rates.Rate(e, task, 100); got != want[i] {
+				t.Errorf("%s %q: want %v rate, got %v", e.Day.Format(dateLayout), task.Description, want[i], got)
+			}
+			i++
+		}
+	}
+	if got := (*RateTable)(nil).Rate(entries[0], entries[0].Tasks[0], 100); got != 100 {
+		t.Errorf("nil table: want 100 rate, got %v", got)
+	}
+}
+
+func TestParseRateTableErrors(t *testing.T) {
+	cases := map[string]string{
+		"default":                   "rates:1: missing rate",
+		"default abc":               `rates:1: invalid rate "abc"`,
+		"foo 10":                    `rates:1: "foo" is not a default, #tag, +project, @client or weekday`,
+		"sat 10":                    "rates:1: weekday can define only a multiplier, for example sat x1.5",
+		"#rush x2 from 2021-01-01":  "rates:1: multiplier cannot have an effective date",
+		"default 10 since 2021":     `rates:1: unexpected "since 2021", expected from <YYYY-MM-DD>`,
+		"default 10 from yesterday": `rates:1: invalid date "yesterday", expected YYYY-MM-DD format`,
+	}
+	for src, want := range cases {
+		if _, err := ParseRateTable(strings.NewReader(src)); err == nil || err.Error() != want {
+			t.Errorf("%q: want %q, got %v", src, want, err)
+		}
+	}
+}
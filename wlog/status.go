@@ -0,0 +1,78 @@
+package wlog
+
+import (
+	"strings"
+)
+
+// DayStatus marks a day as a day off. It is written in square brackets at
+// the end of the day header, for example:
+//
+//	# 24 Dec 2021 Friday [vacation]
+type DayStatus string
+
+const (
+	// StatusWork is a regular day.
+	StatusWork DayStatus = ""
+	// StatusVacation is a day of paid leave that counts against the yearly
+	// allowance.
+	StatusVacation DayStatus = "vacation"
+	// StatusSick is a sick leave day.
+	StatusSick DayStatus = "sick"
+	// StatusHoliday is a public holiday.
+	StatusHoliday DayStatus = "holiday"
+	// StatusHalfDay is a half day of vacation. Half of the regular work time
+	// is expected.
+	StatusHalfDay DayStatus = "half-day"
+)
+
+// ParseDayStatus returns the status of given marker name. Matching is case
+// insensitive. Both "holiday" and "public-holiday" mark a public holiday.
+func ParseDayStatus(name string) (DayStatus, bool) {
+	name = strings.ToLower(strings.Join(strings.Fields(name), "-"))
+	switch name {
+	case "vacation", "sick", "holiday", "half-day":
+		return DayStatus(name), true
+	case "public-holiday":
+		return StatusHoliday, true
+	}
+	return StatusWork, false
+}
+
+// Absence returns the part of the work day that is not worked because of
+// the status. It is 1 for a whole day off and 0 for a regular day.
+func (s DayStatus) Absence() float64 {
+	switch s {
+	case StatusVacation, StatusSick, StatusHoliday:
+		return 1
+	case StatusHalfDay:
+		return 0.5
+	default:
+		return 0
+	}
+}
+
+// Vacation returns how many days of the vacation allowance the status uses.
+func (s DayStatus) Vacation() float64 {
+	switch s {
+	case StatusVacation:
+		return 1
+	case StatusHalfDay:
+		return 0.5
+	default:
+		return 0
+	}
+}
+
+// splitDayMarker splits the header line into the date part and the status
+// marker written in square brackets at the end of the line. Column of the
+// marker is relative to the line start and is 0 if there is no marker.
+func splitDayMarker(line string) (string, string, int) {
+	if !strings.HasSuffix(line, "]") {
+		return line, "", 0
+	}
+	i := strings.LastIndexByte(line, '[')
+	if i <= 0 {
+		return line, "", 0
+	}
+	return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1 : len(line)-1]), i
+}
@@ -0,0 +1,87 @@
+package wlog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDayStatus(t *testing.T) {
+	const src = `# 23 Dec 2021 Thursday [half-day]
+4h work
+
+# 24 Dec 2021 Friday [Public Holiday]
+
+# 27 Dec 2021 Monday   [sick]
+
+# 28 Dec 2021 Tuesday
+8h work
+
+# 29 Dec 2021 Wednesday [vacation]
+`
+	f, err := ParseFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	if len(f.Diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics: %s", f.Diagnostics)
+	}
+	entries := f.Entries()
+	want := []DayStatus{StatusHalfDay, StatusHoliday, StatusSick, StatusWork, StatusVacation}
+	if len(entries) != len(want) {
+		t.Fatalf("want %d entries, got %d", len(want), len(entries))
+	}
+	for i, e := range entries {
+		if e.Status != want[i] {
+			t.Errorf("%s: want %q status, got %q", e.Day.Format(dateLayout), want[i], e.Status)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := ToTextStyle(&b, entries, DurationHM); err != nil {
+		t.Fatalf("to text: %s", err)
+	}
+	const wantText = `# 23 Dec 2021 Thursday [half-day]
+4h work
+
+# 24 Dec 2021 Friday [holiday]
+
+# 27 Dec 2021 Monday [sick]
+
+# 28 Dec 2021 Tuesday
+8h work
+
+# 29 Dec 2021 Wednesday [vacation]
+
+`
+	if b.String() != wantText {
+		t.Fatalf("unexpected text\n%s", b.String())
+	}
+
+	contract, err := ParseContract(strings.NewReader("2021-12-01 mon-fri=8h"))
+	if err != nil {
+		t.Fatalf("parse contract: %s", err)
+	}
+	dr, _ := ParseDateRange("2021-W51", time.Now())
+	rows, err := Balance(entries, contract, dr, "week")
+	if err != nil {
+		t.Fatalf("balance: %s", err)
+	}
+	if len(rows) != 1 || rows[0].Expected != 28*time.Hour || rows[0].Worked != 4*time.Hour {
+		t.Fatalf("unexpected balance: %+v", rows)
+	}
+}
+
+func TestUnknownDayMarker(t *testing.T) {
+	f, err := ParseFile(strings.NewReader("# 24 Dec 2021 Friday [vacaton]\n"))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	if len(f.Diagnostics) != 1 || f.Diagnostics[0].Col != 22 {
+		t.Fatalf("unexpected diagnostics: %v", f.Diagnostics)
+	}
+	if f.Lines[0].Kind != HeaderLine {
+		t.Fatalf("want header line, got %s", f.Lines[0].Kind)
+	}
+}